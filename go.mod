@@ -4,7 +4,10 @@ go 1.25.0
 
 require (
 	github.com/joho/godotenv v1.5.1
+	github.com/jung-kurt/gofpdf v1.16.2
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/xuri/excelize/v2 v2.10.0
+	golang.org/x/image v0.25.0
 	golang.org/x/oauth2 v0.28.0
 	google.golang.org/api v0.210.0
 )
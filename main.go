@@ -1,27 +1,37 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
-	"github.com/ARQAP/ARQAP-Backend/src/db"
+	dbpkg "github.com/ARQAP/ARQAP-Backend/src/db"
+	"github.com/ARQAP/ARQAP-Backend/src/mailer"
 	"github.com/ARQAP/ARQAP-Backend/src/middleware"
 	"github.com/ARQAP/ARQAP-Backend/src/models"
 	"github.com/ARQAP/ARQAP-Backend/src/routes"
 	"github.com/ARQAP/ARQAP-Backend/src/seed"
 	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/ARQAP/ARQAP-Backend/src/storage"
 	"github.com/ARQAP/ARQAP-Backend/src/utils"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
 	// Database connection
-	db, err := db.Connect()
+	db, err := dbpkg.Connect()
 	if err != nil {
 		log.Fatalf("Error connecting to database: %v\n", err)
 	}
 
+	// Schema changes AutoMigrate can't apply by itself
+	if err := dbpkg.RunPreMigrations(db); err != nil {
+		log.Fatalf("Error during pre-migration: %v\n", err)
+	}
+
 	// Auto-migrate models
 	if err := db.AutoMigrate(
 		&models.UserModel{},
@@ -39,13 +49,32 @@ func main() {
 		&models.PictureModel{},
 		&models.HistoricalRecordModel{},
 		&models.MentionModel{},
+		&models.InstitutionModel{},
 		&models.RequesterModel{},
+		&models.LoanHeaderModel{},
 		&models.LoanModel{},
+		&models.LoanDocumentModel{},
+		&models.NotificationLogModel{},
+		&models.LoanRequestModel{},
+		&models.LoanRequestItemModel{},
+		&models.LoanRequestEventModel{},
+		&models.ReservationModel{},
+		&models.MovementGroupModel{},
 		&models.InternalMovementModel{},
+		&models.LocationAssignmentModel{},
+		&models.StocktakeSessionModel{},
+		&models.StocktakeShelfModel{},
+		&models.StocktakeLocationModel{},
+		&models.StocktakeScanModel{},
 	); err != nil {
 		log.Fatalf("Error during auto-migration: %v\n", err)
 	}
 
+	// Data backfills for newly added columns
+	if err := dbpkg.RunPostMigrations(db); err != nil {
+		log.Fatalf("Error during post-migration: %v\n", err)
+	}
+
 	// Db seeding
 	seed.Seed(db)
 
@@ -75,7 +104,20 @@ func main() {
 
 	router.Use(middleware.SetupCORS())
 
-	router.Static("/uploads", "./uploads")
+	// File storage backend (local disk or S3-compatible)
+	store, err := storage.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring file storage: %v\n", err)
+	}
+	log.Printf("File storage backend: %s\n", store.Name())
+
+	// Legacy direct access to uploaded files, only meaningful when they live on local disk.
+	// Quarantined files and loan documents (personal data) used to be kept under uploads too; they are never
+	// served here, loan documents only through the authenticated /loans/:id/documents routes.
+	if store.Name() == storage.BackendLocal {
+		uploads := router.Group("/uploads", middleware.BlockPaths("/uploads/quarantine", "/uploads/loans"))
+		uploads.Static("/", "./uploads")
+	}
 
 	// Services setup
 	archaeologicalsiteService := services.NewArchaeologicalSiteService(db)
@@ -86,21 +128,79 @@ func main() {
 	collectionService := services.NewCollectionService(db)
 	shelfService := services.NewShelfService(db)
 	physicalLocationService := services.NewPhysicalLocationService(db)
-	artefactService := services.NewArtefactService(db)
+	artefactService := services.NewArtefactService(db, store)
 	internalLocationService := services.NewInternalClassifierService(db)
 	mentionService := services.NewMentionService(db)
-	loanService := services.NewLoanService(db, artefactService)
+	loanService := services.NewLoanService(db, store, artefactService)
+	loanRequestService := services.NewLoanRequestService(db, loanService)
+	reservationService := services.NewReservationService(db)
+	loanDocumentService := services.NewLoanDocumentService(db, store)
+	calendarFeedService := services.NewCalendarFeedService(db)
+	statisticsService := services.NewStatisticsService(db)
 	requesterService := services.NewRequesterService(db)
+	institutionService := services.NewInstitutionService(db)
 	internalMovementService := services.NewInternalMovementService(db)
+	locationHistoryService := services.NewLocationHistoryService(db)
+	stocktakeService := services.NewStocktakeService(db, internalMovementService)
+	labelService := services.NewLabelService(db)
 
 	// INPL uploads root (from env or default)
 	inplUploadRoot := os.Getenv("INPL_UPLOAD_ROOT")
 	if inplUploadRoot == "" {
 		inplUploadRoot = filepath.Join("uploads", "inpl")
 	}
-	_ = os.MkdirAll(inplUploadRoot, 0o755)
 
-	inplClassifierService := services.NewINPLService(db, inplUploadRoot)
+	inplClassifierService := services.NewINPLService(db, store, inplUploadRoot)
+
+	// Periodic fixity check of stored files (hours, 0 disables it)
+	fixityIntervalHours := 24
+	if v := os.Getenv("FIXITY_CHECK_INTERVAL_HOURS"); v != "" {
+		if hours, err := strconv.Atoi(v); err == nil {
+			fixityIntervalHours = hours
+		} else {
+			log.Printf("Invalid FIXITY_CHECK_INTERVAL_HOURS %q, using %d\n", v, fixityIntervalHours)
+		}
+	}
+	fileIntegrityService := services.NewFileIntegrityService(db, store)
+	fileIntegrityService.StartFixityScheduler(time.Duration(fixityIntervalHours) * time.Hour)
+
+	// Loan reminder emails (SMTP_HOST unset disables them; hours, 0 disables the scheduler)
+	var reminderSender mailer.Sender
+	if sender, err := mailer.NewFromEnv(); err == nil {
+		reminderSender = sender
+	} else if !errors.Is(err, mailer.ErrNotConfigured) {
+		log.Fatalf("Error configuring SMTP: %v\n", err)
+	}
+	reminderIntervalHours := 24
+	if v := os.Getenv("LOAN_REMINDER_INTERVAL_HOURS"); v != "" {
+		if hours, err := strconv.Atoi(v); err == nil {
+			reminderIntervalHours = hours
+		} else {
+			log.Printf("Invalid LOAN_REMINDER_INTERVAL_HOURS %q, using %d\n", v, reminderIntervalHours)
+		}
+	}
+	notificationService := services.NewNotificationService(db, reminderSender)
+	notificationService.StartReminderScheduler(time.Duration(reminderIntervalHours) * time.Hour)
+
+	// Anonymization of requesters inactive for REQUESTER_RETENTION_MONTHS (0 disables the periodic run)
+	retentionIntervalHours := 24
+	if v := os.Getenv("REQUESTER_RETENTION_INTERVAL_HOURS"); v != "" {
+		if hours, err := strconv.Atoi(v); err == nil {
+			retentionIntervalHours = hours
+		} else {
+			log.Printf("Invalid REQUESTER_RETENTION_INTERVAL_HOURS %q, using %d\n", v, retentionIntervalHours)
+		}
+	}
+	requesterPrivacyService := services.NewRequesterPrivacyService(db, store)
+	requesterPrivacyService.StartRetentionScheduler(time.Duration(retentionIntervalHours) * time.Hour)
+
+	// Upload roots scanned for orphaned files
+	fileReconciliationService := services.NewFileReconciliationService(db, store, artefactService, []string{
+		filepath.Join("uploads", "pictures"),
+		filepath.Join("uploads", "historical_records"),
+		inplUploadRoot,
+		filepath.Join("private", "loans"),
+	})
 
 	// Routes setup
 	routes.SetupArchaeologicalSiteRoutes(router, archaeologicalsiteService)
@@ -112,12 +212,25 @@ func main() {
 	routes.SetupArtefactRoutes(router, artefactService)
 	routes.SetupCollectionRoutes(router, collectionService)
 	routes.SetupShelfsRoutes(router, shelfService)
+	routes.SetupLocationHistoryRoutes(router, locationHistoryService)
 	routes.SetupInternalClassifiersRoutes(router, internalLocationService)
 	routes.SetupINPLClassifiersRoutes(router, inplClassifierService)
 	routes.SetupMentionRoutes(router, mentionService)
 	routes.SetupLoanRoutes(router, loanService)
+	routes.SetupLoanRequestRoutes(router, loanRequestService)
+	routes.SetupReservationRoutes(router, reservationService)
+	routes.SetupLoanDocumentRoutes(router, loanDocumentService)
+	routes.SetupCalendarFeedRoutes(router, calendarFeedService)
+	routes.SetupNotificationRoutes(router, notificationService)
+	routes.SetupStatisticsRoutes(router, statisticsService)
 	routes.SetupRequesterRoutes(router, requesterService)
+	routes.SetupInstitutionRoutes(router, institutionService)
+	routes.SetupRequesterPrivacyRoutes(router, requesterPrivacyService)
 	routes.SetupInternalMovementRoutes(router, internalMovementService)
+	routes.SetupStocktakeRoutes(router, stocktakeService)
+	routes.SetupLabelRoutes(router, labelService)
+	routes.SetupFileIntegrityRoutes(router, fileIntegrityService)
+	routes.SetupFileReconciliationRoutes(router, fileReconciliationService)
 
 	// Test route
 	router.GET("/", func(c *gin.Context) {
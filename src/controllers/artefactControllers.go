@@ -1,18 +1,22 @@
 package controllers
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
 	"github.com/ARQAP/ARQAP-Backend/src/models"
 	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/ARQAP/ARQAP-Backend/src/storage"
+	"github.com/ARQAP/ARQAP-Backend/src/utils"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type ArtefactController struct {
@@ -173,8 +177,119 @@ func (ac *ArtefactController) DeleteArtefact(c *gin.Context) {
 
 // ======================= ARCHIVOS =======================
 
-// ======================= FOTO =======================
+// parseFileID parses a nested file id route param (pictureId, recordId)
+func parseFileID(c *gin.Context, param string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(param))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return 0, false
+	}
+	return id, true
+}
+
+// parseOptionalDate parses an optional YYYY-MM-DD form value
+func parseOptionalDate(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
+
+// optionalFormValue returns nil for empty form values
+func optionalFormValue(c *gin.Context, key string) *string {
+	value := strings.TrimSpace(c.PostForm(key))
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// serveArtefactFile writes a stored file with cache validation headers
+func serveArtefactFile(c *gin.Context, store storage.Storage, filePath, contentType, etag, cacheControl string) {
+	// Verify that the file exists
+	fileInfo, err := store.Stat(filePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(404, gin.H{"error": "File not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Could not read file"})
+		return
+	}
+
+	// Cache headers
+	lastModified := fileInfo.ModTime.UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT")
+	c.Header("Cache-Control", cacheControl)
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", lastModified)
+
+	// Verify If-None-Match (ETag)
+	if match := c.GetHeader("If-None-Match"); match == etag {
+		c.Status(304) // Not Modified
+		return
+	}
+
+	// Verify If-Modified-Since
+	if modSince := c.GetHeader("If-Modified-Since"); modSince != "" {
+		if t, err := time.Parse("Mon, 02 Jan 2006 15:04:05 GMT", modSince); err == nil {
+			if !fileInfo.ModTime.After(t) {
+				c.Status(304) // Not Modified
+				return
+			}
+		}
+	}
+
+	// Serve file with correct content type
+	content, err := store.Open(filePath)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Could not read file"})
+		return
+	}
+	defer content.Close()
 
+	c.DataFromReader(200, fileInfo.Size, contentType, content, nil)
+}
+
+// respondFileError maps service errors of file operations to HTTP responses
+func respondFileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(404, gin.H{"error": "File not found"})
+	case errors.Is(err, services.ErrInvalidFileOrder):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+// respondUploadError maps upload validation errors to HTTP responses
+func respondUploadError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, utils.ErrUploadTooLarge), errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, utils.ErrUploadTypeNotAllowed):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, utils.ErrUploadEmpty), errors.Is(err, utils.ErrUploadInvalid), errors.Is(err, utils.ErrUploadPolyglot):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// limitUploadBody caps the request body to the size limit of the upload kind (plus room for the form fields)
+func limitUploadBody(c *gin.Context, kind string) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, utils.MaxUploadSize(kind)+1<<20)
+}
+
+// ======================= FOTOS =======================
+
+// UploadPicture adds a picture to the artefact. Optional form fields: caption, photographer, takenAt (YYYY-MM-DD), isPrimary
 func (ac *ArtefactController) UploadPicture(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -189,39 +304,40 @@ func (ac *ArtefactController) UploadPicture(c *gin.Context) {
 		return
 	}
 
+	limitUploadBody(c, utils.UploadKindPicture)
 	file, header, err := c.Request.FormFile("picture")
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondUploadError(c, err)
+			return
+		}
 		c.JSON(400, gin.H{"error": "No file uploaded"})
 		return
 	}
 	defer file.Close()
 
-	// Validate file type
-	if !strings.HasPrefix(header.Header.Get("Content-Type"), "image/") {
-		c.JSON(400, gin.H{"error": "File must be an image"})
+	// Validate file by its content (the client Content-Type is not trusted)
+	upload, err := utils.ValidateUpload(utils.UploadKindPicture, file, header.Filename)
+	if err != nil {
+		respondUploadError(c, err)
 		return
 	}
 
-	// Create directories if they don't exist
-	uploadDir := "uploads/pictures"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		c.JSON(500, gin.H{"error": "Could not create upload directory"})
+	takenAt, err := parseOptionalDate(c.PostForm("takenAt"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "takenAt must be a date in YYYY-MM-DD format"})
 		return
 	}
 
+	uploadDir := "uploads/pictures"
+
 	// Generate unique filename
-	filename := fmt.Sprintf("artefact_%d_%d_%s", id, time.Now().Unix(), header.Filename)
+	filename := fmt.Sprintf("artefact_%d_%d_%s", id, time.Now().UnixNano(), upload.Filename)
 	filePath := filepath.Join(uploadDir, filename)
 
 	// Save file
-	dst, err := os.Create(filePath)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Could not save file"})
-		return
-	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, file); err != nil {
+	if err := ac.service.Storage().Put(filePath, bytes.NewReader(upload.Data), int64(len(upload.Data)), upload.ContentType); err != nil {
 		c.JSON(500, gin.H{"error": "Could not save file"})
 		return
 	}
@@ -232,15 +348,19 @@ func (ac *ArtefactController) UploadPicture(c *gin.Context) {
 		Filename:     filename,
 		OriginalName: header.Filename,
 		FilePath:     filePath,
-		ContentType:  header.Header.Get("Content-Type"),
-		Size:         header.Size,
+		ContentType:  upload.ContentType,
+		Size:         int64(len(upload.Data)),
+		Caption:      optionalFormValue(c, "caption"),
+		Photographer: optionalFormValue(c, "photographer"),
+		TakenAt:      takenAt,
+		IsPrimary:    c.PostForm("isPrimary") == "true",
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
 
 	if err := ac.service.SavePicture(&picture); err != nil {
 		// Clean up file if DB save fails
-		_ = os.Remove(filePath)
+		_ = ac.service.Storage().Delete(filePath)
 		c.JSON(500, gin.H{"error": "Could not save picture metadata"})
 		return
 	}
@@ -248,6 +368,7 @@ func (ac *ArtefactController) UploadPicture(c *gin.Context) {
 	c.JSON(200, picture)
 }
 
+// ServePicture serves the primary picture of the artefact. Query: size=thumb|medium|original (default original)
 func (ac *ArtefactController) ServePicture(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -255,51 +376,143 @@ func (ac *ArtefactController) ServePicture(c *gin.Context) {
 		return
 	}
 
+	size, err := utils.ParseImageSize(c.Query("size"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
 	picture, err := ac.service.GetPictureByArtefactID(id)
 	if err != nil || picture == nil {
 		c.JSON(404, gin.H{"error": "Picture not found"})
 		return
 	}
 
-	// Verify that the file exists
-	fileInfo, err := os.Stat(picture.FilePath)
-	if os.IsNotExist(err) {
-		c.JSON(404, gin.H{"error": "Picture file not found"})
+	ac.servePicture(c, picture, size)
+}
+
+// servePicture serves the requested size of a picture (thumb, medium or original)
+func (ac *ArtefactController) servePicture(c *gin.Context, picture *models.PictureModel, size string) {
+	filePath, contentType := ac.service.PictureFileForSize(picture, size)
+
+	// Cache for 1 year (images rarely change)
+	etag := fmt.Sprintf(`"%d-%d-%s"`, picture.ID, picture.UpdatedAt.Unix(), size)
+	serveArtefactFile(c, ac.service.Storage(), filePath, contentType, etag, "public, max-age=31536000")
+}
+
+// ListPictures lists every picture of the artefact
+func (ac *ArtefactController) ListPictures(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
 		return
 	}
 
-	// Cache headers
-	lastModified := fileInfo.ModTime().UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT")
-	etag := fmt.Sprintf(`"%d-%d"`, picture.ID, picture.UpdatedAt.Unix())
+	pictures, err := ac.service.GetPicturesByArtefactID(id)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, pictures)
+}
 
-	// Cache for 1 year (images rarely change)
-	c.Header("Cache-Control", "public, max-age=31536000") // 1 year
-	c.Header("ETag", etag)
-	c.Header("Last-Modified", lastModified)
+// ServePictureByID serves a specific picture of the artefact. Query: size=thumb|medium|original (default original)
+func (ac *ArtefactController) ServePictureByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+	pictureID, ok := parseFileID(c, "pictureId")
+	if !ok {
+		return
+	}
 
-	// Verify If-None-Match (ETag)
-	if match := c.GetHeader("If-None-Match"); match == etag {
-		c.Status(304) // Not Modified
+	size, err := utils.ParseImageSize(c.Query("size"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Verify If-Modified-Since
-	if modSince := c.GetHeader("If-Modified-Since"); modSince != "" {
-		if t, err := time.Parse("Mon, 02 Jan 2006 15:04:05 GMT", modSince); err == nil {
-			if !fileInfo.ModTime().After(t) {
-				c.Status(304) // Not Modified
-				return
-			}
-		}
+	picture, err := ac.service.GetPictureByID(id, pictureID)
+	if err != nil {
+		respondFileError(c, err)
+		return
 	}
 
-	// Serve file with correct content type
-	c.Header("Content-Type", picture.ContentType)
-	c.File(picture.FilePath)
+	ac.servePicture(c, picture, size)
 }
 
-// ======================= DOCUMENTO HISTÓRICO =======================
+// UpdatePicture updates the metadata of a picture (caption, photographer, takenAt, isPrimary)
+func (ac *ArtefactController) UpdatePicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+	pictureID, ok := parseFileID(c, "pictureId")
+	if !ok {
+		return
+	}
 
+	var metadata dtos.PictureMetadataDTO
+	if err := c.ShouldBindJSON(&metadata); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	picture, err := ac.service.UpdatePictureMetadata(id, pictureID, &metadata)
+	if err != nil {
+		respondFileError(c, err)
+		return
+	}
+	c.JSON(200, picture)
+}
+
+// ReorderPictures sets the display order of the artefact's pictures
+func (ac *ArtefactController) ReorderPictures(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var body dtos.ReorderFilesDTO
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	pictures, err := ac.service.ReorderPictures(id, body.IDs)
+	if err != nil {
+		respondFileError(c, err)
+		return
+	}
+	c.JSON(200, pictures)
+}
+
+// DeletePicture removes a single picture of the artefact
+func (ac *ArtefactController) DeletePicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+	pictureID, ok := parseFileID(c, "pictureId")
+	if !ok {
+		return
+	}
+
+	if err := ac.service.DeletePicture(id, pictureID); err != nil {
+		respondFileError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Picture deleted successfully"})
+}
+
+// ======================= DOCUMENTOS HISTÓRICOS =======================
+
+// UploadHistoricalRecord adds a historical record to the artefact. Optional form fields: caption, author, documentDate (YYYY-MM-DD), isPrimary
 func (ac *ArtefactController) UploadHistoricalRecord(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -314,40 +527,40 @@ func (ac *ArtefactController) UploadHistoricalRecord(c *gin.Context) {
 		return
 	}
 
+	limitUploadBody(c, utils.UploadKindDocument)
 	file, header, err := c.Request.FormFile("document")
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondUploadError(c, err)
+			return
+		}
 		c.JSON(400, gin.H{"error": "No file uploaded"})
 		return
 	}
 	defer file.Close()
 
-	// Validate file type
-	contentType := header.Header.Get("Content-Type")
-	if !strings.HasPrefix(contentType, "image/") && contentType != "application/pdf" {
-		c.JSON(400, gin.H{"error": "File must be an image or PDF"})
+	// Validate file by its content: images or PDF
+	upload, err := utils.ValidateUpload(utils.UploadKindDocument, file, header.Filename)
+	if err != nil {
+		respondUploadError(c, err)
 		return
 	}
 
-	// Create directories if they don't exist
-	uploadDir := "uploads/historical_records"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		c.JSON(500, gin.H{"error": "Could not create upload directory"})
+	documentDate, err := parseOptionalDate(c.PostForm("documentDate"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "documentDate must be a date in YYYY-MM-DD format"})
 		return
 	}
 
+	uploadDir := "uploads/historical_records"
+
 	// Generate unique filename
-	filename := fmt.Sprintf("record_%d_%d_%s", id, time.Now().Unix(), header.Filename)
+	filename := fmt.Sprintf("record_%d_%d_%s", id, time.Now().UnixNano(), upload.Filename)
 	filePath := filepath.Join(uploadDir, filename)
 
 	// Save file
-	dst, err := os.Create(filePath)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Could not save file"})
-		return
-	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, file); err != nil {
+	if err := ac.service.Storage().Put(filePath, bytes.NewReader(upload.Data), int64(len(upload.Data)), upload.ContentType); err != nil {
 		c.JSON(500, gin.H{"error": "Could not save file"})
 		return
 	}
@@ -358,15 +571,19 @@ func (ac *ArtefactController) UploadHistoricalRecord(c *gin.Context) {
 		Filename:     filename,
 		OriginalName: header.Filename,
 		FilePath:     filePath,
-		ContentType:  contentType,
-		Size:         header.Size,
+		ContentType:  upload.ContentType,
+		Size:         int64(len(upload.Data)),
+		Caption:      optionalFormValue(c, "caption"),
+		Author:       optionalFormValue(c, "author"),
+		DocumentDate: documentDate,
+		IsPrimary:    c.PostForm("isPrimary") == "true",
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
 
 	if err := ac.service.SaveHistoricalRecord(&record); err != nil {
 		// Clean up file if DB save fails
-		_ = os.Remove(filePath)
+		_ = ac.service.Storage().Delete(filePath)
 		c.JSON(500, gin.H{"error": "Could not save document metadata"})
 		return
 	}
@@ -374,6 +591,7 @@ func (ac *ArtefactController) UploadHistoricalRecord(c *gin.Context) {
 	c.JSON(200, record)
 }
 
+// ServeHistoricalRecord serves the primary historical record of the artefact
 func (ac *ArtefactController) ServeHistoricalRecord(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -387,40 +605,114 @@ func (ac *ArtefactController) ServeHistoricalRecord(c *gin.Context) {
 		return
 	}
 
-	// Verify that the file exists
-	fileInfo, err := os.Stat(record.FilePath)
-	if os.IsNotExist(err) {
-		c.JSON(404, gin.H{"error": "Document file not found"})
+	// Cache for 1 month (historical documents change less)
+	etag := fmt.Sprintf(`"%d-%d"`, record.ID, record.UpdatedAt.Unix())
+	serveArtefactFile(c, ac.service.Storage(), record.FilePath, record.ContentType, etag, "public, max-age=2592000")
+}
+
+// ListHistoricalRecords lists every historical record of the artefact
+func (ac *ArtefactController) ListHistoricalRecords(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	records, err := ac.service.GetHistoricalRecordsByArtefactID(id)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, records)
+}
+
+// ServeHistoricalRecordByID serves a specific historical record of the artefact
+func (ac *ArtefactController) ServeHistoricalRecordByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+	recordID, ok := parseFileID(c, "recordId")
+	if !ok {
+		return
+	}
+
+	record, err := ac.service.GetHistoricalRecordByID(id, recordID)
+	if err != nil {
+		respondFileError(c, err)
 		return
 	}
 
-	// Cache headers
-	lastModified := fileInfo.ModTime().UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT")
 	etag := fmt.Sprintf(`"%d-%d"`, record.ID, record.UpdatedAt.Unix())
+	serveArtefactFile(c, ac.service.Storage(), record.FilePath, record.ContentType, etag, "public, max-age=2592000")
+}
 
-	// Cache for 1 month (historical documents change less)
-	c.Header("Cache-Control", "public, max-age=2592000") // 30 days
-	c.Header("ETag", etag)
-	c.Header("Last-Modified", lastModified)
+// UpdateHistoricalRecord updates the metadata of a historical record (caption, author, documentDate, isPrimary)
+func (ac *ArtefactController) UpdateHistoricalRecord(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+	recordID, ok := parseFileID(c, "recordId")
+	if !ok {
+		return
+	}
 
-	// Verify cache
-	if match := c.GetHeader("If-None-Match"); match == etag {
-		c.Status(304) // Not Modified
+	var metadata dtos.HistoricalRecordMetadataDTO
+	if err := c.ShouldBindJSON(&metadata); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	if modSince := c.GetHeader("If-Modified-Since"); modSince != "" {
-		if t, err := time.Parse("Mon, 02 Jan 2006 15:04:05 GMT", modSince); err == nil {
-			if !fileInfo.ModTime().After(t) {
-				c.Status(304) // Not Modified
-				return
-			}
-		}
+	record, err := ac.service.UpdateHistoricalRecordMetadata(id, recordID, &metadata)
+	if err != nil {
+		respondFileError(c, err)
+		return
+	}
+	c.JSON(200, record)
+}
+
+// ReorderHistoricalRecords sets the display order of the artefact's historical records
+func (ac *ArtefactController) ReorderHistoricalRecords(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var body dtos.ReorderFilesDTO
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
 	}
 
-	// Serve historical document with correct content type
-	c.Header("Content-Type", record.ContentType)
-	c.File(record.FilePath)
+	records, err := ac.service.ReorderHistoricalRecords(id, body.IDs)
+	if err != nil {
+		respondFileError(c, err)
+		return
+	}
+	c.JSON(200, records)
+}
+
+// DeleteHistoricalRecord removes a single historical record of the artefact
+func (ac *ArtefactController) DeleteHistoricalRecord(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+	recordID, ok := parseFileID(c, "recordId")
+	if !ok {
+		return
+	}
+
+	if err := ac.service.DeleteHistoricalRecord(id, recordID); err != nil {
+		respondFileError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Historical record deleted successfully"})
 }
 
 // ======================= ARTEFACTO + MENCIONES =======================
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CalendarFeedController struct {
+	service *services.CalendarFeedService
+}
+
+func NewCalendarFeedController(service *services.CalendarFeedService) *CalendarFeedController {
+	return &CalendarFeedController{service: service}
+}
+
+// CreateCalendarToken handles POST requests to issue (or rotate) the current user's feed token
+func (c *CalendarFeedController) CreateCalendarToken(ctx *gin.Context) {
+	userId, ok := middleware.CurrentUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	token, err := c.service.RotateCalendarToken(userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if errors.Is(err, services.ErrCalendarFeedForbidden) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"token": token, "path": "/calendar/" + token + "/feed.ics"})
+}
+
+// RevokeCalendarToken handles DELETE requests to disable the current user's feed
+func (c *CalendarFeedController) RevokeCalendarToken(ctx *gin.Context) {
+	userId, ok := middleware.CurrentUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := c.service.RevokeCalendarToken(userId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// GetCalendarFeed handles GET requests for the iCalendar feed; the token in the URL authenticates the user
+func (c *CalendarFeedController) GetCalendarFeed(ctx *gin.Context) {
+	feed, err := c.service.GetCalendarFeed(ctx.Param("token"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCalendarToken) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Header("Cache-Control", "private, no-cache")
+	ctx.Data(http.StatusOK, "text/calendar; charset=utf-8", feed)
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+type FileIntegrityController struct {
+	service *services.FileIntegrityService
+}
+
+func NewFileIntegrityController(service *services.FileIntegrityService) *FileIntegrityController {
+	return &FileIntegrityController{service: service}
+}
+
+// GetLastFixityReport handles GET requests to retrieve the result of the last fixity check
+func (c *FileIntegrityController) GetLastFixityReport(ctx *gin.Context) {
+	report := c.service.GetLastFixityReport()
+	if report == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "No fixity check has run yet"})
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// RunFixityCheck handles POST requests to re-hash every stored file and report missing or corrupted ones
+func (c *FileIntegrityController) RunFixityCheck(ctx *gin.Context) {
+	report, err := c.service.RunFixityCheck()
+	if err != nil {
+		if errors.Is(err, services.ErrFixityCheckRunning) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
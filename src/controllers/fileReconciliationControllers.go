@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+type FileReconciliationController struct {
+	service *services.FileReconciliationService
+}
+
+func NewFileReconciliationController(service *services.FileReconciliationService) *FileReconciliationController {
+	return &FileReconciliationController{service: service}
+}
+
+// ScanOrphans handles GET requests to report orphaned files and rows whose file is missing.
+// Query: minAgeMinutes (default 60) skips files newer than that.
+func (c *FileReconciliationController) ScanOrphans(ctx *gin.Context) {
+	minAge := 60
+	if v := ctx.Query("minAgeMinutes"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "minAgeMinutes must be a non-negative integer"})
+			return
+		}
+		minAge = parsed
+	}
+
+	report, err := c.service.Scan(time.Duration(minAge) * time.Minute)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// ReconcileOrphans handles POST requests to quarantine or purge the orphans found by a new scan
+func (c *FileReconciliationController) ReconcileOrphans(ctx *gin.Context) {
+	var body dtos.ReconcileFilesDTO
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := c.service.Reconcile(&body)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/ARQAP/ARQAP-Backend/src/models"
 	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/ARQAP/ARQAP-Backend/src/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -116,7 +117,7 @@ func (c *INPLClassifierController) CreateINPLClassifier(ctx *gin.Context) {
 
 	classifier, _, err := c.service.CreateClassifierWithFichas(uploads)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondUploadError(ctx, err)
 		return
 	}
 	reloaded, err := c.service.GetClassifierByID(classifier.ID)
@@ -217,7 +218,7 @@ func (c *INPLClassifierController) AddFichasToINPLClassifier(ctx *gin.Context) {
 	defer closeAll(closers)
 
 	if _, err := c.service.AddFichasToClassifier(classifierID, uploads); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondUploadError(ctx, err)
 		return
 	}
 	reloaded, err := c.service.GetClassifierByID(classifierID)
@@ -272,7 +273,7 @@ func (c *INPLClassifierController) ReplaceFicha(ctx *gin.Context) {
 	}
 	updated, err := c.service.ReplaceFicha(fichaID, upload)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondUploadError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, updated)
@@ -294,6 +295,7 @@ func (c *INPLClassifierController) DeleteFicha(ctx *gin.Context) {
 }
 
 // DownloadFicha serves the file associated with the given ficha ID
+// Query: size=thumb|medium|original (default original)
 func (c *INPLClassifierController) DownloadFicha(ctx *gin.Context) {
 	idParam := ctx.Param("id")
 	fichaID, err := strconv.Atoi(idParam)
@@ -301,6 +303,11 @@ func (c *INPLClassifierController) DownloadFicha(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
+	size, err := utils.ParseImageSize(ctx.Query("size"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	f, err := c.service.GetFichaByID(fichaID)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": "not found"})
@@ -318,6 +325,11 @@ func (c *INPLClassifierController) DownloadFicha(ctx *gin.Context) {
 	} else if strings.HasSuffix(strings.ToLower(f.Filename), ".webp") {
 		contentType = "image/webp"
 	}
+
+	filePath := f.FilePath
+	if size != utils.ImageSizeOriginal && contentType != "application/pdf" {
+		filePath, contentType = c.service.FichaFileForSize(f, size)
+	}
 	
 	// Headers para evitar caché y asegurar el Content-Type correcto
 	ctx.Header("Content-Disposition", `inline; filename="`+f.Filename+`"`)
@@ -325,7 +337,19 @@ func (c *INPLClassifierController) DownloadFicha(ctx *gin.Context) {
 	ctx.Header("Cache-Control", "no-cache, no-store, must-revalidate")
 	ctx.Header("Pragma", "no-cache")
 	ctx.Header("Expires", "0")
-	ctx.File(f.FilePath)
+
+	info, err := c.service.Storage().Stat(filePath)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	content, err := c.service.Storage().Open(filePath)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "could not read file"})
+		return
+	}
+	defer content.Close()
+	ctx.DataFromReader(http.StatusOK, info.Size, contentType, content, nil)
 }
 
 // closeAll closes all provided ReadClosers
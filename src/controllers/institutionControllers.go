@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type InstitutionController struct {
+	service *services.InstitutionService
+}
+
+func NewInstitutionController(service *services.InstitutionService) *InstitutionController {
+	return &InstitutionController{service: service}
+}
+
+// respondInstitutionError maps institution service errors to HTTP responses
+func respondInstitutionError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound),
+		errors.Is(err, services.ErrInstitutionNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Institution not found"})
+	case errors.Is(err, services.ErrDuplicateInstitution),
+		errors.Is(err, services.ErrInstitutionInUse):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetAllInstitutions handles GET requests to list institutions, optionally filtered by name
+func (c *InstitutionController) GetAllInstitutions(ctx *gin.Context) {
+	institutions, err := c.service.GetAllInstitutions(ctx.Query("name"))
+	if err != nil {
+		respondInstitutionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, institutions)
+}
+
+// GetInstitutionByID handles GET requests to retrieve an institution with its contact people
+func (c *InstitutionController) GetInstitutionByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid institution ID"})
+		return
+	}
+
+	institution, err := c.service.GetInstitutionByID(id)
+	if err != nil {
+		respondInstitutionError(ctx, err)
+		return
+	}
+	respondMaskedJSON(ctx, http.StatusOK, institution)
+}
+
+// GetInstitutionContacts handles GET requests to list the requesters linked to an institution
+func (c *InstitutionController) GetInstitutionContacts(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid institution ID"})
+		return
+	}
+
+	contacts, err := c.service.GetInstitutionContacts(id)
+	if err != nil {
+		respondInstitutionError(ctx, err)
+		return
+	}
+	respondMaskedJSON(ctx, http.StatusOK, contacts)
+}
+
+// CreateInstitution handles POST requests to create a new institution
+func (c *InstitutionController) CreateInstitution(ctx *gin.Context) {
+	var institution models.InstitutionModel
+	if err := ctx.ShouldBindJSON(&institution); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(institution.Name) == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	created, err := c.service.CreateInstitution(&institution)
+	if err != nil {
+		respondInstitutionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, created)
+}
+
+// UpdateInstitution handles PUT requests to update an existing institution
+func (c *InstitutionController) UpdateInstitution(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid institution ID"})
+		return
+	}
+
+	var institution models.InstitutionModel
+	if err := ctx.ShouldBindJSON(&institution); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := c.service.UpdateInstitution(id, &institution)
+	if err != nil {
+		respondInstitutionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, updated)
+}
+
+// DeleteInstitution handles DELETE requests to remove an institution without loans
+func (c *InstitutionController) DeleteInstitution(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid institution ID"})
+		return
+	}
+
+	if err := c.service.DeleteInstitution(id); err != nil {
+		respondInstitutionError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
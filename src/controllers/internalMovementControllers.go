@@ -1,14 +1,39 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
 	"github.com/ARQAP/ARQAP-Backend/src/models"
 	"github.com/ARQAP/ARQAP-Backend/src/services"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// respondInternalMovementError maps internal movement service errors to HTTP responses
+func respondInternalMovementError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Internal movement not found"})
+	case errors.Is(err, services.ErrMovementAlreadyClosed),
+		errors.Is(err, services.ErrMovementVoided),
+		errors.Is(err, services.ErrMovementHasLaterMovements),
+		errors.Is(err, services.ErrMovementDestinationLocked),
+		errors.Is(err, services.ErrMovementGroupCancelled),
+		errors.Is(err, services.ErrMovementGroupClosed):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	// La pieza no está disponible, no existe o los datos son inválidos: 400 Bad Request
+	case errors.Is(err, services.ErrArtefactNotAvailableForMovement),
+		errors.Is(err, services.ErrArtefactNotFound),
+		errors.Is(err, services.ErrInvalidMovementReturnDate):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
 type InternalMovementController struct {
 	service *services.InternalMovementService
 }
@@ -17,14 +42,15 @@ func NewInternalMovementController(service *services.InternalMovementService) *I
 	return &InternalMovementController{service: service}
 }
 
-// GetAllInternalMovements handles GET requests to retrieve all internal movement records
+// GetAllInternalMovements handles GET requests to retrieve all internal movement records.
+// Voided movements are left out unless includeVoided=true.
 func (c *InternalMovementController) GetAllInternalMovements(ctx *gin.Context) {
-	movements, err := c.service.GetAllInternalMovements()
+	movements, err := c.service.GetAllInternalMovements(ctx.Query("includeVoided") == "true")
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, movements)
+	respondMaskedJSON(ctx, http.StatusOK, movements)
 }
 
 // GetInternalMovementByID handles GET requests to retrieve an internal movement by its ID
@@ -53,12 +79,12 @@ func (c *InternalMovementController) GetInternalMovementsByArtefactID(ctx *gin.C
 		return
 	}
 
-	movements, err := c.service.GetInternalMovementsByArtefactID(artefactId)
+	movements, err := c.service.GetInternalMovementsByArtefactID(artefactId, ctx.Query("includeVoided") == "true")
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, movements)
+	respondMaskedJSON(ctx, http.StatusOK, movements)
 }
 
 // GetActiveInternalMovementByArtefactID handles GET requests to retrieve the active movement for a specific artefact
@@ -89,12 +115,7 @@ func (c *InternalMovementController) CreateInternalMovement(ctx *gin.Context) {
 
 	createdMovement, err := c.service.CreateInternalMovement(&movement)
 	if err != nil {
-		// Si el error indica que la pieza no está disponible, devolver 400 Bad Request
-		if err.Error() == "la pieza arqueológica no está disponible para movimientos internos (ya está prestada)" {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondInternalMovementError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusCreated, createdMovement)
@@ -114,14 +135,9 @@ func (c *InternalMovementController) CreateBatchInternalMovements(ctx *gin.Conte
 		movementPointers[i] = &movements[i]
 	}
 
-	createdMovements, err := c.service.CreateBatchInternalMovements(movementPointers)
+	createdMovements, err := c.service.CreateBatchInternalMovements(movementPointers, currentUserIDPtr(ctx))
 	if err != nil {
-		// Si el error indica que la pieza no está disponible, devolver 400 Bad Request
-		if err.Error() == "la pieza arqueológica no está disponible para movimientos internos (ya está prestada)" {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondInternalMovementError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusCreated, createdMovements)
@@ -144,13 +160,60 @@ func (c *InternalMovementController) UpdateInternalMovement(ctx *gin.Context) {
 
 	updatedMovement, err := c.service.UpdateInternalMovement(id, &movement)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondInternalMovementError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, updatedMovement)
 }
 
-// DeleteInternalMovement handles DELETE requests to remove an internal movement record by its ID
+// ReturnInternalMovement handles POST requests to return the piece of an active movement to its origin location
+func (c *InternalMovementController) ReturnInternalMovement(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movement ID"})
+		return
+	}
+
+	var dto dtos.ReturnInternalMovementDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	movement, err := c.service.ReturnInternalMovement(id, &dto)
+	if err != nil {
+		respondInternalMovementError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, movement)
+}
+
+// VoidInternalMovement handles POST requests to void a movement, restoring the piece to its previous location
+func (c *InternalMovementController) VoidInternalMovement(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movement ID"})
+		return
+	}
+
+	var dto dtos.VoidInternalMovementDTO
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&dto); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	movement, err := c.service.VoidInternalMovement(id, &dto, currentUserIDPtr(ctx))
+	if err != nil {
+		respondInternalMovementError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, movement)
+}
+
+// DeleteInternalMovement handles DELETE requests to remove an internal movement record by its ID.
+// The movement is voided rather than deleted, so it stays in the history.
 func (c *InternalMovementController) DeleteInternalMovement(ctx *gin.Context) {
 	idParam := ctx.Param("id")
 	id, err := strconv.Atoi(idParam)
@@ -159,8 +222,8 @@ func (c *InternalMovementController) DeleteInternalMovement(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.service.DeleteInternalMovement(id); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.DeleteInternalMovement(id, currentUserIDPtr(ctx)); err != nil {
+		respondInternalMovementError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusNoContent, nil)
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type LabelController struct {
+	service *services.LabelService
+}
+
+func NewLabelController(service *services.LabelService) *LabelController {
+	return &LabelController{service: service}
+}
+
+// respondLabelError maps label service errors to HTTP responses
+func respondLabelError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+	case errors.Is(err, services.ErrUnknownLabel):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmptyLabelSelection),
+		errors.Is(err, services.ErrArtefactNotFound),
+		errors.Is(err, services.ErrPhysicalLocationNotFound),
+		errors.Is(err, services.ErrShelfNotFound):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// qrSize reads the size query param (pixels), answering 400 when it's invalid
+func qrSize(ctx *gin.Context) (int, bool) {
+	v := ctx.Query("size")
+	if v == "" {
+		return services.DefaultQRSize, true
+	}
+	size, err := strconv.Atoi(v)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid size"})
+		return 0, false
+	}
+	return size, true
+}
+
+// GetArtefactQR handles GET requests to render the QR code of an artefact (PNG, optional ?size= in pixels)
+func (c *LabelController) GetArtefactQR(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid artefact ID"})
+		return
+	}
+	size, ok := qrSize(ctx)
+	if !ok {
+		return
+	}
+
+	png, err := c.service.GetArtefactQR(id, size)
+	if err != nil {
+		respondLabelError(ctx, err)
+		return
+	}
+	ctx.Data(http.StatusOK, "image/png", png)
+}
+
+// GetLocationQR handles GET requests to render the QR code of a shelf position (PNG, optional ?size= in pixels)
+func (c *LabelController) GetLocationQR(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid physical location ID"})
+		return
+	}
+	size, ok := qrSize(ctx)
+	if !ok {
+		return
+	}
+
+	png, err := c.service.GetLocationQR(id, size)
+	if err != nil {
+		respondLabelError(ctx, err)
+		return
+	}
+	ctx.Data(http.StatusOK, "image/png", png)
+}
+
+// GenerateLabelSheet handles POST requests to render a printable PDF sheet with the labels of a selection
+func (c *LabelController) GenerateLabelSheet(ctx *gin.Context) {
+	var dto dtos.LabelSheetDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pdf, err := c.service.GenerateLabelSheet(&dto)
+	if err != nil {
+		respondLabelError(ctx, err)
+		return
+	}
+	ctx.Header("Content-Disposition", `inline; filename="etiquetas.pdf"`)
+	ctx.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// ResolveLabel handles GET requests to identify a scanned code (?code=): a piece or a shelf position
+func (c *LabelController) ResolveLabel(ctx *gin.Context) {
+	code := ctx.Query("code")
+	if code == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing code"})
+		return
+	}
+
+	resolved, err := c.service.ResolveLabel(code)
+	if err != nil {
+		respondLabelError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, resolved)
+}
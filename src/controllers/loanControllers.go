@@ -1,14 +1,49 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
 	"github.com/ARQAP/ARQAP-Backend/src/models"
 	"github.com/ARQAP/ARQAP-Backend/src/services"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// respondLoanError maps loan service errors to HTTP responses
+func respondLoanError(ctx *gin.Context, err error) {
+	var conflict *services.ReservationConflictError
+	var notEligible *services.RequesterNotEligibleError
+	switch {
+	case errors.As(err, &conflict):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "conflicts": conflict.Conflicts})
+	case errors.As(err, &notEligible):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "reasons": notEligible.Reasons})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Loan not found"})
+	// La pieza no está disponible, no existe o los datos son inválidos: 400 Bad Request
+	case errors.Is(err, services.ErrArtefactNotAvailable),
+		errors.Is(err, services.ErrArtefactNotFound),
+		errors.Is(err, services.ErrInstitutionNotFound),
+		errors.Is(err, services.ErrDuplicateLoanLine),
+		errors.Is(err, services.ErrLoanLineNotInHeader),
+		errors.Is(err, services.ErrInvalidDueDate),
+		errors.Is(err, services.ErrInvalidReturnDate),
+		errors.Is(err, services.ErrLoanArtefactChange):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrLoanAlreadyReturned),
+		errors.Is(err, services.ErrArtefactReserved),
+		errors.Is(err, services.ErrLoanHeaderFromRequest):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
 type LoanController struct {
 	service *services.LoanService
 }
@@ -17,14 +52,86 @@ func NewLoanController(service *services.LoanService) *LoanController {
 	return &LoanController{service: service}
 }
 
-// GetAllLoans handles GET requests to retrieve all loan records
+// GetAllLoans handles GET requests to retrieve loan records.
+// Optional filters: status (active, due_soon, overdue, returned), requesterId, institutionId, from, to (YYYY-MM-DD, loan date)
 func (c *LoanController) GetAllLoans(ctx *gin.Context) {
-	loans, err := c.service.GetAllLoans()									
+	filter := dtos.LoanFilterDTO{Status: ctx.Query("status")}
+
+	if v := ctx.Query("requesterId"); v != "" {
+		requesterId, err := strconv.Atoi(v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid requesterId"})
+			return
+		}
+		filter.RequesterId = &requesterId
+	}
+	if v := ctx.Query("institutionId"); v != "" {
+		institutionId, err := strconv.Atoi(v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid institutionId"})
+			return
+		}
+		filter.InstitutionId = &institutionId
+	}
+	if v := ctx.Query("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		filter.From = &from
+	}
+	if v := ctx.Query("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		// Incluir el día completo
+		to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		filter.To = &to
+	}
+
+	loans, err := c.service.GetAllLoans(&filter)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidLoanStatus) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, loans)
+	respondMaskedJSON(ctx, http.StatusOK, loans)
+}
+
+// CheckAvailabilityConsistency handles GET requests to list artefacts whose availability disagrees with their loans
+func (c *LoanController) CheckAvailabilityConsistency(ctx *gin.Context) {
+	mismatches, err := c.service.CheckAvailabilityConsistency()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, mismatches)
+}
+
+// RepairAvailability handles POST requests to recompute the availability of inconsistent artefacts
+func (c *LoanController) RepairAvailability(ctx *gin.Context) {
+	fixed, err := c.service.RepairAvailability()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"fixed": fixed})
+}
+
+// GetOverdueLoans handles GET requests to list the loans past their due date
+func (c *LoanController) GetOverdueLoans(ctx *gin.Context) {
+	loans, err := c.service.GetOverdueLoans()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondMaskedJSON(ctx, http.StatusOK, loans)
 }
 
 // GetLoanByID handles GET requests to retrieve a loan by its ID
@@ -54,12 +161,7 @@ func (c *LoanController) CreateLoan(ctx *gin.Context) {
 	
 	createdLoan, err := c.service.CreateLoan(&loan)
 	if err != nil {
-		// Si el error indica que la pieza no está disponible, devolver 400 Bad Request
-		if err.Error() == "la pieza arqueológica no está disponible para préstamo (ya está prestada)" {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondLoanError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusCreated, createdLoan)
@@ -82,12 +184,44 @@ func (c *LoanController) UpdateLoan(ctx *gin.Context) {
 
 	updatedLoan, err := c.service.UpdateLoan(id, &loan)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondLoanError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, updatedLoan)
 }
 
+// ReturnLoan handles POST requests to register the return of a loaned piece
+func (c *LoanController) ReturnLoan(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		return
+	}
+
+	var dto dtos.ReturnLoanDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	setReceivingUser(ctx, &dto)
+
+	loan, err := c.service.ReturnLoan(id, &dto)
+	if err != nil {
+		respondLoanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, loan)
+}
+
+// setReceivingUser defaults the user who received a return to the authenticated one
+func setReceivingUser(ctx *gin.Context, dto *dtos.ReturnLoanDTO) {
+	if dto.ReceivedById == nil {
+		if userId, ok := middleware.CurrentUserID(ctx); ok {
+			dto.ReceivedById = &userId
+		}
+	}
+}
+
 // DeleteLoan handles DELETE requests to remove a loan record by its ID
 func (c *LoanController) DeleteLoan(ctx *gin.Context) {
 	idParam := ctx.Param("id")
@@ -102,4 +236,128 @@ func (c *LoanController) DeleteLoan(ctx *gin.Context) {
 		return
 	}
 	ctx.JSON(http.StatusNoContent, nil)
-}
\ No newline at end of file
+}
+
+// GetAllLoanHeaders handles GET requests to retrieve all multi-artefact loans with their lines
+func (c *LoanController) GetAllLoanHeaders(ctx *gin.Context) {
+	headers, err := c.service.GetAllLoanHeaders()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondMaskedJSON(ctx, http.StatusOK, headers)
+}
+
+// GetLoanHeaderByID handles GET requests to retrieve a multi-artefact loan by its ID
+func (c *LoanController) GetLoanHeaderByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan header ID"})
+		return
+	}
+
+	header, err := c.service.GetLoanHeaderByID(id)
+	if err != nil {
+		respondLoanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, header)
+}
+
+// CreateLoanHeader handles POST requests to lend several artefacts under one loan
+func (c *LoanController) CreateLoanHeader(ctx *gin.Context) {
+	var dto dtos.CreateLoanHeaderDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	header, err := c.service.CreateLoanHeader(&dto)
+	if err != nil {
+		respondLoanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, header)
+}
+
+// AddLoanLines handles POST requests to add artefacts to a multi-artefact loan
+func (c *LoanController) AddLoanLines(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan header ID"})
+		return
+	}
+
+	var dto dtos.AddLoanLinesDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	header, err := c.service.AddLoanLines(id, &dto)
+	if err != nil {
+		respondLoanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, header)
+}
+
+// UpdateLoanHeader handles PUT requests to update a multi-artefact loan
+func (c *LoanController) UpdateLoanHeader(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan header ID"})
+		return
+	}
+
+	var dto dtos.UpdateLoanHeaderDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	header, err := c.service.UpdateLoanHeader(id, &dto)
+	if err != nil {
+		respondLoanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, header)
+}
+
+// ReturnLoanLines handles POST requests to return some or all artefacts of a multi-artefact loan
+func (c *LoanController) ReturnLoanLines(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan header ID"})
+		return
+	}
+
+	var dto dtos.ReturnLoanLinesDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	setReceivingUser(ctx, &dto.ReturnLoanDTO)
+
+	header, err := c.service.ReturnLoanLines(id, &dto)
+	if err != nil {
+		respondLoanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, header)
+}
+
+// DeleteLoanHeader handles DELETE requests to remove a multi-artefact loan with all its lines
+func (c *LoanController) DeleteLoanHeader(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan header ID"})
+		return
+	}
+
+	if err := c.service.DeleteLoanHeader(id); err != nil {
+		respondLoanError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusNoContent, nil)
+}
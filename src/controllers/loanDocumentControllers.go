@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type LoanDocumentController struct {
+	service *services.LoanDocumentService
+}
+
+func NewLoanDocumentController(service *services.LoanDocumentService) *LoanDocumentController {
+	return &LoanDocumentController{service: service}
+}
+
+// respondLoanDocumentError maps loan document service errors to HTTP responses
+func respondLoanDocumentError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Loan or document not found"})
+	case errors.Is(err, services.ErrLoanHasNoArtefact):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetLoanDocuments handles GET requests to list the documents generated for a loan
+func (c *LoanDocumentController) GetLoanDocuments(ctx *gin.Context) {
+	loanId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		return
+	}
+
+	documents, err := c.service.GetLoanDocuments(loanId)
+	if err != nil {
+		respondLoanDocumentError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, documents)
+}
+
+// DownloadLoanDocument handles GET requests to download a generated loan document
+func (c *LoanDocumentController) DownloadLoanDocument(ctx *gin.Context) {
+	loanId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		return
+	}
+	documentId, err := strconv.Atoi(ctx.Param("documentId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+
+	document, err := c.service.GetLoanDocument(loanId, documentId)
+	if err != nil {
+		respondLoanDocumentError(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", document.Filename))
+	etag := fmt.Sprintf(`"%s"`, document.Sha256)
+	serveArtefactFile(ctx, c.service.Storage(), document.FilePath, "application/pdf", etag, "private, max-age=86400")
+}
+
+// GenerateLoanAgreement handles POST requests to generate the loan agreement PDF
+func (c *LoanDocumentController) GenerateLoanAgreement(ctx *gin.Context) {
+	loanId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		return
+	}
+
+	document, err := c.service.GenerateLoanAgreement(loanId, currentUserIDPtr(ctx))
+	if err != nil {
+		respondLoanDocumentError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, document)
+}
+
+// GenerateConditionReport handles POST requests to generate a condition report PDF (dispatch or return)
+func (c *LoanDocumentController) GenerateConditionReport(ctx *gin.Context) {
+	loanId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		return
+	}
+
+	var dto dtos.ConditionReportDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	document, err := c.service.GenerateConditionReport(loanId, &dto, currentUserIDPtr(ctx))
+	if err != nil {
+		respondLoanDocumentError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, document)
+}
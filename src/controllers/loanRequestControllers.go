@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type LoanRequestController struct {
+	service *services.LoanRequestService
+}
+
+func NewLoanRequestController(service *services.LoanRequestService) *LoanRequestController {
+	return &LoanRequestController{service: service}
+}
+
+// respondLoanRequestError maps loan request service errors to HTTP responses
+func respondLoanRequestError(ctx *gin.Context, err error) {
+	var conflict *services.ReservationConflictError
+	var notEligible *services.RequesterNotEligibleError
+	switch {
+	case errors.As(err, &conflict):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "conflicts": conflict.Conflicts})
+	case errors.As(err, &notEligible):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "reasons": notEligible.Reasons})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Loan request not found"})
+	case errors.Is(err, services.ErrLoanRequestForbidden):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrLoanRequestTransition),
+		errors.Is(err, services.ErrLoanRequestNotEditable),
+		errors.Is(err, services.ErrArtefactReserved),
+		errors.Is(err, services.ErrArtefactNotAvailable):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrLoanRequestCommentRequired),
+		errors.Is(err, services.ErrInvalidRequestedPeriod),
+		errors.Is(err, services.ErrInvalidDueDate),
+		errors.Is(err, services.ErrDuplicateLoanLine),
+		errors.Is(err, services.ErrArtefactNotFound),
+		errors.Is(err, services.ErrInstitutionNotFound):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// currentUserIDPtr returns the authenticated user ID, or nil when the token has none
+func currentUserIDPtr(ctx *gin.Context) *int {
+	if userId, ok := middleware.CurrentUserID(ctx); ok {
+		return &userId
+	}
+	return nil
+}
+
+// loanRequestID parses the :id param, answering 400 when it's invalid
+func loanRequestID(ctx *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan request ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// bindLoanRequestComment reads the optional comment of a transition (an empty body is allowed)
+func bindLoanRequestComment(ctx *gin.Context) (*dtos.LoanRequestCommentDTO, bool) {
+	var dto dtos.LoanRequestCommentDTO
+	if ctx.Request.ContentLength == 0 {
+		return &dto, true
+	}
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &dto, true
+}
+
+// GetAllLoanRequests handles GET requests to list loan requests (optional ?status=)
+func (c *LoanRequestController) GetAllLoanRequests(ctx *gin.Context) {
+	requests, err := c.service.GetAllLoanRequests(ctx.Query("status"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondMaskedJSON(ctx, http.StatusOK, requests)
+}
+
+// GetLoanRequestByID handles GET requests to retrieve a loan request with its history
+func (c *LoanRequestController) GetLoanRequestByID(ctx *gin.Context) {
+	id, ok := loanRequestID(ctx)
+	if !ok {
+		return
+	}
+
+	request, err := c.service.GetLoanRequestByID(id)
+	if err != nil {
+		respondLoanRequestError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, request)
+}
+
+// CreateLoanRequest handles POST requests to draft a loan request
+func (c *LoanRequestController) CreateLoanRequest(ctx *gin.Context) {
+	var dto dtos.CreateLoanRequestDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := c.service.CreateLoanRequest(&dto, currentUserIDPtr(ctx))
+	if err != nil {
+		respondLoanRequestError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, request)
+}
+
+// UpdateLoanRequest handles PUT requests to edit a draft loan request
+func (c *LoanRequestController) UpdateLoanRequest(ctx *gin.Context) {
+	id, ok := loanRequestID(ctx)
+	if !ok {
+		return
+	}
+
+	var dto dtos.UpdateLoanRequestDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := c.service.UpdateLoanRequest(id, &dto)
+	if err != nil {
+		respondLoanRequestError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, request)
+}
+
+// SubmitLoanRequest handles POST requests to send a draft for review
+func (c *LoanRequestController) SubmitLoanRequest(ctx *gin.Context) {
+	id, ok := loanRequestID(ctx)
+	if !ok {
+		return
+	}
+	dto, ok := bindLoanRequestComment(ctx)
+	if !ok {
+		return
+	}
+
+	request, err := c.service.SubmitLoanRequest(id, dto.Comment, currentUserIDPtr(ctx))
+	if err != nil {
+		respondLoanRequestError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, request)
+}
+
+// ApproveLoanRequest handles POST requests to approve a submitted request
+func (c *LoanRequestController) ApproveLoanRequest(ctx *gin.Context) {
+	id, ok := loanRequestID(ctx)
+	if !ok {
+		return
+	}
+	dto, ok := bindLoanRequestComment(ctx)
+	if !ok {
+		return
+	}
+
+	request, err := c.service.ApproveLoanRequest(id, dto.Comment, currentUserIDPtr(ctx))
+	if err != nil {
+		respondLoanRequestError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, request)
+}
+
+// RejectLoanRequest handles POST requests to reject a submitted request (comment required)
+func (c *LoanRequestController) RejectLoanRequest(ctx *gin.Context) {
+	id, ok := loanRequestID(ctx)
+	if !ok {
+		return
+	}
+	dto, ok := bindLoanRequestComment(ctx)
+	if !ok {
+		return
+	}
+
+	request, err := c.service.RejectLoanRequest(id, dto.Comment, currentUserIDPtr(ctx))
+	if err != nil {
+		respondLoanRequestError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, request)
+}
+
+// CancelLoanRequest handles POST requests to cancel a request not dispatched yet
+func (c *LoanRequestController) CancelLoanRequest(ctx *gin.Context) {
+	id, ok := loanRequestID(ctx)
+	if !ok {
+		return
+	}
+	dto, ok := bindLoanRequestComment(ctx)
+	if !ok {
+		return
+	}
+
+	canReview := middleware.HasRole(ctx, models.RoleAdmin, models.RoleRegistrar)
+	request, err := c.service.CancelLoanRequest(id, dto.Comment, currentUserIDPtr(ctx), canReview)
+	if err != nil {
+		respondLoanRequestError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, request)
+}
+
+// DispatchLoanRequest handles POST requests to turn an approved request into a loan
+func (c *LoanRequestController) DispatchLoanRequest(ctx *gin.Context) {
+	id, ok := loanRequestID(ctx)
+	if !ok {
+		return
+	}
+
+	var dto dtos.DispatchLoanRequestDTO
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&dto); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	request, err := c.service.DispatchLoanRequest(id, &dto, currentUserIDPtr(ctx))
+	if err != nil {
+		respondLoanRequestError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, request)
+}
+
+// AddLoanRequestComment handles POST requests to comment on a request without changing its status
+func (c *LoanRequestController) AddLoanRequestComment(ctx *gin.Context) {
+	id, ok := loanRequestID(ctx)
+	if !ok {
+		return
+	}
+	dto, ok := bindLoanRequestComment(ctx)
+	if !ok {
+		return
+	}
+	if dto.Comment == nil || *dto.Comment == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Comment is required"})
+		return
+	}
+
+	request, err := c.service.AddLoanRequestComment(id, *dto.Comment, currentUserIDPtr(ctx))
+	if err != nil {
+		respondLoanRequestError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, request)
+}
+
+// DeleteLoanRequest handles DELETE requests to remove a draft or cancelled request
+func (c *LoanRequestController) DeleteLoanRequest(ctx *gin.Context) {
+	id, ok := loanRequestID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.service.DeleteLoanRequest(id); err != nil {
+		respondLoanRequestError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type LocationHistoryController struct {
+	service *services.LocationHistoryService
+}
+
+func NewLocationHistoryController(service *services.LocationHistoryService) *LocationHistoryController {
+	return &LocationHistoryController{service: service}
+}
+
+// parseAtDate reads the at query param (YYYY-MM-DD, today when missing), answering 400 when it's invalid
+func parseAtDate(ctx *gin.Context) (time.Time, bool) {
+	v := ctx.Query("at")
+	if v == "" {
+		return time.Now(), true
+	}
+	at, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid at date, expected YYYY-MM-DD"})
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// GetLocationHistory handles GET requests to retrieve the location timeline of an artefact
+func (c *LocationHistoryController) GetLocationHistory(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid artefact ID"})
+		return
+	}
+
+	history, err := c.service.GetLocationHistory(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Artefact not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, history)
+}
+
+// GetArtefactLocationAt handles GET requests to know where an artefact was on a date (?at=YYYY-MM-DD)
+func (c *LocationHistoryController) GetArtefactLocationAt(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid artefact ID"})
+		return
+	}
+	at, ok := parseAtDate(ctx)
+	if !ok {
+		return
+	}
+
+	location, err := c.service.GetArtefactLocationAt(id, at)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Artefact not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, location)
+}
+
+// GetShelfContentsAt handles GET requests to list what was stored on a shelf on a date (?at=YYYY-MM-DD)
+func (c *LocationHistoryController) GetShelfContentsAt(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shelf ID"})
+		return
+	}
+	at, ok := parseAtDate(ctx)
+	if !ok {
+		return
+	}
+
+	contents, err := c.service.GetShelfContentsAt(id, at)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Shelf not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, contents)
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/gin-gonic/gin"
+)
+
+// movementGroupID parses the :id param of a movement group, answering 400 when it's invalid
+func movementGroupID(ctx *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movement group ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// GetMovementGroupByID handles GET requests to retrieve a movement group with its movements
+func (c *InternalMovementController) GetMovementGroupByID(ctx *gin.Context) {
+	id, ok := movementGroupID(ctx)
+	if !ok {
+		return
+	}
+
+	group, err := c.service.GetMovementGroupByID(id)
+	if err != nil {
+		respondInternalMovementError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, group)
+}
+
+// CreateMovementGroup handles POST requests to move several artefacts together
+func (c *InternalMovementController) CreateMovementGroup(ctx *gin.Context) {
+	var dto dtos.CreateMovementGroupDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, err := c.service.CreateMovementGroup(&dto, currentUserIDPtr(ctx))
+	if err != nil {
+		respondInternalMovementError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, group)
+}
+
+// ReturnMovementGroup handles POST requests to return every active movement of a group to its origin
+func (c *InternalMovementController) ReturnMovementGroup(ctx *gin.Context) {
+	id, ok := movementGroupID(ctx)
+	if !ok {
+		return
+	}
+
+	var dto dtos.ReturnInternalMovementDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, err := c.service.ReturnMovementGroup(id, &dto)
+	if err != nil {
+		respondInternalMovementError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, group)
+}
+
+// CancelMovementGroup handles POST requests to void every movement of a group
+func (c *InternalMovementController) CancelMovementGroup(ctx *gin.Context) {
+	id, ok := movementGroupID(ctx)
+	if !ok {
+		return
+	}
+
+	var dto dtos.CancelMovementGroupDTO
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&dto); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	group, err := c.service.CancelMovementGroup(id, &dto, currentUserIDPtr(ctx))
+	if err != nil {
+		respondInternalMovementError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, group)
+}
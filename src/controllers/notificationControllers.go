@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type NotificationController struct {
+	service *services.NotificationService
+}
+
+func NewNotificationController(service *services.NotificationService) *NotificationController {
+	return &NotificationController{service: service}
+}
+
+// GetNotificationLog handles GET requests to list the reminder emails sent, filtered by loanId and status
+func (c *NotificationController) GetNotificationLog(ctx *gin.Context) {
+	var loanId *int
+	if v := ctx.Query("loanId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loanId"})
+			return
+		}
+		loanId = &id
+	}
+
+	entries, err := c.service.GetNotificationLog(loanId, ctx.Query("status"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
+
+// RunLoanReminders handles POST requests to send the pending loan reminders right away
+func (c *NotificationController) RunLoanReminders(ctx *gin.Context) {
+	report, err := c.service.RunLoanReminders()
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRemindersRunning):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrMailerNotConfigured):
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// SetLoanNotifications handles PUT requests to turn the reminder emails of a loan on or off
+func (c *NotificationController) SetLoanNotifications(ctx *gin.Context) {
+	loanId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		return
+	}
+
+	var dto dtos.LoanNotificationsDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loan, err := c.service.SetLoanNotificationsOptOut(loanId, *dto.OptOut)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Loan not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, loan)
+}
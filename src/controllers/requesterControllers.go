@@ -1,12 +1,14 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/ARQAP/ARQAP-Backend/src/models"
 	"github.com/ARQAP/ARQAP-Backend/src/services"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type RequesterController struct {
@@ -24,7 +26,7 @@ func (c *RequesterController) GetAllRequesters(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, requesters)
+	respondMaskedJSON(ctx, http.StatusOK, requesters)
 }
 
 // GetRequesterByID handles GET requests to retrieve a requester by its ID
@@ -68,6 +70,10 @@ func (c *RequesterController) CreateRequester(ctx *gin.Context) {
 
 	createdRequester, err := c.service.CreateRequester(&requester)
 	if err != nil {
+		if errors.Is(err, services.ErrInstitutionNotFound) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -91,6 +97,14 @@ func (c *RequesterController) UpdateRequester(ctx *gin.Context) {
 
 	updatedRequester, err := c.service.UpdateRequester(id, &requester)
 	if err != nil {
+		if errors.Is(err, services.ErrInstitutionNotFound) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrRequesterAnonymized) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -111,3 +125,24 @@ func (c *RequesterController) DeleteRequester(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusNoContent, nil)
 }
+
+// GetRequesterHistory handles GET requests to review the loans and movements of a requester
+// and whether they can receive new loans
+func (c *RequesterController) GetRequesterHistory(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid requester ID"})
+		return
+	}
+
+	history, err := c.service.GetRequesterHistory(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Requester not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, history)
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/ARQAP/ARQAP-Backend/src/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// personalDataRoles can see the full DNI and phone number of requesters in list responses
+var personalDataRoles = []string{models.RoleAdmin, models.RoleRegistrar}
+
+var requesterModelType = reflect.TypeOf(models.RequesterModel{})
+
+// respondMaskedJSON writes a list response, masking the DNI and phone number of every requester
+// in it (loans, requests, reservations, movements...) unless the user has a privileged role
+func respondMaskedJSON(ctx *gin.Context, status int, body interface{}) {
+	if !middleware.HasRole(ctx, personalDataRoles...) {
+		maskPersonalData(reflect.ValueOf(body))
+	}
+	ctx.JSON(status, body)
+}
+
+// maskPersonalData walks pointers, slices and struct fields looking for requesters to mask.
+// Values must be addressable (pointers or slices), which is what the services return.
+func maskPersonalData(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			maskPersonalData(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			maskPersonalData(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type() == requesterModelType {
+			if v.CanAddr() {
+				requester := v.Addr().Interface().(*models.RequesterModel)
+				requester.Dni = utils.MaskTail(requester.Dni, 3)
+				requester.PhoneNumber = utils.MaskTail(requester.PhoneNumber, 4)
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				maskPersonalData(v.Field(i))
+			}
+		}
+	}
+}
+
+type RequesterPrivacyController struct {
+	service *services.RequesterPrivacyService
+}
+
+func NewRequesterPrivacyController(service *services.RequesterPrivacyService) *RequesterPrivacyController {
+	return &RequesterPrivacyController{service: service}
+}
+
+// respondRequesterPrivacyError maps retention and anonymization errors to HTTP responses
+func respondRequesterPrivacyError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Requester not found"})
+	case errors.Is(err, services.ErrRequesterInUse),
+		errors.Is(err, services.ErrRequesterAnonymized):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRetentionDisabled):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ExportRequesterData handles GET requests to download everything stored about a requester as JSON
+func (c *RequesterPrivacyController) ExportRequesterData(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid requester ID"})
+		return
+	}
+
+	export, err := c.service.ExportRequesterData(id)
+	if err != nil {
+		respondRequesterPrivacyError(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="solicitante_%d_datos.json"`, id))
+	ctx.IndentedJSON(http.StatusOK, export)
+}
+
+// AnonymizeRequester handles POST requests to erase the personal data of a requester, keeping its loan history
+func (c *RequesterPrivacyController) AnonymizeRequester(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid requester ID"})
+		return
+	}
+
+	requester, err := c.service.AnonymizeRequester(id)
+	if err != nil {
+		respondRequesterPrivacyError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, requester)
+}
+
+// GetRetentionCandidates handles GET requests to preview the requesters the retention job would anonymize
+func (c *RequesterPrivacyController) GetRetentionCandidates(ctx *gin.Context) {
+	candidates, err := c.service.GetRetentionCandidates()
+	if err != nil {
+		respondRequesterPrivacyError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, candidates)
+}
+
+// RunRetention handles POST requests to anonymize the requesters past the retention period right away
+func (c *RequesterPrivacyController) RunRetention(ctx *gin.Context) {
+	report, err := c.service.RunRetention()
+	if err != nil {
+		respondRequesterPrivacyError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReservationController struct {
+	service *services.ReservationService
+}
+
+func NewReservationController(service *services.ReservationService) *ReservationController {
+	return &ReservationController{service: service}
+}
+
+// respondReservationError maps reservation service errors to HTTP responses.
+// Conflicts include the busy periods that overlap the requested one.
+func respondReservationError(ctx *gin.Context, err error) {
+	var conflict *services.ReservationConflictError
+	switch {
+	case errors.As(err, &conflict):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "conflicts": conflict.Conflicts})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+	case errors.Is(err, services.ErrReservationNotActive),
+		errors.Is(err, services.ErrReservationFromLoanRequest):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidReservationPeriod),
+		errors.Is(err, services.ErrArtefactNotFound):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetAllReservations handles GET requests to list reservations (optional ?artefactId= and ?status=)
+func (c *ReservationController) GetAllReservations(ctx *gin.Context) {
+	var artefactId *int
+	if v := ctx.Query("artefactId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid artefactId"})
+			return
+		}
+		artefactId = &id
+	}
+
+	reservations, err := c.service.GetAllReservations(artefactId, ctx.Query("status"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondMaskedJSON(ctx, http.StatusOK, reservations)
+}
+
+// GetReservationByID handles GET requests to retrieve a reservation by its ID
+func (c *ReservationController) GetReservationByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reservation ID"})
+		return
+	}
+
+	reservation, err := c.service.GetReservationByID(id)
+	if err != nil {
+		respondReservationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, reservation)
+}
+
+// CreateReservation handles POST requests to book an artefact for a period
+func (c *ReservationController) CreateReservation(ctx *gin.Context) {
+	var dto dtos.CreateReservationDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reservation, err := c.service.CreateReservation(&dto, currentUserIDPtr(ctx))
+	if err != nil {
+		respondReservationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, reservation)
+}
+
+// UpdateReservation handles PUT requests to change an active reservation
+func (c *ReservationController) UpdateReservation(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reservation ID"})
+		return
+	}
+
+	var dto dtos.UpdateReservationDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reservation, err := c.service.UpdateReservation(id, &dto)
+	if err != nil {
+		respondReservationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, reservation)
+}
+
+// CancelReservation handles POST requests to cancel an active reservation
+func (c *ReservationController) CancelReservation(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reservation ID"})
+		return
+	}
+
+	reservation, err := c.service.CancelReservation(id)
+	if err != nil {
+		respondReservationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, reservation)
+}
+
+// GetArtefactCalendar handles GET requests to list the busy periods of an artefact
+// (optional ?from= and ?to=, YYYY-MM-DD)
+func (c *ReservationController) GetArtefactCalendar(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid artefact ID"})
+		return
+	}
+
+	var from, to *time.Time
+	if v := ctx.Query("from"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		from = &parsed
+	}
+	if v := ctx.Query("to"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		to = &parsed
+	}
+
+	entries, err := c.service.GetArtefactCalendar(id, from, to)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Artefact not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
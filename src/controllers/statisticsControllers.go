@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+type StatisticsController struct {
+	service *services.StatisticsService
+}
+
+func NewStatisticsController(service *services.StatisticsService) *StatisticsController {
+	return &StatisticsController{service: service}
+}
+
+// parseStatisticsFilter reads the optional from, to (YYYY-MM-DD) and limit query parameters.
+// It writes the error response and returns false when one is invalid.
+func parseStatisticsFilter(ctx *gin.Context) (*dtos.StatisticsFilterDTO, bool) {
+	filter := &dtos.StatisticsFilterDTO{}
+
+	if v := ctx.Query("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return nil, false
+		}
+		filter.From = &from
+	}
+	if v := ctx.Query("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return nil, false
+		}
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "The to date can't be before the from date"})
+		return nil, false
+	}
+	if v := ctx.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return nil, false
+		}
+		filter.Limit = limit
+	}
+	return filter, true
+}
+
+// GetLoansPerMonth handles GET requests to count loans started and returned per month
+func (c *StatisticsController) GetLoansPerMonth(ctx *gin.Context) {
+	filter, ok := parseStatisticsFilter(ctx)
+	if !ok {
+		return
+	}
+
+	rows, err := c.service.GetLoansPerMonth(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, rows)
+}
+
+// GetLoansPerRequesterType handles GET requests to count loans per requester type
+func (c *StatisticsController) GetLoansPerRequesterType(ctx *gin.Context) {
+	filter, ok := parseStatisticsFilter(ctx)
+	if !ok {
+		return
+	}
+
+	rows, err := c.service.GetLoansPerRequesterType(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, rows)
+}
+
+// GetLoanDuration handles GET requests for the average, shortest and longest loan duration
+func (c *StatisticsController) GetLoanDuration(ctx *gin.Context) {
+	filter, ok := parseStatisticsFilter(ctx)
+	if !ok {
+		return
+	}
+
+	duration, err := c.service.GetLoanDuration(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, duration)
+}
+
+// GetMostRequestedArtefacts handles GET requests for the ranking of most lent artefacts
+func (c *StatisticsController) GetMostRequestedArtefacts(ctx *gin.Context) {
+	filter, ok := parseStatisticsFilter(ctx)
+	if !ok {
+		return
+	}
+
+	rows, err := c.service.GetMostRequestedArtefacts(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, rows)
+}
+
+// GetMostRequestedCollections handles GET requests for the ranking of most lent collections
+func (c *StatisticsController) GetMostRequestedCollections(ctx *gin.Context) {
+	filter, ok := parseStatisticsFilter(ctx)
+	if !ok {
+		return
+	}
+
+	rows, err := c.service.GetMostRequestedCollections(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, rows)
+}
+
+// GetMovementsPerShelf handles GET requests to count internal movements into and out of each shelf per month
+func (c *StatisticsController) GetMovementsPerShelf(ctx *gin.Context) {
+	filter, ok := parseStatisticsFilter(ctx)
+	if !ok {
+		return
+	}
+
+	rows, err := c.service.GetMovementsPerShelf(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, rows)
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type StocktakeController struct {
+	service *services.StocktakeService
+}
+
+func NewStocktakeController(service *services.StocktakeService) *StocktakeController {
+	return &StocktakeController{service: service}
+}
+
+// respondStocktakeError maps stocktake service errors to HTTP responses
+func respondStocktakeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Stocktake session not found"})
+	case errors.Is(err, services.ErrStocktakeClosed),
+		errors.Is(err, services.ErrNoStocktakeCorrections):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	// Estante o ubicación inexistente, fuera del alcance, o una pieza que no se puede mover: 400 Bad Request
+	case errors.Is(err, services.ErrShelfNotFound),
+		errors.Is(err, services.ErrPhysicalLocationNotFound),
+		errors.Is(err, services.ErrLocationOutOfStocktakeScope),
+		errors.Is(err, services.ErrNotStocktakeCorrection),
+		errors.Is(err, services.ErrArtefactNotAvailableForMovement),
+		errors.Is(err, services.ErrArtefactNotFound):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// stocktakeID parses the :id param of a stocktake session, answering 400 when it's invalid
+func stocktakeID(ctx *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stocktake ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// GetAllStocktakes handles GET requests to list stocktake sessions (optional ?status=)
+func (c *StocktakeController) GetAllStocktakes(ctx *gin.Context) {
+	sessions, err := c.service.GetAllStocktakes(ctx.Query("status"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+// GetStocktakeByID handles GET requests to retrieve a stocktake session with its scans
+func (c *StocktakeController) GetStocktakeByID(ctx *gin.Context) {
+	id, ok := stocktakeID(ctx)
+	if !ok {
+		return
+	}
+
+	session, err := c.service.GetStocktakeByID(id)
+	if err != nil {
+		respondStocktakeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, session)
+}
+
+// CreateStocktake handles POST requests to open a stocktake session
+func (c *StocktakeController) CreateStocktake(ctx *gin.Context) {
+	var dto dtos.CreateStocktakeDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := c.service.CreateStocktake(&dto, currentUserIDPtr(ctx))
+	if err != nil {
+		respondStocktakeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, session)
+}
+
+// SubmitStocktakeScans handles POST requests to record the codes found at a location
+func (c *StocktakeController) SubmitStocktakeScans(ctx *gin.Context) {
+	id, ok := stocktakeID(ctx)
+	if !ok {
+		return
+	}
+
+	var dto dtos.SubmitStocktakeScansDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scans, err := c.service.SubmitStocktakeScans(id, &dto, currentUserIDPtr(ctx))
+	if err != nil {
+		respondStocktakeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, scans)
+}
+
+// GetStocktakeReport handles GET requests to compare a stocktake session with the database
+func (c *StocktakeController) GetStocktakeReport(ctx *gin.Context) {
+	id, ok := stocktakeID(ctx)
+	if !ok {
+		return
+	}
+
+	report, err := c.service.GetStocktakeReport(id)
+	if err != nil {
+		respondStocktakeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// ApplyStocktakeCorrections handles POST requests to move the out-of-place pieces to where they were found
+func (c *StocktakeController) ApplyStocktakeCorrections(ctx *gin.Context) {
+	id, ok := stocktakeID(ctx)
+	if !ok {
+		return
+	}
+
+	var dto dtos.ApplyStocktakeCorrectionsDTO
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, err := c.service.ApplyStocktakeCorrections(id, &dto, currentUserIDPtr(ctx))
+	if err != nil {
+		respondStocktakeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, group)
+}
+
+// CloseStocktake handles POST requests to finish a stocktake session
+func (c *StocktakeController) CloseStocktake(ctx *gin.Context) {
+	id, ok := stocktakeID(ctx)
+	if !ok {
+		return
+	}
+
+	session, err := c.service.CloseStocktake(id, currentUserIDPtr(ctx))
+	if err != nil {
+		respondStocktakeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, session)
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// RunPreMigrations applies schema changes that AutoMigrate cannot handle by itself
+// (dropping legacy constraints/indexes). It must run before db.AutoMigrate.
+func RunPreMigrations(db *gorm.DB) error {
+	// Pictures and historical records used to be limited to one per artefact
+	legacyIndexes := map[string]string{
+		"picture_models":           "idx_picture_models_artefact_id",
+		"historical_record_models": "idx_historical_record_models_artefact_id",
+	}
+	for table, index := range legacyIndexes {
+		if !db.Migrator().HasTable(table) || !db.Migrator().HasIndex(table, index) {
+			continue
+		}
+		if err := db.Migrator().DropIndex(table, index); err != nil {
+			return err
+		}
+		log.Printf("Legacy index %s dropped from %s\n", index, table)
+	}
+
+	return nil
+}
+
+// RunPostMigrations backfills data for columns added by AutoMigrate.
+// Every step must be idempotent because it runs on each startup.
+func RunPostMigrations(db *gorm.DB) error {
+	// Mark the oldest file of each artefact as primary when none is marked yet
+	for _, table := range []string{"picture_models", "historical_record_models"} {
+		if err := db.Exec(`
+			UPDATE ` + table + ` SET is_primary = true
+			WHERE id IN (
+				SELECT MIN(t.id) FROM ` + table + ` t
+				GROUP BY t.artefact_id
+				HAVING NOT bool_or(t.is_primary)
+			)`).Error; err != nil {
+			return err
+		}
+	}
+
+	// Users created before roles existed keep full access
+	if err := db.Exec(`UPDATE user_models SET role = 'admin' WHERE role IS NULL OR role = ''`).Error; err != nil {
+		return err
+	}
+
+	// The retention period of requesters loaded before it existed starts counting now
+	if err := db.Exec(`UPDATE requester_models SET created_at = NOW() WHERE created_at IS NULL`).Error; err != nil {
+		return err
+	}
+
+	// Batches used to be grouped by a timestamp; each legacy group becomes a movement group
+	if err := backfillMovementGroups(db); err != nil {
+		return err
+	}
+
+	// The location history of pieces placed before it existed (and never moved) starts from their current location
+	if err := db.Exec(`
+		INSERT INTO location_assignment_models (artefact_id, physical_location_id, source, created_at)
+		SELECT a.id, a.physical_location_id, 'baseline', NOW() FROM artefact_models a
+		WHERE a.physical_location_id IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM location_assignment_models l WHERE l.artefact_id = a.id)
+			AND NOT EXISTS (SELECT 1 FROM internal_movement_models m WHERE m.artefact_id = a.id AND m.voided_at IS NULL)`).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// backfillMovementGroups creates a movement group for each legacy group_movement_id (a Unix timestamp)
+// and points its movements to it. The group takes the reason and requester of its first movement.
+func backfillMovementGroups(db *gorm.DB) error {
+	var legacyIds []int
+	if err := db.Raw(`
+		SELECT DISTINCT m.group_movement_id FROM internal_movement_models m
+		WHERE m.group_movement_id IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM movement_group_models g WHERE g.id = m.group_movement_id)`).
+		Scan(&legacyIds).Error; err != nil {
+		return err
+	}
+
+	for _, legacyId := range legacyIds {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var groupId int
+			if err := tx.Raw(`
+				INSERT INTO movement_group_models (reason, requester_id, created_at)
+				SELECT reason, requester_id, movement_date + movement_time FROM internal_movement_models
+				WHERE group_movement_id = ? ORDER BY id LIMIT 1
+				RETURNING id`, legacyId).
+				Scan(&groupId).Error; err != nil {
+				return err
+			}
+			return tx.Exec(`UPDATE internal_movement_models SET group_movement_id = ? WHERE group_movement_id = ?`,
+				groupId, legacyId).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(legacyIds) > 0 {
+		log.Printf("%d legacy movement groups migrated\n", len(legacyIds))
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 package dtos
 
+import "time"
+
 // ArtefactSummaryDTO represents a summarized view of an artefact.
 type ArtefactSummaryDTO struct {
 	ID                     int     `json:"id"`
@@ -12,3 +14,26 @@ type ArtefactSummaryDTO struct {
 	Level                  *int    `json:"level,omitempty"`
 	Column                 *string `json:"column,omitempty"`
 }
+
+// PictureMetadataDTO holds the editable metadata of an artefact picture. Fields left out are not changed
+// (an empty caption clears it). isPrimary only promotes the picture: the artefact always keeps one primary.
+type PictureMetadataDTO struct {
+	Caption      *string    `json:"caption"`
+	Photographer *string    `json:"photographer"`
+	TakenAt      *time.Time `json:"takenAt"`
+	IsPrimary    *bool      `json:"isPrimary"`
+}
+
+// HistoricalRecordMetadataDTO holds the editable metadata of an artefact historical record. Fields left out are
+// not changed (an empty caption clears it). isPrimary only promotes the record: the artefact always keeps one primary.
+type HistoricalRecordMetadataDTO struct {
+	Caption      *string    `json:"caption"`
+	Author       *string    `json:"author"`
+	DocumentDate *time.Time `json:"documentDate"`
+	IsPrimary    *bool      `json:"isPrimary"`
+}
+
+// ReorderFilesDTO lists file IDs in the desired display order.
+type ReorderFilesDTO struct {
+	IDs []int `json:"ids" binding:"required"`
+}
@@ -0,0 +1,11 @@
+package dtos
+
+// ReconcileFilesDTO selects what the reconciliation job does with the orphans it finds.
+type ReconcileFilesDTO struct {
+	// Action: quarantine (move files / back up rows before deleting them) or purge (delete)
+	Action string `json:"action" binding:"required,oneof=quarantine purge"`
+	// Target: files (orphaned files), records (rows whose file is missing) or all. Defaults to all.
+	Target string `json:"target" binding:"omitempty,oneof=files records all"`
+	// MinAgeMinutes skips files newer than this, so uploads in progress aren't touched. Defaults to 60.
+	MinAgeMinutes *int `json:"minAgeMinutes"`
+}
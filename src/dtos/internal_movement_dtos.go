@@ -0,0 +1,31 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+)
+
+// ReturnInternalMovementDTO records that a moved artefact came back to its origin location.
+type ReturnInternalMovementDTO struct {
+	ReturnDate   time.Time `json:"returnDate" binding:"required"`
+	ReturnTime   time.Time `json:"returnTime" binding:"required"`
+	Observations *string   `json:"observations"` // reemplaza las observaciones del movimiento si se envía
+}
+
+// CreateMovementGroupDTO moves several artefacts together. Movements without reason or requester take the group's.
+type CreateMovementGroupDTO struct {
+	Reason      *string                        `json:"reason"`
+	RequesterId *int                           `json:"requesterId"`
+	Movements   []models.InternalMovementModel `json:"movements" binding:"required,min=1"`
+}
+
+// VoidInternalMovementDTO cancels a movement registered by mistake, keeping it for audit.
+type VoidInternalMovementDTO struct {
+	Reason *string `json:"reason"`
+}
+
+// CancelMovementGroupDTO voids every movement of a group, putting the artefacts back where they were.
+type CancelMovementGroupDTO struct {
+	Reason *string `json:"reason"`
+}
@@ -0,0 +1,26 @@
+package dtos
+
+import "github.com/ARQAP/ARQAP-Backend/src/models"
+
+// What a scanned label identifies
+const (
+	LabelKindArtefact = "artefact"
+	LabelKindLocation = "location"
+)
+
+// LabelSheetDTO selects what to print on a label sheet. Shelves add a label for each of their positions.
+type LabelSheetDTO struct {
+	ArtefactIds         []int `json:"artefactIds"`
+	PhysicalLocationIds []int `json:"physicalLocationIds"`
+	ShelfIds            []int `json:"shelfIds"`
+}
+
+// ResolvedLabelDTO is the piece or location a scanned code (QR payload or inventory code) refers to.
+type ResolvedLabelDTO struct {
+	Code               string                        `json:"code"`
+	Kind               string                        `json:"kind"`
+	ArtefactId         *int                          `json:"artefactId,omitempty"`
+	Artefact           *models.ArtefactModel         `json:"artefact,omitempty"`
+	PhysicalLocationId *int                          `json:"physicalLocationId,omitempty"`
+	PhysicalLocation   *models.PhysicalLocationModel `json:"physicalLocation,omitempty"`
+}
@@ -0,0 +1,82 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+)
+
+// LoanFilterDTO holds the optional filters of the loan list.
+type LoanFilterDTO struct {
+	Status        string     // active, due_soon, overdue, returned
+	RequesterId   *int       // loans of this requester
+	InstitutionId *int       // loans attributed to this institution
+	From          *time.Time // loan date from (inclusive)
+	To            *time.Time // loan date to (inclusive)
+}
+
+// CreateLoanHeaderDTO creates a loan of several artefacts, one line per artefact.
+type CreateLoanHeaderDTO struct {
+	RequesterId        *int       `json:"requesterId"`
+	InstitutionId      *int       `json:"institutionId"` // por defecto, la institución del solicitante
+	Purpose            *string    `json:"purpose"`
+	LoanDate           time.Time  `json:"loanDate" binding:"required"`
+	LoanTime           time.Time  `json:"loanTime" binding:"required"`
+	DueDate            *time.Time `json:"dueDate"`
+	AgreementReference *string    `json:"agreementReference"`
+	Observations       *string    `json:"observations"`
+	ArtefactIds        []int      `json:"artefactIds" binding:"required,min=1"`
+}
+
+// UpdateLoanHeaderDTO holds the editable fields of a loan header. Nil fields are left unchanged.
+// Requester, institution and dates are copied to every line so single-loan queries keep working.
+type UpdateLoanHeaderDTO struct {
+	RequesterId        *int       `json:"requesterId"`
+	InstitutionId      *int       `json:"institutionId"`
+	Purpose            *string    `json:"purpose"`
+	LoanDate           *time.Time `json:"loanDate"`
+	LoanTime           *time.Time `json:"loanTime"`
+	DueDate            *time.Time `json:"dueDate"`
+	AgreementReference *string    `json:"agreementReference"`
+	Observations       *string    `json:"observations"`
+}
+
+// AddLoanLinesDTO adds artefacts to an existing loan header.
+type AddLoanLinesDTO struct {
+	ArtefactIds []int `json:"artefactIds" binding:"required,min=1"`
+}
+
+// ReturnLoanDTO registers the return of a lent artefact.
+type ReturnLoanDTO struct {
+	ReturnDate   time.Time `json:"returnDate" binding:"required"`
+	ReturnTime   time.Time `json:"returnTime" binding:"required"`
+	Condition    *string   `json:"condition"`    // estado de la pieza al devolverla
+	ReceivedById *int      `json:"receivedById"` // por defecto, el usuario autenticado
+}
+
+// ReturnLoanLinesDTO returns some or all of the open lines of a loan header.
+type ReturnLoanLinesDTO struct {
+	LoanIds []int `json:"loanIds"` // vacío: todas las líneas abiertas
+	ReturnLoanDTO
+}
+
+// AvailabilityMismatchDTO is an artefact whose available flag disagrees with its open loans.
+type AvailabilityMismatchDTO struct {
+	ArtefactId int    `json:"artefactId"`
+	Name       string `json:"name"`
+	Available  bool   `json:"available"`
+	OpenLoans  int    `json:"openLoans"`
+}
+
+// OverdueLoanDTO is a row of the overdue loans report.
+type OverdueLoanDTO struct {
+	Loan        models.LoanModel `json:"loan"`
+	DaysOverdue int              `json:"daysOverdue"`
+}
+
+// ConditionReportDTO holds what the examiner observed when a piece leaves or comes back.
+type ConditionReportDTO struct {
+	Stage        string  `json:"stage" binding:"required,oneof=dispatch return"`
+	Condition    *string `json:"condition"` // en la devolución, por defecto el estado registrado al devolver
+	Observations *string `json:"observations"`
+}
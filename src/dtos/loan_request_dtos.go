@@ -0,0 +1,41 @@
+package dtos
+
+import "time"
+
+// CreateLoanRequestDTO drafts a loan request for one or more artefacts.
+type CreateLoanRequestDTO struct {
+	RequesterId        *int       `json:"requesterId"`
+	InstitutionId      *int       `json:"institutionId"`
+	Purpose            *string    `json:"purpose"`
+	RequestedFrom      time.Time  `json:"requestedFrom" binding:"required"`
+	RequestedUntil     *time.Time `json:"requestedUntil"`
+	AgreementReference *string    `json:"agreementReference"`
+	Observations       *string    `json:"observations"`
+	ArtefactIds        []int      `json:"artefactIds" binding:"required,min=1"`
+}
+
+// UpdateLoanRequestDTO edits a draft loan request. Nil fields are left unchanged;
+// ArtefactIds, when present, replaces the requested pieces.
+type UpdateLoanRequestDTO struct {
+	RequesterId        *int       `json:"requesterId"`
+	InstitutionId      *int       `json:"institutionId"`
+	Purpose            *string    `json:"purpose"`
+	RequestedFrom      *time.Time `json:"requestedFrom"`
+	RequestedUntil     *time.Time `json:"requestedUntil"`
+	AgreementReference *string    `json:"agreementReference"`
+	Observations       *string    `json:"observations"`
+	ArtefactIds        []int      `json:"artefactIds" binding:"omitempty,min=1"`
+}
+
+// LoanRequestCommentDTO carries the comment of a transition (required to reject) or a plain comment.
+type LoanRequestCommentDTO struct {
+	Comment *string `json:"comment"`
+}
+
+// DispatchLoanRequestDTO turns an approved request into a loan. Dates default to now and the requested end date.
+type DispatchLoanRequestDTO struct {
+	LoanDate *time.Time `json:"loanDate"`
+	LoanTime *time.Time `json:"loanTime"`
+	DueDate  *time.Time `json:"dueDate"`
+	Comment  *string    `json:"comment"`
+}
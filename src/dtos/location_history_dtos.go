@@ -0,0 +1,55 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+)
+
+// Where a piece is according to its location history
+const (
+	LocationStatusInStorage = "in_storage" // en la reserva, en PhysicalLocation (o sin ubicación asignada)
+	LocationStatusOnLoan    = "on_loan"
+	LocationStatusUnknown   = "unknown" // no hay registros anteriores a la fecha
+)
+
+// LocationHistoryEntryDTO is an event of the location history, with where the piece was right after it.
+type LocationHistoryEntryDTO struct {
+	At                 time.Time                     `json:"at"`
+	Kind               string                        `json:"kind"`        // import, manual, baseline, internal_movement, movement_return, loan, loan_return
+	ReferenceId        int                           `json:"referenceId"` // asignación, movimiento o préstamo
+	Status             string                        `json:"status"`
+	PhysicalLocationId *int                          `json:"physicalLocationId"`
+	PhysicalLocation   *models.PhysicalLocationModel `json:"physicalLocation"`
+	Description        string                        `json:"description,omitempty"`
+}
+
+// ArtefactLocationDTO is where a piece was at a given moment.
+type ArtefactLocationDTO struct {
+	ArtefactId         int                           `json:"artefactId"`
+	At                 time.Time                     `json:"at"`
+	Status             string                        `json:"status"`
+	PhysicalLocationId *int                          `json:"physicalLocationId"`
+	PhysicalLocation   *models.PhysicalLocationModel `json:"physicalLocation"`
+	LoanId             *int                          `json:"loanId,omitempty"`
+	LastEvent          *LocationHistoryEntryDTO      `json:"lastEvent"` // el evento que dejó la pieza ahí
+}
+
+// ShelfArtefactDTO is a piece placed on a shelf at a given moment.
+type ShelfArtefactDTO struct {
+	ArtefactId       int                           `json:"artefactId"`
+	Name             string                        `json:"name"`
+	InventoryCode    string                        `json:"inventoryCode"`
+	PhysicalLocation *models.PhysicalLocationModel `json:"physicalLocation"`
+	Since            time.Time                     `json:"since"`
+	LoanId           *int                          `json:"loanId,omitempty"`
+}
+
+// ShelfContentsDTO lists the pieces stored on a shelf at a given moment.
+type ShelfContentsDTO struct {
+	ShelfId   int                `json:"shelfId"`
+	ShelfCode int                `json:"shelfCode"`
+	At        time.Time          `json:"at"`
+	Artefacts []ShelfArtefactDTO `json:"artefacts"`
+	OnLoan    []ShelfArtefactDTO `json:"onLoan"` // ubicadas en el estante pero prestadas en ese momento
+}
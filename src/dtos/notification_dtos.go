@@ -0,0 +1,17 @@
+package dtos
+
+import "time"
+
+// LoanNotificationsDTO turns the reminder emails of a loan on or off.
+type LoanNotificationsDTO struct {
+	OptOut *bool `json:"optOut" binding:"required"`
+}
+
+// ReminderRunDTO summarizes one run of the loan reminder job.
+type ReminderRunDTO struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Sent       int       `json:"sent"`    // correos enviados
+	Failed     int       `json:"failed"`  // correos que el servidor SMTP rechazó
+	Skipped    int       `json:"skipped"` // préstamos cuyo solicitante no tiene email
+}
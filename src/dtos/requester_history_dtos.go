@@ -0,0 +1,28 @@
+package dtos
+
+import "github.com/ARQAP/ARQAP-Backend/src/models"
+
+// RequesterLoanSummaryDTO counts the loans of a requester by outcome.
+type RequesterLoanSummaryDTO struct {
+	TotalLoans    int `json:"totalLoans"`
+	OpenLoans     int `json:"openLoans"`
+	OverdueLoans  int `json:"overdueLoans"` // abiertos con la fecha pactada vencida
+	ReturnedLoans int `json:"returnedLoans"`
+	LateReturns   int `json:"lateReturns"` // devueltos después de la fecha pactada
+	MaxDaysLate   int `json:"maxDaysLate"`
+}
+
+// LoanEligibilityDTO tells whether a requester may receive new loans and, if not, why.
+type LoanEligibilityDTO struct {
+	Eligible bool     `json:"eligible"`
+	Reasons  []string `json:"reasons"`
+}
+
+// RequesterHistoryDTO is the track record of a requester, reviewed before approving a new loan.
+type RequesterHistoryDTO struct {
+	Requester         models.RequesterModel          `json:"requester"`
+	Summary           RequesterLoanSummaryDTO        `json:"summary"`
+	Eligibility       LoanEligibilityDTO             `json:"eligibility"`
+	Loans             []models.LoanModel             `json:"loans"`
+	InternalMovements []models.InternalMovementModel `json:"internalMovements"`
+}
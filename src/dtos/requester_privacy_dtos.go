@@ -0,0 +1,36 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+)
+
+// RetentionCandidateDTO is a requester inactive for longer than the retention period.
+type RetentionCandidateDTO struct {
+	RequesterId  int       `json:"requesterId"`
+	FirstName    *string   `json:"firstname" gorm:"column:firstname"`
+	LastName     *string   `json:"lastname" gorm:"column:lastname"`
+	Type         string    `json:"type"`
+	LastActivity time.Time `json:"lastActivity"`
+}
+
+// RetentionRunDTO summarizes one run of the requester retention job.
+type RetentionRunDTO struct {
+	RetentionMonths int       `json:"retentionMonths"`
+	Cutoff          time.Time `json:"cutoff"` // sin actividad desde antes de esta fecha
+	Anonymized      []int     `json:"anonymized"`
+}
+
+// RequesterDataExportDTO is everything stored about a requester (data-subject access request).
+type RequesterDataExportDTO struct {
+	ExportedAt        time.Time                      `json:"exportedAt"`
+	Requester         models.RequesterModel          `json:"requester"`
+	Loans             []models.LoanModel             `json:"loans"`
+	LoanHeaders       []models.LoanHeaderModel       `json:"loanHeaders"`
+	LoanRequests      []models.LoanRequestModel      `json:"loanRequests"`
+	Reservations      []models.ReservationModel      `json:"reservations"`
+	InternalMovements []models.InternalMovementModel `json:"internalMovements"`
+	LoanDocuments     []models.LoanDocumentModel     `json:"loanDocuments"`
+	Notifications     []models.NotificationLogModel  `json:"notifications"`
+}
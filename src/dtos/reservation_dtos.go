@@ -0,0 +1,30 @@
+package dtos
+
+import "time"
+
+// CreateReservationDTO books an artefact for a period.
+type CreateReservationDTO struct {
+	ArtefactId  int       `json:"artefactId" binding:"required"`
+	StartDate   time.Time `json:"startDate" binding:"required"`
+	EndDate     time.Time `json:"endDate" binding:"required"`
+	Purpose     *string   `json:"purpose"`
+	RequesterId *int      `json:"requesterId"`
+}
+
+// UpdateReservationDTO edits an active reservation. Nil fields are left unchanged.
+type UpdateReservationDTO struct {
+	StartDate   *time.Time `json:"startDate"`
+	EndDate     *time.Time `json:"endDate"`
+	Purpose     *string    `json:"purpose"`
+	RequesterId *int       `json:"requesterId"`
+}
+
+// CalendarEntryDTO is a period in which an artefact is busy.
+type CalendarEntryDTO struct {
+	Kind        string     `json:"kind"` // reservation, loan, internal_movement
+	Id          int        `json:"id"`
+	Start       time.Time  `json:"start"`
+	End         *time.Time `json:"end"` // nil: sin fecha de fin
+	Status      string     `json:"status"`
+	Description string     `json:"description,omitempty"`
+}
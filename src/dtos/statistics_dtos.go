@@ -0,0 +1,57 @@
+package dtos
+
+import "time"
+
+// StatisticsFilterDTO holds the optional period and size of the statistics queries.
+type StatisticsFilterDTO struct {
+	From  *time.Time // fecha de préstamo o movimiento desde (inclusive)
+	To    *time.Time // hasta (inclusive)
+	Limit int        // tamaño de los rankings
+}
+
+// LoansPerMonthDTO counts the loans started and returned in a month (YYYY-MM).
+type LoansPerMonthDTO struct {
+	Month    string `json:"month"`
+	Loans    int    `json:"loans"`
+	Returned int    `json:"returned"`
+}
+
+// LoansPerRequesterTypeDTO counts the loans of each requester type.
+type LoansPerRequesterTypeDTO struct {
+	RequesterType string `json:"requesterType"`
+	Loans         int    `json:"loans"`
+}
+
+// LoanDurationDTO summarizes how long pieces stay out on loan, in days.
+type LoanDurationDTO struct {
+	ReturnedLoans   int     `json:"returnedLoans"`
+	AverageDays     float64 `json:"averageDays"`
+	MinDays         int     `json:"minDays"`
+	MaxDays         int     `json:"maxDays"`
+	OpenLoans       int     `json:"openLoans"`
+	AverageOpenDays float64 `json:"averageOpenDays"` // antigüedad media de los préstamos abiertos
+}
+
+// MostRequestedArtefactDTO is a row of the most lent artefacts ranking.
+type MostRequestedArtefactDTO struct {
+	ArtefactId int    `json:"artefactId"`
+	Name       string `json:"name"`
+	Loans      int    `json:"loans"`
+}
+
+// MostRequestedCollectionDTO is a row of the most lent collections ranking.
+type MostRequestedCollectionDTO struct {
+	CollectionId   *int   `json:"collectionId"` // nil: piezas sin colección
+	CollectionName string `json:"collectionName"`
+	Loans          int    `json:"loans"`
+	Artefacts      int    `json:"artefacts"` // piezas distintas prestadas
+}
+
+// MovementsPerShelfDTO counts the internal movements into and out of a shelf in a month (YYYY-MM).
+type MovementsPerShelfDTO struct {
+	Month     string `json:"month"`
+	ShelfId   int    `json:"shelfId"`
+	ShelfCode int    `json:"shelfCode"`
+	Incoming  int    `json:"incoming"`
+	Outgoing  int    `json:"outgoing"`
+}
@@ -0,0 +1,67 @@
+package dtos
+
+import "github.com/ARQAP/ARQAP-Backend/src/models"
+
+// Why a piece found during a stocktake was not expected in the counted area
+const (
+	UnexpectedOnLoan     = "on_loan"      // la base la da por prestada
+	UnexpectedOutOfScope = "out_of_scope" // ubicada en un estante fuera del recuento
+	UnexpectedNoLocation = "no_location"  // sin ubicación asignada
+)
+
+// CreateStocktakeDTO opens a stocktake session. Without shelves it covers the whole store.
+type CreateStocktakeDTO struct {
+	ShelfIds []int   `json:"shelfIds"`
+	Notes    *string `json:"notes"`
+}
+
+// SubmitStocktakeScansDTO records the inventory codes or piece labels found at a location, typed or scanned.
+// The location is given by its ID or its scanned shelf position label. An empty list means the location
+// was counted and nothing was there.
+type SubmitStocktakeScansDTO struct {
+	PhysicalLocationId int      `json:"physicalLocationId" binding:"required_without=LocationCode"`
+	LocationCode       string   `json:"locationCode"`
+	Codes              []string `json:"codes" binding:"required"`
+	Replace            bool     `json:"replace"` // recuento: descarta lo registrado antes en la ubicación
+}
+
+// ApplyStocktakeCorrectionsDTO moves the misplaced and unexpected pieces to where they were found.
+// Without artefact IDs every correctable piece of the report is moved.
+type ApplyStocktakeCorrectionsDTO struct {
+	ArtefactIds []int   `json:"artefactIds"`
+	Reason      *string `json:"reason"`
+}
+
+// StocktakePieceDTO is a piece of the stocktake report with where the database puts it and where it was found.
+type StocktakePieceDTO struct {
+	ArtefactId         int                           `json:"artefactId"`
+	Name               string                        `json:"name"`
+	InventoryCode      string                        `json:"inventoryCode"`
+	Available          bool                          `json:"available"`
+	RecordedLocationId *int                          `json:"recordedLocationId"`
+	RecordedLocation   *models.PhysicalLocationModel `json:"recordedLocation"`
+	FoundLocationId    *int                          `json:"foundLocationId"`
+	FoundLocation      *models.PhysicalLocationModel `json:"foundLocation"`
+	Reason             string                        `json:"reason,omitempty"` // solo en las inesperadas
+}
+
+// StocktakeUnknownCodeDTO is a scanned code that doesn't match any piece.
+type StocktakeUnknownCodeDTO struct {
+	Code               string `json:"code"`
+	PhysicalLocationId int    `json:"physicalLocationId"`
+}
+
+// StocktakeReportDTO compares what was found in the counted locations with the database.
+type StocktakeReportDTO struct {
+	SessionId        int                            `json:"sessionId"`
+	Status           string                         `json:"status"`
+	ShelfIds         []int                          `json:"shelfIds"` // vacío: toda la reserva
+	CountedLocations int                            `json:"countedLocations"`
+	PendingLocations []models.PhysicalLocationModel `json:"pendingLocations"` // del alcance, todavía sin contar
+	Expected         int                            `json:"expected"`
+	Matched          int                            `json:"matched"`
+	Missing          []StocktakePieceDTO            `json:"missing"`    // esperadas en una ubicación contada y no encontradas
+	Misplaced        []StocktakePieceDTO            `json:"misplaced"`  // encontradas en otra ubicación del alcance
+	Unexpected       []StocktakePieceDTO            `json:"unexpected"` // encontradas pero no esperadas en el alcance
+	UnknownCodes     []StocktakeUnknownCodeDTO      `json:"unknownCodes"`
+}
@@ -0,0 +1,104 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotConfigured is returned by NewFromEnv when SMTP_HOST is empty
+var ErrNotConfigured = errors.New("el servidor SMTP no está configurado")
+
+// Sender delivers plain-text emails
+type Sender interface {
+	Send(to []string, subject, body string) error
+}
+
+// SMTPConfig holds the connection settings of the SMTP server
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPSender sends emails through an SMTP server with net/smtp.
+// STARTTLS is used when the server offers it; authentication only when a username is set.
+type SMTPSender struct {
+	config SMTPConfig
+}
+
+// NewFromEnv creates a sender configured from environment variables:
+// SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM.
+// A local stand-in (e.g. Mailpit on port 1025) works with just SMTP_HOST and SMTP_PORT.
+func NewFromEnv() (*SMTPSender, error) {
+	config := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if config.Host == "" {
+		return nil, ErrNotConfigured
+	}
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	if config.From == "" {
+		config.From = "arqap@localhost"
+	}
+	if _, err := mail.ParseAddress(config.From); err != nil {
+		return nil, fmt.Errorf("SMTP_FROM inválido: %w", err)
+	}
+	return &SMTPSender{config: config}, nil
+}
+
+// Send delivers one message to every recipient
+func (s *SMTPSender) Send(to []string, subject, body string) error {
+	if len(to) == 0 {
+		return errors.New("el correo no tiene destinatarios")
+	}
+
+	from, _ := mail.ParseAddress(s.config.From)
+	message, err := buildMessage(s.config.From, to, subject, body)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(s.config.Host, s.config.Port), auth, from.Address, to, message)
+}
+
+// buildMessage renders the headers and a quoted-printable UTF-8 body
+func buildMessage(from string, to []string, subject, body string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := w.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -18,6 +18,16 @@ func GetSecretKey() string {
 	return secretKey
 }
 
+// CurrentUserID returns the ID of the authenticated user (set by AuthMiddleware)
+func CurrentUserID(ctx *gin.Context) (int, bool) {
+	if id, ok := ctx.Get("userId"); ok {
+		if value, ok := id.(float64); ok {
+			return int(value), true
+		}
+	}
+	return 0, false
+}
+
 func AuthMiddleware() gin.HandlerFunc {
 	return func (ctx *gin.Context) {
 		var tokenString string
@@ -65,8 +75,9 @@ func AuthMiddleware() gin.HandlerFunc {
 			}
 		}
 
-		// Sets the token claims in the context (user ID)
+		// Sets the token claims in the context (user ID and role)
 		ctx.Set("userId", claims["id"])
+		ctx.Set("userRole", claims["role"])
 		ctx.Next()
 	}
 }
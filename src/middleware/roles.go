@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CurrentUserRole returns the role of the authenticated user (set by AuthMiddleware).
+// Tokens issued before roles existed have none.
+func CurrentUserRole(ctx *gin.Context) string {
+	if role, ok := ctx.Get("userRole"); ok {
+		if value, ok := role.(string); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// HasRole reports whether the authenticated user has one of the given roles
+func HasRole(ctx *gin.Context, roles ...string) bool {
+	current := CurrentUserRole(ctx)
+	for _, role := range roles {
+		if current == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRole only lets through users with one of the given roles. Must run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !HasRole(ctx, roles...) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BlockPaths answers 404 for requests under any of the given path prefixes. It keeps private files
+// out of a static route that serves their parent directory.
+func BlockPaths(prefixes ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requested := path.Clean("/" + ctx.Request.URL.Path)
+		for _, prefix := range prefixes {
+			if requested == prefix || strings.HasPrefix(requested, prefix+"/") {
+				ctx.AbortWithStatus(http.StatusNotFound)
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
@@ -25,26 +25,42 @@ type ArtefactModel struct {
 	PhysicalLocation     *PhysicalLocationModel   `json:"physicalLocation" gorm:"foreignKey:PhysicalLocationID;references:ID"`
 }
 
+// PictureModel is one of the photographs of an artefact (different views, before/after conservation, etc.)
 type PictureModel struct {
-	ID           int       `json:"id" gorm:"primaryKey;autoIncrement"`
-	ArtefactID   int       `json:"artefactId" gorm:"column:artefact_id;not null;uniqueIndex"`
-	Filename     string    `json:"filename" gorm:"type:varchar(255);not null"`
-	OriginalName string    `json:"originalName" gorm:"column:original_name;type:varchar(255)"`
-	FilePath     string    `json:"filePath" gorm:"column:file_path;type:varchar(500);not null"`
-	ContentType  string    `json:"contentType" gorm:"column:content_type;type:varchar(50)"`
-	Size         int64     `json:"size"`
-	CreatedAt    time.Time `json:"createdAt" gorm:"column:created_at"`
-	UpdatedAt    time.Time `json:"updatedAt" gorm:"column:updated_at"`
+	ID            int        `json:"id" gorm:"primaryKey;autoIncrement"`
+	ArtefactID    int        `json:"artefactId" gorm:"column:artefact_id;not null;index:idx_picture_artefact_order,priority:1"`
+	Filename      string     `json:"filename" gorm:"type:varchar(255);not null"`
+	OriginalName  string     `json:"originalName" gorm:"column:original_name;type:varchar(255)"`
+	FilePath      string     `json:"filePath" gorm:"column:file_path;type:varchar(500);not null"`
+	ContentType   string     `json:"contentType" gorm:"column:content_type;type:varchar(50)"`
+	Size          int64      `json:"size"`
+	ThumbnailPath string     `json:"thumbnailPath" gorm:"column:thumbnail_path;type:varchar(500)"`
+	MediumPath    string     `json:"mediumPath" gorm:"column:medium_path;type:varchar(500)"`
+	Sha256        string     `json:"sha256" gorm:"column:sha256;type:varchar(64);index"`
+	SortOrder     int        `json:"sortOrder" gorm:"column:sort_order;not null;default:0;index:idx_picture_artefact_order,priority:2"`
+	Caption       *string    `json:"caption" gorm:"column:caption;type:varchar(255)"`
+	Photographer  *string    `json:"photographer" gorm:"column:photographer;type:varchar(100)"`
+	TakenAt       *time.Time `json:"takenAt" gorm:"column:taken_at;type:date"`
+	IsPrimary     bool       `json:"isPrimary" gorm:"column:is_primary;not null;default:false"`
+	CreatedAt     time.Time  `json:"createdAt" gorm:"column:created_at"`
+	UpdatedAt     time.Time  `json:"updatedAt" gorm:"column:updated_at"`
 }
 
+// HistoricalRecordModel is one of the historical documents (fichas históricas) of an artefact
 type HistoricalRecordModel struct {
-	ID           int       `json:"id" gorm:"primaryKey;autoIncrement"`
-	ArtefactID   int       `json:"artefactId" gorm:"column:artefact_id;not null;uniqueIndex"`
-	Filename     string    `json:"filename" gorm:"type:varchar(255);not null"`
-	OriginalName string    `json:"originalName" gorm:"column:original_name;type:varchar(255)"`
-	FilePath     string    `json:"filePath" gorm:"column:file_path;type:varchar(500);not null"`
-	ContentType  string    `json:"contentType" gorm:"column:content_type;type:varchar(50)"`
-	Size         int64     `json:"size"`
-	CreatedAt    time.Time `json:"createdAt" gorm:"column:created_at"`
-	UpdatedAt    time.Time `json:"updatedAt" gorm:"column:updated_at"`
+	ID           int        `json:"id" gorm:"primaryKey;autoIncrement"`
+	ArtefactID   int        `json:"artefactId" gorm:"column:artefact_id;not null;index:idx_historical_record_artefact_order,priority:1"`
+	Filename     string     `json:"filename" gorm:"type:varchar(255);not null"`
+	OriginalName string     `json:"originalName" gorm:"column:original_name;type:varchar(255)"`
+	FilePath     string     `json:"filePath" gorm:"column:file_path;type:varchar(500);not null"`
+	ContentType  string     `json:"contentType" gorm:"column:content_type;type:varchar(50)"`
+	Size         int64      `json:"size"`
+	Sha256       string     `json:"sha256" gorm:"column:sha256;type:varchar(64);index"`
+	SortOrder    int        `json:"sortOrder" gorm:"column:sort_order;not null;default:0;index:idx_historical_record_artefact_order,priority:2"`
+	Caption      *string    `json:"caption" gorm:"column:caption;type:varchar(255)"`
+	Author       *string    `json:"author" gorm:"column:author;type:varchar(100)"`
+	DocumentDate *time.Time `json:"documentDate" gorm:"column:document_date;type:date"`
+	IsPrimary    bool       `json:"isPrimary" gorm:"column:is_primary;not null;default:false"`
+	CreatedAt    time.Time  `json:"createdAt" gorm:"column:created_at"`
+	UpdatedAt    time.Time  `json:"updatedAt" gorm:"column:updated_at"`
 }
@@ -15,6 +15,9 @@ type INPLFicha struct {
 	FilePath         string    `json:"filePath" gorm:"column:file_path;type:varchar(500);not null"`
 	ContentType      string    `json:"contentType" gorm:"column:content_type;type:varchar(50)"`
 	Size             int64     `json:"size"`
+	ThumbnailPath    string    `json:"thumbnailPath" gorm:"column:thumbnail_path;type:varchar(500)"`
+	MediumPath       string    `json:"mediumPath" gorm:"column:medium_path;type:varchar(500)"`
+	Sha256           string    `json:"sha256" gorm:"column:sha256;type:varchar(64);index"`
 	CreatedAt        time.Time `json:"createdAt" gorm:"column:created_at"`
 	UpdatedAt        time.Time `json:"updatedAt" gorm:"column:updated_at"`
 }
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// InstitutionModel is an organisation that borrows pieces (university, museum, ministry, department).
+// Its requesters are the contact people; loans can be attributed to the institution as well.
+type InstitutionModel struct {
+	Id                          int              `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name                        string           `json:"name" gorm:"type:varchar(255);not null;uniqueIndex"`
+	TaxId                       *string          `json:"taxId" gorm:"column:tax_id;type:varchar(20)"` // CUIT
+	Address                     *string          `json:"address" gorm:"type:varchar(255)"`
+	City                        *string          `json:"city" gorm:"type:varchar(100)"`
+	Province                    *string          `json:"province" gorm:"type:varchar(100)"`
+	CountryId                   *int             `json:"countryId" gorm:"column:country_id"`
+	Country                     *CountryModel    `json:"country,omitempty" gorm:"foreignKey:CountryId;references:Id"`
+	Email                       *string          `json:"email" gorm:"type:varchar(100)"`
+	PhoneNumber                 *string          `json:"phoneNumber" gorm:"column:phone_number;type:varchar(20)"`
+	LegalRepresentativeName     *string          `json:"legalRepresentativeName" gorm:"type:varchar(255)"`
+	LegalRepresentativeDni      *string          `json:"legalRepresentativeDni" gorm:"type:varchar(20)"`
+	LegalRepresentativePosition *string          `json:"legalRepresentativePosition" gorm:"type:varchar(100)"` // cargo (rector, director, etc.)
+	Contacts                    []RequesterModel `json:"contacts,omitempty" gorm:"foreignKey:InstitutionId"`
+	CreatedAt                   time.Time        `json:"createdAt"`
+	UpdatedAt                   time.Time        `json:"updatedAt"`
+}
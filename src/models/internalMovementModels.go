@@ -8,6 +8,7 @@ type InternalMovementModel struct {
 	MovementTime           time.Time              `json:"movementTime" gorm:"type:time;not null"`
 	ReturnDate             *time.Time             `json:"returnDate" gorm:"type:date"`
 	ReturnTime             *time.Time             `json:"returnTime" gorm:"type:time"`
+	ExpectedReturnDate     *time.Time             `json:"expectedReturnDate" gorm:"type:date"` // retorno programado, se publica en el calendario
 	ArtefactId             int                    `json:"artefactId" gorm:"column:artefact_id;not null"`
 	Artefact               *ArtefactModel         `json:"artefact" gorm:"foreignKey:ArtefactId;references:ID"`
 	FromPhysicalLocationId *int                   `json:"fromPhysicalLocationId" gorm:"column:from_physical_location_id"`
@@ -18,5 +19,32 @@ type InternalMovementModel struct {
 	Observations           *string                `json:"observations" gorm:"type:text"`
 	RequesterId            *int                   `json:"requesterId" gorm:"column:requester_id"`
 	Requester              *RequesterModel        `json:"requester" gorm:"foreignKey:RequesterId;references:Id"`
-	GroupMovementId        *int                   `json:"groupMovementId" gorm:"column:group_movement_id;index"` // Para agrupar movimientos creados juntos
+	GroupMovementId        *int                   `json:"groupMovementId" gorm:"column:group_movement_id;index"`        // Para agrupar movimientos creados juntos
+	ClosedByMovementId     *int                   `json:"closedByMovementId" gorm:"column:closed_by_movement_id;index"` // movimiento posterior que lo finalizó
+	VoidedAt               *time.Time             `json:"voidedAt" gorm:"index"`                                        // anulado: se conserva para auditoría pero no cuenta
+	VoidedById             *int                   `json:"voidedById" gorm:"column:voided_by_id"`
+	VoidReason             *string                `json:"voidReason" gorm:"type:text"`
+}
+
+// Computed movement group statuses, derived from its movements
+const (
+	MovementGroupStatusOpen      = "open"
+	MovementGroupStatusReturned  = "returned"
+	MovementGroupStatusCancelled = "cancelled"
+)
+
+// MovementGroupModel groups the internal movements made together (batch), so they can be returned or cancelled at once
+type MovementGroupModel struct {
+	Id                 int                     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Reason             *string                 `json:"reason" gorm:"type:text"`
+	RequesterId        *int                    `json:"requesterId" gorm:"column:requester_id"`
+	Requester          *RequesterModel         `json:"requester" gorm:"foreignKey:RequesterId;references:Id"`
+	CreatedById        *int                    `json:"createdById" gorm:"column:created_by_id"`
+	CreatedAt          time.Time               `json:"createdAt"`
+	CancelledAt        *time.Time              `json:"cancelledAt"`
+	CancelledById      *int                    `json:"cancelledById" gorm:"column:cancelled_by_id"`
+	CancelReason       *string                 `json:"cancelReason" gorm:"type:text"`
+	StocktakeSessionId *int                    `json:"stocktakeSessionId" gorm:"column:stocktake_session_id;index"` // correcciones generadas por un recuento
+	Movements          []InternalMovementModel `json:"movements" gorm:"foreignKey:GroupMovementId"`
+	Status             string                  `json:"status" gorm:"-"`
 }
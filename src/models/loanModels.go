@@ -2,14 +2,76 @@ package models
 
 import "time"
 
+// Computed loan statuses (not stored, see LoanService)
+const (
+	LoanStatusActive   = "active"
+	LoanStatusDueSoon  = "due_soon"
+	LoanStatusOverdue  = "overdue"
+	LoanStatusReturned = "returned"
+)
+
+// Computed loan header statuses, derived from its lines
+const (
+	LoanHeaderStatusOpen              = "open"
+	LoanHeaderStatusPartiallyReturned = "partially_returned"
+	LoanHeaderStatusReturned          = "returned"
+)
+
+// LoanHeaderModel groups the artefacts lent together under one agreement (exhibition, research, etc.).
+// Each artefact is a LoanModel line; loans created before headers existed have no header.
+type LoanHeaderModel struct {
+	Id                 int               `json:"id" gorm:"primaryKey;autoIncrement"`
+	RequesterId        *int              `json:"requesterId" gorm:"column:requester_id;index"`
+	Requester          *RequesterModel   `json:"requester" gorm:"foreignKey:RequesterId;references:Id"`
+	InstitutionId      *int              `json:"institutionId" gorm:"column:institution_id;index"`
+	Institution        *InstitutionModel `json:"institution,omitempty" gorm:"foreignKey:InstitutionId;references:Id"`
+	Purpose            *string           `json:"purpose" gorm:"type:text"`
+	LoanDate           time.Time         `json:"loanDate" gorm:"type:date;not null"`
+	LoanTime           time.Time         `json:"loanTime" gorm:"type:time;not null"`
+	DueDate            *time.Time        `json:"dueDate" gorm:"type:date"`
+	AgreementReference *string           `json:"agreementReference" gorm:"type:varchar(255)"`
+	Observations       *string           `json:"observations" gorm:"type:text"`
+	Lines              []LoanModel       `json:"lines" gorm:"foreignKey:LoanHeaderId"`
+	Status             string            `json:"status" gorm:"-"`
+}
+
 type LoanModel struct {
-	Id          int             `json:"id" gorm:"primaryKey;autoIncrement"`
-	LoanDate    time.Time       `json:"loanDate" gorm:"type:date;not null"`
-	LoanTime    time.Time       `json:"loanTime" gorm:"type:time;not null"`
-	ReturnDate  *time.Time      `json:"returnDate" gorm:"type:date"`
-	ReturnTime  *time.Time      `json:"returnTime" gorm:"type:time"`
-	ArtefactId  *int            `json:"artefactId" gorm:"column:artefact_id"`
-	Artefact    *ArtefactModel  `json:"artefact" gorm:"foreignKey:ArtefactId;references:ID"`
-	RequesterId *int            `json:"requesterId" gorm:"column:requester_id"`
-	Requester   *RequesterModel `json:"requester" gorm:"foreignKey:RequesterId;references:Id"`
+	Id                  int               `json:"id" gorm:"primaryKey;autoIncrement"`
+	LoanDate            time.Time         `json:"loanDate" gorm:"type:date;not null"`
+	LoanTime            time.Time         `json:"loanTime" gorm:"type:time;not null"`
+	DueDate             *time.Time        `json:"dueDate" gorm:"type:date;index"`
+	ReturnDate          *time.Time        `json:"returnDate" gorm:"type:date"`
+	ReturnTime          *time.Time        `json:"returnTime" gorm:"type:time"`
+	ReturnCondition     *string           `json:"returnCondition" gorm:"type:text"`          // estado de la pieza al devolverla
+	ReceivedById        *int              `json:"receivedById" gorm:"column:received_by_id"` // usuario que recibió la devolución
+	ArtefactId          *int              `json:"artefactId" gorm:"column:artefact_id;index"`
+	Artefact            *ArtefactModel    `json:"artefact" gorm:"foreignKey:ArtefactId;references:ID"`
+	RequesterId         *int              `json:"requesterId" gorm:"column:requester_id"`
+	Requester           *RequesterModel   `json:"requester" gorm:"foreignKey:RequesterId;references:Id"`
+	InstitutionId       *int              `json:"institutionId" gorm:"column:institution_id;index"` // institución a la que se atribuye el préstamo
+	Institution         *InstitutionModel `json:"institution,omitempty" gorm:"foreignKey:InstitutionId;references:Id"`
+	LoanHeaderId        *int              `json:"loanHeaderId" gorm:"column:loan_header_id;index"`   // nil en préstamos individuales
+	NotificationsOptOut bool              `json:"notificationsOptOut" gorm:"not null;default:false"` // no enviar recordatorios por correo
+	Status              string            `json:"status" gorm:"-"`
+}
+
+// Kinds of generated loan documents
+const (
+	LoanDocumentAgreement         = "agreement"
+	LoanDocumentConditionDispatch = "condition_dispatch"
+	LoanDocumentConditionReturn   = "condition_return"
+)
+
+// LoanDocumentModel is a PDF generated for a loan (agreement, condition reports) kept in the file storage
+type LoanDocumentModel struct {
+	Id          int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	LoanId      int       `json:"loanId" gorm:"column:loan_id;not null;index"`
+	Kind        string    `json:"kind" gorm:"type:varchar(30);not null"`
+	Filename    string    `json:"filename" gorm:"type:varchar(255);not null"`
+	FilePath    string    `json:"filePath" gorm:"column:file_path;type:varchar(500);not null"`
+	Size        int64     `json:"size"`
+	Sha256      string    `json:"sha256" gorm:"column:sha256;type:varchar(64)"`
+	Condition   *string   `json:"condition" gorm:"type:text"` // estado registrado en los informes de condición
+	CreatedById *int      `json:"createdById" gorm:"column:created_by_id"`
+	CreatedAt   time.Time `json:"createdAt"`
 }
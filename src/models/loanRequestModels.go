@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Loan request statuses
+const (
+	LoanRequestStatusDraft      = "draft"
+	LoanRequestStatusSubmitted  = "submitted"
+	LoanRequestStatusApproved   = "approved"
+	LoanRequestStatusRejected   = "rejected"
+	LoanRequestStatusDispatched = "dispatched"
+	LoanRequestStatusCancelled  = "cancelled"
+)
+
+// LoanRequestModel is a loan asked for by a requester. It goes draft -> submitted -> approved/rejected,
+// and an approved request becomes a loan (LoanHeaderModel) when the pieces are dispatched.
+type LoanRequestModel struct {
+	Id                 int                     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Status             string                  `json:"status" gorm:"type:varchar(20);not null;index"`
+	RequesterId        *int                    `json:"requesterId" gorm:"column:requester_id;index"`
+	Requester          *RequesterModel         `json:"requester" gorm:"foreignKey:RequesterId;references:Id"`
+	InstitutionId      *int                    `json:"institutionId" gorm:"column:institution_id;index"`
+	Institution        *InstitutionModel       `json:"institution,omitempty" gorm:"foreignKey:InstitutionId;references:Id"`
+	Purpose            *string                 `json:"purpose" gorm:"type:text"`
+	RequestedFrom      time.Time               `json:"requestedFrom" gorm:"type:date;not null"`
+	RequestedUntil     *time.Time              `json:"requestedUntil" gorm:"type:date"`
+	AgreementReference *string                 `json:"agreementReference" gorm:"type:varchar(255)"`
+	Observations       *string                 `json:"observations" gorm:"type:text"`
+	CreatedById        *int                    `json:"createdById" gorm:"column:created_by_id"`
+	ReviewedById       *int                    `json:"reviewedById" gorm:"column:reviewed_by_id"`
+	ReviewedAt         *time.Time              `json:"reviewedAt"`
+	LoanHeaderId       *int                    `json:"loanHeaderId" gorm:"column:loan_header_id"` // préstamo generado al despachar
+	LoanHeader         *LoanHeaderModel        `json:"loanHeader,omitempty" gorm:"foreignKey:LoanHeaderId;references:Id"`
+	Items              []LoanRequestItemModel  `json:"items" gorm:"foreignKey:LoanRequestId;constraint:OnDelete:CASCADE;"`
+	Events             []LoanRequestEventModel `json:"events" gorm:"foreignKey:LoanRequestId;constraint:OnDelete:CASCADE;"`
+	CreatedAt          time.Time               `json:"createdAt"`
+	UpdatedAt          time.Time               `json:"updatedAt"`
+}
+
+// LoanRequestItemModel is one of the pieces asked for in a loan request
+type LoanRequestItemModel struct {
+	Id            int            `json:"id" gorm:"primaryKey;autoIncrement"`
+	LoanRequestId int            `json:"loanRequestId" gorm:"column:loan_request_id;not null;uniqueIndex:idx_loan_request_item"`
+	ArtefactId    int            `json:"artefactId" gorm:"column:artefact_id;not null;uniqueIndex:idx_loan_request_item;index"`
+	Artefact      *ArtefactModel `json:"artefact" gorm:"foreignKey:ArtefactId;references:ID"`
+}
+
+// LoanRequestEventModel records a status transition or comment on a loan request
+type LoanRequestEventModel struct {
+	Id            int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	LoanRequestId int       `json:"loanRequestId" gorm:"column:loan_request_id;not null;index"`
+	FromStatus    string    `json:"fromStatus" gorm:"type:varchar(20)"`
+	ToStatus      string    `json:"toStatus" gorm:"type:varchar(20);not null"`
+	Comment       *string   `json:"comment" gorm:"type:text"`
+	UserId        *int      `json:"userId" gorm:"column:user_id"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Sources of a location assignment
+const (
+	LocationSourceImport   = "import"   // importación desde Excel
+	LocationSourceManual   = "manual"   // alta o edición de la pieza
+	LocationSourceBaseline = "baseline" // ubicación vigente cuando empezó a registrarse el historial
+)
+
+// LocationAssignmentModel records a change of the artefact's location made outside internal movements,
+// so the location history can be rebuilt (movements and loans have their own records)
+type LocationAssignmentModel struct {
+	Id                 int                    `json:"id" gorm:"primaryKey;autoIncrement"`
+	ArtefactId         int                    `json:"artefactId" gorm:"column:artefact_id;not null;index"`
+	PhysicalLocationId *int                   `json:"physicalLocationId" gorm:"column:physical_location_id;index"`
+	PhysicalLocation   *PhysicalLocationModel `json:"physicalLocation" gorm:"foreignKey:PhysicalLocationId;references:ID"`
+	Source             string                 `json:"source" gorm:"type:varchar(20);not null"`
+	CreatedAt          time.Time              `json:"createdAt" gorm:"index"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Kinds of loan reminder emails
+const (
+	NotificationKindDueSoon = "due_soon" // antes de la fecha pactada
+	NotificationKindOverdue = "overdue"  // después de la fecha pactada, se repite periódicamente
+)
+
+// Recipients of loan reminder emails
+const (
+	NotificationRecipientRequester = "requester"
+	NotificationRecipientStaff     = "staff"
+)
+
+// Delivery statuses of a notification
+const (
+	NotificationStatusSent   = "sent"
+	NotificationStatusFailed = "failed"
+)
+
+// NotificationLogModel records every reminder email sent (or attempted) for a loan
+type NotificationLogModel struct {
+	Id            int        `json:"id" gorm:"primaryKey;autoIncrement"`
+	LoanId        int        `json:"loanId" gorm:"column:loan_id;not null;index"`
+	Kind          string     `json:"kind" gorm:"type:varchar(20);not null"`
+	RecipientType string     `json:"recipientType" gorm:"type:varchar(20);not null"`
+	Recipient     string     `json:"recipient" gorm:"type:varchar(255);not null"`
+	Subject       string     `json:"subject" gorm:"type:varchar(255);not null"`
+	DueDate       *time.Time `json:"dueDate" gorm:"type:date"` // fecha pactada al momento del envío
+	Status        string     `json:"status" gorm:"type:varchar(20);not null"`
+	Error         *string    `json:"error" gorm:"type:text"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
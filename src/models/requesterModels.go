@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type RequesterType string
 
 const (
@@ -9,11 +11,16 @@ const (
 )
 
 type RequesterModel struct {
-	Id          int           `json:"id" gorm:"primaryKey;autoIncrement"`
-	Type        RequesterType `json:"type" gorm:"column:type;type:varchar(50);not null"`
-	FirstName   *string        `json:"firstname" gorm:"column:firstname;type:varchar(50)"`
-	LastName    *string        `json:"lastname" gorm:"column:lastname;type:varchar(50)"`
-	Dni         *string        `json:"dni" gorm:"column:dni;type:varchar(20);unique"`
-	Email       *string        `json:"email" gorm:"column:email;type:varchar(100)"`
-	PhoneNumber *string        `json:"phoneNumber" gorm:"column:phone_number;type:varchar(20)"`
+	Id            int               `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type          RequesterType     `json:"type" gorm:"column:type;type:varchar(50);not null"`
+	FirstName     *string           `json:"firstname" gorm:"column:firstname;type:varchar(50)"`
+	LastName      *string           `json:"lastname" gorm:"column:lastname;type:varchar(50)"`
+	Dni           *string           `json:"dni" gorm:"column:dni;type:varchar(20);unique"`
+	Email         *string           `json:"email" gorm:"column:email;type:varchar(100)"`
+	PhoneNumber   *string           `json:"phoneNumber" gorm:"column:phone_number;type:varchar(20)"`
+	InstitutionId *int              `json:"institutionId" gorm:"column:institution_id;index"` // institución a la que pertenece (contacto)
+	Institution   *InstitutionModel `json:"institution,omitempty" gorm:"foreignKey:InstitutionId;references:Id"`
+	Position      *string           `json:"position" gorm:"type:varchar(100)"` // cargo en la institución
+	CreatedAt     *time.Time        `json:"createdAt" gorm:"autoCreateTime"`
+	AnonymizedAt  *time.Time        `json:"anonymizedAt"` // datos personales borrados por retención o a pedido
 }
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Reservation statuses
+const (
+	ReservationStatusActive    = "active"
+	ReservationStatusCancelled = "cancelled"
+	ReservationStatusFulfilled = "fulfilled" // la pieza salió en préstamo
+)
+
+// ReservationModel books an artefact for a period (future exhibition, research visit, approved loan request)
+type ReservationModel struct {
+	Id            int             `json:"id" gorm:"primaryKey;autoIncrement"`
+	ArtefactId    int             `json:"artefactId" gorm:"column:artefact_id;not null;index"`
+	Artefact      *ArtefactModel  `json:"artefact,omitempty" gorm:"foreignKey:ArtefactId;references:ID"`
+	StartDate     time.Time       `json:"startDate" gorm:"type:date;not null"`
+	EndDate       *time.Time      `json:"endDate" gorm:"type:date"` // nil: sin fecha de fin
+	Status        string          `json:"status" gorm:"type:varchar(20);not null;index"`
+	Purpose       *string         `json:"purpose" gorm:"type:text"`
+	RequesterId   *int            `json:"requesterId" gorm:"column:requester_id"`
+	Requester     *RequesterModel `json:"requester,omitempty" gorm:"foreignKey:RequesterId;references:Id"`
+	LoanRequestId *int            `json:"loanRequestId" gorm:"column:loan_request_id;index"` // reserva creada al aprobar una solicitud
+	CreatedById   *int            `json:"createdById" gorm:"column:created_by_id"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Stocktake session statuses
+const (
+	StocktakeStatusOpen   = "open"
+	StocktakeStatusClosed = "closed"
+)
+
+// StocktakeSessionModel is a physical inventory check, scoped to some shelves or (without shelves) the whole store
+type StocktakeSessionModel struct {
+	Id          int                      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Notes       *string                  `json:"notes" gorm:"type:text"`
+	Status      string                   `json:"status" gorm:"type:varchar(20);not null;index"`
+	CreatedById *int                     `json:"createdById" gorm:"column:created_by_id"`
+	CreatedAt   time.Time                `json:"createdAt"`
+	ClosedAt    *time.Time               `json:"closedAt"`
+	ClosedById  *int                     `json:"closedById" gorm:"column:closed_by_id"`
+	Shelves     []StocktakeShelfModel    `json:"shelves" gorm:"foreignKey:SessionId"`
+	Locations   []StocktakeLocationModel `json:"locations,omitempty" gorm:"foreignKey:SessionId"`
+	Scans       []StocktakeScanModel     `json:"scans,omitempty" gorm:"foreignKey:SessionId"`
+	Corrections []MovementGroupModel     `json:"corrections,omitempty" gorm:"foreignKey:StocktakeSessionId"`
+}
+
+// StocktakeShelfModel is a shelf included in the scope of a stocktake session
+type StocktakeShelfModel struct {
+	Id        int         `json:"id" gorm:"primaryKey;autoIncrement"`
+	SessionId int         `json:"sessionId" gorm:"column:session_id;not null;uniqueIndex:idx_stocktake_shelf"`
+	ShelfId   int         `json:"shelfId" gorm:"column:shelf_id;not null;uniqueIndex:idx_stocktake_shelf"`
+	Shelf     *ShelfModel `json:"shelf,omitempty" gorm:"foreignKey:ShelfId;references:ID"`
+}
+
+// StocktakeLocationModel records that a location was counted in the session, even if nothing was found there
+type StocktakeLocationModel struct {
+	Id                 int                    `json:"id" gorm:"primaryKey;autoIncrement"`
+	SessionId          int                    `json:"sessionId" gorm:"column:session_id;not null;uniqueIndex:idx_stocktake_location"`
+	PhysicalLocationId int                    `json:"physicalLocationId" gorm:"column:physical_location_id;not null;uniqueIndex:idx_stocktake_location"`
+	PhysicalLocation   *PhysicalLocationModel `json:"physicalLocation,omitempty" gorm:"foreignKey:PhysicalLocationId;references:ID"`
+	CountedById        *int                   `json:"countedById" gorm:"column:counted_by_id"`
+	CountedAt          time.Time              `json:"countedAt"`
+}
+
+// StocktakeScanModel is an inventory code found at a location during the session
+type StocktakeScanModel struct {
+	Id                 int            `json:"id" gorm:"primaryKey;autoIncrement"`
+	SessionId          int            `json:"sessionId" gorm:"column:session_id;not null;index"`
+	PhysicalLocationId int            `json:"physicalLocationId" gorm:"column:physical_location_id;not null"`
+	Code               string         `json:"code" gorm:"type:varchar(255);not null"`     // tal como se tipeó o escaneó
+	ArtefactId         *int           `json:"artefactId" gorm:"column:artefact_id;index"` // nil: el código no corresponde a ninguna pieza
+	Artefact           *ArtefactModel `json:"artefact,omitempty" gorm:"foreignKey:ArtefactId;references:ID"`
+	ScannedById        *int           `json:"scannedById" gorm:"column:scanned_by_id"`
+	CreatedAt          time.Time      `json:"createdAt"`
+}
@@ -1,9 +1,24 @@
 package models
 
+// User roles
+const (
+	RoleAdmin     = "admin"     // gestiona usuarios y todo lo demás
+	RoleRegistrar = "registrar" // aprueba, rechaza y despacha solicitudes de préstamo
+	RoleStaff     = "staff"     // carga datos y crea solicitudes
+)
+
 type UserModel struct {
 	Id       int    `json:"id" gorm:"primaryKey;autoIncrement"`
 	Username string `json:"username" gorm:"column:username;type:varchar(255);not null"`
 	Password string `json:"password" gorm:"type:varchar(100);not null"`
+	Role     string `json:"role" gorm:"type:varchar(20)"`
+	// CalendarTokenHash is the SHA-256 of the secret that authenticates the user's .ics feed
+	CalendarTokenHash *string `json:"-" gorm:"column:calendar_token_hash;type:varchar(64);uniqueIndex"`
+}
+
+// UpdateUserRoleRequest changes the role of a user
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin registrar staff"`
 }
 
 type LoginRequest struct {
@@ -27,10 +27,26 @@ func SetupArtefactRoutes(router *gin.Engine, service *services.ArtefactService)
 		artefactGroup.POST("/:id/picture", controller.UploadPicture)
 		artefactGroup.POST("/:id/historical-record", controller.UploadHistoricalRecord)
 
-		// Serve
+		// Serve (primary file)
 		artefactGroup.GET("/:id/picture", controller.ServePicture)
 		artefactGroup.GET("/:id/historical-record", controller.ServeHistoricalRecord)
 
+		// Pictures
+		artefactGroup.GET("/:id/pictures", controller.ListPictures)
+		artefactGroup.POST("/:id/pictures", controller.UploadPicture)
+		artefactGroup.PUT("/:id/pictures/order", controller.ReorderPictures)
+		artefactGroup.GET("/:id/pictures/:pictureId", controller.ServePictureByID)
+		artefactGroup.PUT("/:id/pictures/:pictureId", controller.UpdatePicture)
+		artefactGroup.DELETE("/:id/pictures/:pictureId", controller.DeletePicture)
+
+		// Historical records
+		artefactGroup.GET("/:id/historical-records", controller.ListHistoricalRecords)
+		artefactGroup.POST("/:id/historical-records", controller.UploadHistoricalRecord)
+		artefactGroup.PUT("/:id/historical-records/order", controller.ReorderHistoricalRecords)
+		artefactGroup.GET("/:id/historical-records/:recordId", controller.ServeHistoricalRecordByID)
+		artefactGroup.PUT("/:id/historical-records/:recordId", controller.UpdateHistoricalRecord)
+		artefactGroup.DELETE("/:id/historical-records/:recordId", controller.DeleteHistoricalRecord)
+
 		// Summaries (endpoint singular para consistencia con frontend)
 		artefactGroup.GET("/summaries", controller.GetArtefactSummaries)
 
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/ARQAP/ARQAP-Backend/src/controllers"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupCalendarFeedRoutes(router *gin.Engine, service *services.CalendarFeedService) {
+	calendarFeedController := controllers.NewCalendarFeedController(service)
+
+	calendar := router.Group("/calendar")
+	{
+		// Public route: calendar apps authenticate with the token in the URL
+		calendar.GET("/:token/feed.ics", calendarFeedController.GetCalendarFeed)
+
+		// Protected routes. The feed lists every loan and reservation, so only these roles can subscribe
+		calendar.POST("/token", middleware.AuthMiddleware(), middleware.RequireRole(models.RoleAdmin, models.RoleRegistrar),
+			calendarFeedController.CreateCalendarToken)
+		calendar.DELETE("/token", middleware.AuthMiddleware(), calendarFeedController.RevokeCalendarToken)
+	}
+}
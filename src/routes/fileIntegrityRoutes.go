@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/ARQAP/ARQAP-Backend/src/controllers"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupFileIntegrityRoutes(router *gin.Engine, service *services.FileIntegrityService) {
+
+	fileIntegrityController := controllers.NewFileIntegrityController(service)
+
+	// Protected routes: storage maintenance is for admins only
+	files := router.Group("/admin/files")
+	files.Use(middleware.AuthMiddleware(), middleware.RequireRole(models.RoleAdmin))
+	{
+		files.GET("/fixity", fileIntegrityController.GetLastFixityReport)
+		files.POST("/fixity/run", fileIntegrityController.RunFixityCheck)
+	}
+}
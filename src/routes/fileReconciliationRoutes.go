@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/ARQAP/ARQAP-Backend/src/controllers"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupFileReconciliationRoutes(router *gin.Engine, service *services.FileReconciliationService) {
+
+	fileReconciliationController := controllers.NewFileReconciliationController(service)
+
+	// Protected routes: storage maintenance is for admins only
+	reconciliation := router.Group("/admin/files/reconciliation")
+	reconciliation.Use(middleware.AuthMiddleware(), middleware.RequireRole(models.RoleAdmin))
+	{
+		reconciliation.GET("/", fileReconciliationController.ScanOrphans)
+		reconciliation.POST("/", fileReconciliationController.ReconcileOrphans)
+	}
+}
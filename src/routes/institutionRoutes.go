@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/ARQAP/ARQAP-Backend/src/controllers"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupInstitutionRoutes(router *gin.Engine, service *services.InstitutionService) {
+	institutionController := controllers.NewInstitutionController(service)
+
+	// Protected routes
+	institutions := router.Group("/institutions")
+	institutions.Use(middleware.AuthMiddleware())
+	{
+		institutions.GET("", institutionController.GetAllInstitutions)
+		institutions.GET("/:id", institutionController.GetInstitutionByID)
+		institutions.GET("/:id/contacts", institutionController.GetInstitutionContacts)
+		institutions.POST("", institutionController.CreateInstitution)
+		institutions.PUT("/:id", institutionController.UpdateInstitution)
+		institutions.DELETE("/:id", institutionController.DeleteInstitution)
+	}
+}
@@ -21,6 +21,12 @@ func SetupInternalMovementRoutes(router *gin.Engine, service *services.InternalM
 		internalMovementGroup.POST("/", internalMovementController.CreateInternalMovement)
 		internalMovementGroup.POST("/batch", internalMovementController.CreateBatchInternalMovements)
 		internalMovementGroup.PUT("/:id", internalMovementController.UpdateInternalMovement)
+		internalMovementGroup.POST("/:id/return", internalMovementController.ReturnInternalMovement)
+		internalMovementGroup.POST("/:id/void", internalMovementController.VoidInternalMovement)
+		internalMovementGroup.POST("/groups", internalMovementController.CreateMovementGroup)
+		internalMovementGroup.GET("/groups/:id", internalMovementController.GetMovementGroupByID)
+		internalMovementGroup.POST("/groups/:id/return", internalMovementController.ReturnMovementGroup)
+		internalMovementGroup.POST("/groups/:id/cancel", internalMovementController.CancelMovementGroup)
 		internalMovementGroup.DELETE("/:id", internalMovementController.DeleteInternalMovement)
 	}
 }
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/ARQAP/ARQAP-Backend/src/controllers"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupLabelRoutes(router *gin.Engine, service *services.LabelService) {
+	labelController := controllers.NewLabelController(service)
+
+	// Protected routes
+	label := router.Group("/labels")
+	label.Use(middleware.AuthMiddleware())
+	{
+		label.GET("/artefacts/:id/qr", labelController.GetArtefactQR)
+		label.GET("/locations/:id/qr", labelController.GetLocationQR)
+		label.POST("/sheet", labelController.GenerateLabelSheet)
+		label.GET("/resolve", labelController.ResolveLabel)
+	}
+}
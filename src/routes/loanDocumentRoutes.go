@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/ARQAP/ARQAP-Backend/src/controllers"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupLoanDocumentRoutes(router *gin.Engine, service *services.LoanDocumentService) {
+	loanDocumentController := controllers.NewLoanDocumentController(service)
+
+	// Protected routes
+	documents := router.Group("/loans/:id/documents")
+	documents.Use(middleware.AuthMiddleware())
+	{
+		documents.GET("", loanDocumentController.GetLoanDocuments)
+		documents.GET("/:documentId", loanDocumentController.DownloadLoanDocument)
+		documents.POST("/agreement", loanDocumentController.GenerateLoanAgreement)
+		documents.POST("/condition-report", loanDocumentController.GenerateConditionReport)
+	}
+}
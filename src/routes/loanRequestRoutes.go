@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"github.com/ARQAP/ARQAP-Backend/src/controllers"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupLoanRequestRoutes(router *gin.Engine, service *services.LoanRequestService) {
+	loanRequestController := controllers.NewLoanRequestController(service)
+
+	// Only these roles can review and dispatch requests
+	reviewers := middleware.RequireRole(models.RoleAdmin, models.RoleRegistrar)
+
+	// Protected routes
+	loanRequests := router.Group("/loan-requests")
+	loanRequests.Use(middleware.AuthMiddleware())
+	{
+		loanRequests.GET("/", loanRequestController.GetAllLoanRequests)
+		loanRequests.GET("/:id", loanRequestController.GetLoanRequestByID)
+		loanRequests.POST("/", loanRequestController.CreateLoanRequest)
+		loanRequests.PUT("/:id", loanRequestController.UpdateLoanRequest)
+		loanRequests.DELETE("/:id", loanRequestController.DeleteLoanRequest)
+		loanRequests.POST("/:id/comments", loanRequestController.AddLoanRequestComment)
+		loanRequests.POST("/:id/submit", loanRequestController.SubmitLoanRequest)
+		loanRequests.POST("/:id/cancel", loanRequestController.CancelLoanRequest)
+		loanRequests.POST("/:id/approve", reviewers, loanRequestController.ApproveLoanRequest)
+		loanRequests.POST("/:id/reject", reviewers, loanRequestController.RejectLoanRequest)
+		loanRequests.POST("/:id/dispatch", reviewers, loanRequestController.DispatchLoanRequest)
+	}
+}
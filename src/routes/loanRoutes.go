@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/ARQAP/ARQAP-Backend/src/controllers"
 	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
 	"github.com/ARQAP/ARQAP-Backend/src/services"
 	"github.com/gin-gonic/gin"
 )
@@ -11,14 +12,32 @@ func SetupLoanRoutes(router *gin.Engine, service *services.LoanService) {
 
 	loanController := controllers.NewLoanController(service)
 
+	// Only these roles can open, change or return loans, as with dispatching a loan request
+	lenders := middleware.RequireRole(models.RoleAdmin, models.RoleRegistrar)
+	// Deleting a loan erases its record and the repair rewrites every artefact, so only admins can
+	admins := middleware.RequireRole(models.RoleAdmin)
+
 	// Protected routes
 	mention := router.Group("/loans")
 	mention.Use(middleware.AuthMiddleware())
 	{
 		mention.GET("/", loanController.GetAllLoans)
+		mention.GET("/overdue", loanController.GetOverdueLoans)
+		mention.GET("/availability-check", loanController.CheckAvailabilityConsistency)
+		mention.POST("/availability-check/repair", admins, loanController.RepairAvailability)
 		mention.GET("/:id", loanController.GetLoanByID)
-		mention.POST("/", loanController.CreateLoan)
-		mention.PUT("/:id", loanController.UpdateLoan)
-		mention.DELETE("/:id", loanController.DeleteLoan)
+		mention.POST("/", lenders, loanController.CreateLoan)
+		mention.PUT("/:id", lenders, loanController.UpdateLoan)
+		mention.POST("/:id/return", lenders, loanController.ReturnLoan)
+		mention.DELETE("/:id", admins, loanController.DeleteLoan)
+
+		// Préstamos de varias piezas (cabecera + una línea por pieza)
+		mention.GET("/headers", loanController.GetAllLoanHeaders)
+		mention.GET("/headers/:id", loanController.GetLoanHeaderByID)
+		mention.POST("/headers", lenders, loanController.CreateLoanHeader)
+		mention.POST("/headers/:id/lines", lenders, loanController.AddLoanLines)
+		mention.POST("/headers/:id/return", lenders, loanController.ReturnLoanLines)
+		mention.PUT("/headers/:id", lenders, loanController.UpdateLoanHeader)
+		mention.DELETE("/headers/:id", admins, loanController.DeleteLoanHeader)
 	}
 }
\ No newline at end of file
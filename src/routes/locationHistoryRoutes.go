@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"github.com/ARQAP/ARQAP-Backend/src/controllers"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupLocationHistoryRoutes(router *gin.Engine, service *services.LocationHistoryService) {
+	locationHistoryController := controllers.NewLocationHistoryController(service)
+
+	// Protected routes
+	artefact := router.Group("/artefacts")
+	artefact.Use(middleware.AuthMiddleware())
+	{
+		artefact.GET("/:id/location", locationHistoryController.GetArtefactLocationAt)
+		artefact.GET("/:id/location-history", locationHistoryController.GetLocationHistory)
+	}
+
+	// Also under /shelves, the spelling the rest of the API should have used
+	for _, prefix := range []string{"/shelfs", "/shelves"} {
+		shelf := router.Group(prefix)
+		shelf.Use(middleware.AuthMiddleware())
+		{
+			shelf.GET("/:id/contents", locationHistoryController.GetShelfContentsAt)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/ARQAP/ARQAP-Backend/src/controllers"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupNotificationRoutes(router *gin.Engine, service *services.NotificationService) {
+	notificationController := controllers.NewNotificationController(service)
+
+	// Protected routes
+	notifications := router.Group("/notifications")
+	notifications.Use(middleware.AuthMiddleware())
+	{
+		notifications.GET("", notificationController.GetNotificationLog)
+		notifications.POST("/loan-reminders/run",
+			middleware.RequireRole(models.RoleAdmin, models.RoleRegistrar), notificationController.RunLoanReminders)
+	}
+
+	router.PUT("/loans/:id/notifications", middleware.AuthMiddleware(), notificationController.SetLoanNotifications)
+}
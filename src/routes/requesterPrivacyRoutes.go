@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/ARQAP/ARQAP-Backend/src/controllers"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupRequesterPrivacyRoutes(router *gin.Engine, service *services.RequesterPrivacyService) {
+	requesterPrivacyController := controllers.NewRequesterPrivacyController(service)
+
+	// Protected routes: personal data is handled by registrars, erasure only by admins
+	requester := router.Group("/requesters")
+	requester.Use(middleware.AuthMiddleware())
+	{
+		requester.GET("/:id/export",
+			middleware.RequireRole(models.RoleAdmin, models.RoleRegistrar), requesterPrivacyController.ExportRequesterData)
+		requester.POST("/:id/anonymize", middleware.RequireRole(models.RoleAdmin), requesterPrivacyController.AnonymizeRequester)
+		requester.GET("/retention/candidates", middleware.RequireRole(models.RoleAdmin), requesterPrivacyController.GetRetentionCandidates)
+		requester.POST("/retention/run", middleware.RequireRole(models.RoleAdmin), requesterPrivacyController.RunRetention)
+	}
+}
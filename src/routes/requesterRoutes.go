@@ -17,6 +17,7 @@ func SetupRequesterRoutes(router *gin.Engine, service *services.RequesterService
 	{
 		requester.GET("/", requesterController.GetAllRequesters)
 		requester.GET("/:id", requesterController.GetRequesterByID)
+		requester.GET("/:id/history", requesterController.GetRequesterHistory)
 		requester.POST("/", requesterController.CreateRequester)
 		requester.PUT("/:id", requesterController.UpdateRequester)
 		requester.DELETE("/:id", requesterController.DeleteRequester)
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/ARQAP/ARQAP-Backend/src/controllers"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupReservationRoutes(router *gin.Engine, service *services.ReservationService) {
+	reservationController := controllers.NewReservationController(service)
+
+	// Protected routes
+	reservations := router.Group("/reservations")
+	reservations.Use(middleware.AuthMiddleware())
+	{
+		reservations.GET("/", reservationController.GetAllReservations)
+		reservations.GET("/:id", reservationController.GetReservationByID)
+		reservations.POST("/", reservationController.CreateReservation)
+		reservations.PUT("/:id", reservationController.UpdateReservation)
+		reservations.POST("/:id/cancel", reservationController.CancelReservation)
+	}
+
+	// Busy periods of an artefact (reservations, loans and internal movements)
+	router.GET("/artefacts/:id/calendar", middleware.AuthMiddleware(), reservationController.GetArtefactCalendar)
+}
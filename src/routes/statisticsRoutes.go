@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/ARQAP/ARQAP-Backend/src/controllers"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupStatisticsRoutes(router *gin.Engine, service *services.StatisticsService) {
+	statisticsController := controllers.NewStatisticsController(service)
+
+	// Protected routes
+	statistics := router.Group("/statistics")
+	statistics.Use(middleware.AuthMiddleware())
+	{
+		statistics.GET("/loans/per-month", statisticsController.GetLoansPerMonth)
+		statistics.GET("/loans/per-requester-type", statisticsController.GetLoansPerRequesterType)
+		statistics.GET("/loans/duration", statisticsController.GetLoanDuration)
+		statistics.GET("/artefacts/most-requested", statisticsController.GetMostRequestedArtefacts)
+		statistics.GET("/collections/most-requested", statisticsController.GetMostRequestedCollections)
+		statistics.GET("/movements/per-shelf", statisticsController.GetMovementsPerShelf)
+	}
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/ARQAP/ARQAP-Backend/src/controllers"
+	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/services"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupStocktakeRoutes(router *gin.Engine, service *services.StocktakeService) {
+	stocktakeController := controllers.NewStocktakeController(service)
+
+	// Protected routes
+	stocktake := router.Group("/stocktakes")
+	stocktake.Use(middleware.AuthMiddleware())
+	{
+		stocktake.GET("/", stocktakeController.GetAllStocktakes)
+		stocktake.POST("/", stocktakeController.CreateStocktake)
+		stocktake.GET("/:id", stocktakeController.GetStocktakeByID)
+		stocktake.POST("/:id/scans", stocktakeController.SubmitStocktakeScans)
+		stocktake.GET("/:id/report", stocktakeController.GetStocktakeReport)
+		stocktake.POST("/:id/corrections", stocktakeController.ApplyStocktakeCorrections)
+		stocktake.POST("/:id/close", stocktakeController.CloseStocktake)
+	}
+}
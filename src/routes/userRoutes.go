@@ -5,6 +5,7 @@ import (
 	"github.com/ARQAP/ARQAP-Backend/src/controllers"
 	"github.com/ARQAP/ARQAP-Backend/src/services"
 	"github.com/ARQAP/ARQAP-Backend/src/middleware"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
 )
 
 func SetupUserRoutes(router *gin.Engine, service *services.UserService) {
@@ -20,5 +21,6 @@ func SetupUserRoutes(router *gin.Engine, service *services.UserService) {
     user.Use(middleware.AuthMiddleware())
     {
         user.DELETE("/:id", UserController.DeleteUser)
+        user.PUT("/:id/role", middleware.RequireRole(models.RoleAdmin), UserController.UpdateUserRole)
     }
 }
\ No newline at end of file
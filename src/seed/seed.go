@@ -14,12 +14,16 @@ func Seed(db *gorm.DB) {
 	result := db.Where("username = ?", "arqap").First(&user)
 	if result.Error == nil {
 		log.Println("User 'arqap' already exists")
+		if user.Role != models.RoleAdmin {
+			db.Model(&user).Update("role", models.RoleAdmin)
+		}
 	} else {
 		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("arqap"), bcrypt.DefaultCost)
 
 		newUser := models.UserModel{
 			Username: "arqap",
 			Password: string(hashedPassword),
+			Role:     models.RoleAdmin,
 		}
 		if err := db.Create(&newUser).Error; err != nil {
 			log.Printf("Failed to create user: %v\n", err)
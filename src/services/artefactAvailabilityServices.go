@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Artefact availability is derived from loans: a piece is available when it has no open loan
+// (return_date IS NULL). The available column is only a cache of that state and is written
+// exclusively here, always with the artefact row locked, so concurrent loans can't both take it.
+
+// lockArtefact loads an artefact with SELECT ... FOR UPDATE
+func lockArtefact(tx *gorm.DB, artefactId int) (*models.ArtefactModel, error) {
+	var artefact models.ArtefactModel
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&artefact, artefactId).Error; err != nil {
+		return nil, err
+	}
+	return &artefact, nil
+}
+
+// countOpenLoans returns how many loans of the artefact are not returned yet
+func countOpenLoans(tx *gorm.DB, artefactId int) (int64, error) {
+	var count int64
+	err := tx.Model(&models.LoanModel{}).
+		Where("artefact_id = ? AND return_date IS NULL", artefactId).
+		Count(&count).Error
+	return count, err
+}
+
+// reserveArtefactForLoan locks the artefact, checks it exists, has no open loan, isn't reserved
+// by someone else nor moved internally during the loan period, and marks it as lent
+func reserveArtefactForLoan(tx *gorm.DB, artefactId int, loanDate time.Time, dueDate *time.Time) error {
+	if _, err := lockArtefact(tx, artefactId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrArtefactNotFound
+		}
+		return err
+	}
+
+	openLoans, err := countOpenLoans(tx, artefactId)
+	if err != nil {
+		return err
+	}
+	if openLoans > 0 {
+		return ErrArtefactNotAvailable
+	}
+
+	if err := checkLoanPeriod(tx, artefactId, loanDate, dueDate); err != nil {
+		return err
+	}
+
+	return tx.Model(&models.ArtefactModel{}).
+		Where("id = ?", artefactId).
+		Update("available", false).Error
+}
+
+// syncArtefactAvailability recomputes the available flag of an artefact from its open loans.
+// Must run after the loan change (return, delete) inside the same transaction.
+func syncArtefactAvailability(tx *gorm.DB, artefactId int) error {
+	if _, err := lockArtefact(tx, artefactId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	openLoans, err := countOpenLoans(tx, artefactId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&models.ArtefactModel{}).
+		Where("id = ?", artefactId).
+		Update("available", openLoans == 0).Error
+}
+
+// availabilityMismatchQuery lists the artefacts whose available flag disagrees with their open loans
+const availabilityMismatchQuery = `
+	SELECT a.id AS artefact_id, a.name, a.available, COUNT(l.id) AS open_loans
+	FROM artefact_models a
+	LEFT JOIN loan_models l ON l.artefact_id = a.id AND l.return_date IS NULL
+	GROUP BY a.id, a.name, a.available
+	HAVING (a.available AND COUNT(l.id) > 0) OR (NOT a.available AND COUNT(l.id) = 0)
+	ORDER BY a.id`
+
+// CheckAvailabilityConsistency lists the artefacts whose available flag disagrees with their loans
+func (s *LoanService) CheckAvailabilityConsistency() ([]dtos.AvailabilityMismatchDTO, error) {
+	mismatches := []dtos.AvailabilityMismatchDTO{}
+	if err := s.db.Raw(availabilityMismatchQuery).Scan(&mismatches).Error; err != nil {
+		return nil, err
+	}
+	return mismatches, nil
+}
+
+// RepairAvailability recomputes the available flag of every inconsistent artefact and returns what was fixed
+func (s *LoanService) RepairAvailability() ([]dtos.AvailabilityMismatchDTO, error) {
+	mismatches, err := s.CheckAvailabilityConsistency()
+	if err != nil {
+		return nil, err
+	}
+
+	fixed := make([]int, 0, len(mismatches))
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, mismatch := range mismatches {
+			if err := syncArtefactAvailability(tx, mismatch.ArtefactId); err != nil {
+				return err
+			}
+			fixed = append(fixed, mismatch.ArtefactId)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateArtefactsCache(fixed)
+	return mismatches, nil
+}
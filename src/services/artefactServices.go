@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -18,6 +19,7 @@ import (
 
 	"github.com/ARQAP/ARQAP-Backend/src/dtos"
 	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/storage"
 	"github.com/ARQAP/ARQAP-Backend/src/utils"
 	excelize "github.com/xuri/excelize/v2"
 	"gorm.io/gorm"
@@ -36,6 +38,7 @@ type ImportResult struct {
 
 type ArtefactService struct {
 	db    *gorm.DB
+	store storage.Storage
 	cache map[string]*CacheEntry
 	mutex sync.RWMutex
 }
@@ -51,9 +54,10 @@ type CreateArtefactWithMentionsDTO struct {
 	Mentions           []models.MentionModel    `json:"mentions"`
 }
 
-func NewArtefactService(db *gorm.DB) *ArtefactService {
+func NewArtefactService(db *gorm.DB, store storage.Storage) *ArtefactService {
 	service := &ArtefactService{
 		db:    db,
+		store: store,
 		cache: make(map[string]*CacheEntry),
 	}
 
@@ -63,6 +67,11 @@ func NewArtefactService(db *gorm.DB) *ArtefactService {
 	return service
 }
 
+// Storage returns the backend where pictures and historical records are stored
+func (s *ArtefactService) Storage() storage.Storage {
+	return s.store
+}
+
 func (s *ArtefactService) cleanupCache() {
 	ticker := time.NewTicker(30 * time.Minute)
 	defer ticker.Stop()
@@ -208,8 +217,8 @@ func (s *ArtefactService) GetAllArtefacts(shelfId *int) ([]models.ArtefactModel,
 
 	// If not in cache, query DB
 	var artefacts []models.ArtefactModel
-	query := s.db.Preload("Picture").
-		Preload("HistoricalRecord").
+	query := s.db.Preload("Picture", orderArtefactFiles).
+		Preload("HistoricalRecord", orderArtefactFiles).
 		Preload("Archaeologist").
 		Preload("ArchaeologicalSite").
 		Preload("PhysicalLocation").
@@ -246,8 +255,8 @@ func (s *ArtefactService) GetArtefactByID(id int) (*models.ArtefactModel, error)
 	// If not in cache, query DB
 	var artefact models.ArtefactModel
 
-	err := s.db.Preload("Picture").
-		Preload("HistoricalRecord").
+	err := s.db.Preload("Picture", orderArtefactFiles).
+		Preload("HistoricalRecord", orderArtefactFiles).
 		Preload("Archaeologist").
 		Preload("ArchaeologicalSite").
 		Preload("PhysicalLocation").
@@ -267,7 +276,17 @@ func (s *ArtefactService) GetArtefactByID(id int) (*models.ArtefactModel, error)
 }
 
 func (s *ArtefactService) CreateArtefact(artefact *models.ArtefactModel) error {
-	if err := s.db.Create(artefact).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(artefact).Error; err != nil {
+			return err
+		}
+		// Registrar la ubicación inicial en el historial de ubicaciones
+		if artefact.PhysicalLocationID != nil {
+			return recordLocationAssignment(tx, artefact.ID, artefact.PhysicalLocationID, models.LocationSourceManual)
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
@@ -279,7 +298,14 @@ func (s *ArtefactService) CreateArtefact(artefact *models.ArtefactModel) error {
 }
 
 func (s *ArtefactService) UpdateArtefact(id int, artefact *models.ArtefactModel) error {
-	if err := s.db.Where("id = ?", id).Updates(artefact).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := trackLocationChange(tx, id, artefact.PhysicalLocationID, models.LocationSourceManual); err != nil {
+			return err
+		}
+		// La disponibilidad depende de los préstamos, no se edita a mano
+		return tx.Where("id = ?", id).Omit("available").Updates(artefact).Error
+	})
+	if err != nil {
 		return err
 	}
 
@@ -356,8 +382,11 @@ func (s *ArtefactService) UpdateArtefactWithInternalClassifier(
 			}
 		}
 
-		// 2) Update artefact
-		if err := tx.Where("id = ?", id).Updates(artefact).Error; err != nil {
+		// 2) Update artefact (un cambio de ubicación queda en el historial)
+		if err := trackLocationChange(tx, id, artefact.PhysicalLocationID, models.LocationSourceManual); err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", id).Omit("available").Updates(artefact).Error; err != nil {
 			return err
 		}
 
@@ -383,19 +412,19 @@ func (s *ArtefactService) DeleteArtefact(id int) error {
 		return err
 	}
 
-	// Delete the files if they exist
-	if len(artefact.Picture) > 0 && artefact.Picture[0].FilePath != "" {
-		_ = os.Remove(artefact.Picture[0].FilePath)
-	}
-	if len(artefact.HistoricalRecord) > 0 && artefact.HistoricalRecord[0].FilePath != "" {
-		_ = os.Remove(artefact.HistoricalRecord[0].FilePath)
-	}
-
-	// Delete from DB
+	// Delete from DB (pictures and records cascade)
 	if err := s.db.Delete(&artefact, id).Error; err != nil {
 		return err
 	}
 
+	// Delete the files unless another row shares them (deduplicated storage)
+	for _, picture := range artefact.Picture {
+		removeFileIfUnreferenced(s.db, s.store, picture.FilePath, picture.ThumbnailPath, picture.MediumPath)
+	}
+	for _, record := range artefact.HistoricalRecord {
+		removeFileIfUnreferenced(s.db, s.store, record.FilePath)
+	}
+
 	// Invalidate cache
 	s.invalidateCache(fmt.Sprintf("artefact_%d", id))
 	s.invalidateCache("all_artefacts")
@@ -404,8 +433,108 @@ func (s *ArtefactService) DeleteArtefact(id int) error {
 	return nil
 }
 
-// ======================= FOTO Y DOCUMENTO =======================
+// ======================= FOTOS Y DOCUMENTOS =======================
 
+// orderArtefactFiles sorts pictures/historical records: primary first, then by sort order
+func orderArtefactFiles(db *gorm.DB) *gorm.DB {
+	return db.Order("is_primary DESC, sort_order ASC, id ASC")
+}
+
+// invalidateArtefactFilesCache invalida el caché que incluye fotos o documentos del artefacto
+func (s *ArtefactService) invalidateArtefactFilesCache(artefactID int) {
+	s.invalidateCache(fmt.Sprintf("picture_%d", artefactID))
+	s.invalidateCache(fmt.Sprintf("historical_record_%d", artefactID))
+	s.invalidateCache(fmt.Sprintf("artefact_%d", artefactID))
+	s.invalidateCache("all_artefacts")
+	s.invalidateCache("artefact_summaries")
+}
+
+// nextSortOrder returns the sort order for a file appended at the end of the artefact's list
+func nextSortOrder(tx *gorm.DB, model interface{}, artefactID int) (int, error) {
+	var maxOrder int
+	if err := tx.Model(model).
+		Where("artefact_id = ?", artefactID).
+		Select("COALESCE(MAX(sort_order), -1)").
+		Scan(&maxOrder).Error; err != nil {
+		return 0, err
+	}
+	return maxOrder + 1, nil
+}
+
+// setPrimaryFile marks a file as the primary one of the artefact and unmarks the rest
+func setPrimaryFile(tx *gorm.DB, model interface{}, artefactID, fileID int) error {
+	if err := tx.Model(model).
+		Where("artefact_id = ? AND id <> ?", artefactID, fileID).
+		Update("is_primary", false).Error; err != nil {
+		return err
+	}
+	return tx.Model(model).
+		Where("artefact_id = ? AND id = ?", artefactID, fileID).
+		Update("is_primary", true).Error
+}
+
+// promoteFirstFile marks the first file (by sort order) as primary when the artefact has none
+func promoteFirstFile(tx *gorm.DB, model interface{}, artefactID int) error {
+	var primaryCount int64
+	if err := tx.Model(model).
+		Where("artefact_id = ? AND is_primary = ?", artefactID, true).
+		Count(&primaryCount).Error; err != nil {
+		return err
+	}
+	if primaryCount > 0 {
+		return nil
+	}
+
+	var firstIDs []int
+	if err := tx.Model(model).
+		Where("artefact_id = ?", artefactID).
+		Order("sort_order ASC, id ASC").
+		Limit(1).
+		Pluck("id", &firstIDs).Error; err != nil {
+		return err
+	}
+	if len(firstIDs) == 0 {
+		return nil
+	}
+	return setPrimaryFile(tx, model, artefactID, firstIDs[0])
+}
+
+// reorderFiles assigns sort orders following the given ids, which must be exactly the artefact's files
+func reorderFiles(tx *gorm.DB, model interface{}, artefactID int, ids []int) error {
+	var currentIDs []int
+	if err := tx.Model(model).Where("artefact_id = ?", artefactID).Pluck("id", &currentIDs).Error; err != nil {
+		return err
+	}
+	if len(currentIDs) != len(ids) {
+		return ErrInvalidFileOrder
+	}
+
+	current := make(map[int]bool, len(currentIDs))
+	for _, id := range currentIDs {
+		current[id] = true
+	}
+	for position, id := range ids {
+		if !current[id] {
+			return ErrInvalidFileOrder
+		}
+		// Evita ids repetidos en el orden recibido
+		delete(current, id)
+
+		if err := tx.Model(model).
+			Where("artefact_id = ? AND id = ?", artefactID, id).
+			Update("sort_order", position).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ErrInvalidFileOrder is returned when a reorder request doesn't list exactly the artefact's files
+var ErrInvalidFileOrder = errors.New("el orden debe incluir todos los archivos del artefacto, sin repetir")
+
+// ======================= FOTOS =======================
+
+// GetPictureByArtefactID returns the primary picture of the artefact (nil if it has none)
 func (s *ArtefactService) GetPictureByArtefactID(artefactID int) (*models.PictureModel, error) {
 	cacheKey := fmt.Sprintf("picture_%d", artefactID)
 	if cached, found := s.getCache(cacheKey); found {
@@ -414,7 +543,7 @@ func (s *ArtefactService) GetPictureByArtefactID(artefactID int) (*models.Pictur
 	}
 
 	var picture models.PictureModel
-	err := s.db.Where("artefact_id = ?", artefactID).First(&picture).Error
+	err := orderArtefactFiles(s.db.Where("artefact_id = ?", artefactID)).First(&picture).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		// caso normal: aún no hay foto
 		return nil, nil
@@ -427,6 +556,201 @@ func (s *ArtefactService) GetPictureByArtefactID(artefactID int) (*models.Pictur
 	return &picture, nil
 }
 
+// GetPicturesByArtefactID lists all the pictures of the artefact, primary first and then by sort order
+func (s *ArtefactService) GetPicturesByArtefactID(artefactID int) ([]models.PictureModel, error) {
+	var pictures []models.PictureModel
+	err := orderArtefactFiles(s.db.Where("artefact_id = ?", artefactID)).Find(&pictures).Error
+	return pictures, err
+}
+
+// GetPictureByID retrieves a single picture of the artefact
+func (s *ArtefactService) GetPictureByID(artefactID, pictureID int) (*models.PictureModel, error) {
+	var picture models.PictureModel
+	if err := s.db.Where("artefact_id = ? AND id = ?", artefactID, pictureID).First(&picture).Error; err != nil {
+		return nil, err
+	}
+	return &picture, nil
+}
+
+// SavePicture adds a new picture at the end of the artefact's pictures.
+// The first picture of an artefact (or one flagged IsPrimary) becomes the primary picture.
+func (s *ArtefactService) SavePicture(picture *models.PictureModel) error {
+	// Si el mismo archivo ya está almacenado se reutiliza en lugar de guardar otra copia
+	reused, err := s.deduplicateStoredFile(&picture.FilePath, &picture.Filename, &picture.Sha256)
+	if err != nil {
+		return err
+	}
+
+	// Derivados (miniatura y tamaño medio) para no servir el original en las grillas
+	if picture.ThumbnailPath == "" {
+		thumbPath, mediumPath, err := imageDerivativesFor(s.db, s.store, picture.FilePath)
+		if err != nil {
+			log.Printf("[PICTURES] No se pudieron generar derivados para %s: %v", picture.FilePath, err)
+		} else {
+			picture.ThumbnailPath = thumbPath
+			picture.MediumPath = mediumPath
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		order, err := nextSortOrder(tx, &models.PictureModel{}, picture.ArtefactID)
+		if err != nil {
+			return err
+		}
+		picture.SortOrder = order
+
+		if err := tx.Create(picture).Error; err != nil {
+			return err
+		}
+
+		if picture.IsPrimary {
+			return setPrimaryFile(tx, &models.PictureModel{}, picture.ArtefactID, picture.ID)
+		}
+		if err := promoteFirstFile(tx, &models.PictureModel{}, picture.ArtefactID); err != nil {
+			return err
+		}
+		return tx.First(picture, picture.ID).Error
+	})
+	if err != nil {
+		if !reused {
+			removeStoredFiles(s.store, picture.ThumbnailPath, picture.MediumPath)
+		}
+		return err
+	}
+
+	s.invalidateArtefactFilesCache(picture.ArtefactID)
+	return nil
+}
+
+// deduplicateStoredFile records the SHA-256 of a freshly stored file and points it to an identical
+// file already stored, if any. Returns true when an existing file was reused (the new copy is gone).
+func (s *ArtefactService) deduplicateStoredFile(filePath, filename, sha256 *string) (bool, error) {
+	if *sha256 != "" {
+		return false, nil
+	}
+
+	finalPath, hash, reused, err := deduplicateFile(s.db, s.store, *filePath)
+	if err != nil {
+		return false, fmt.Errorf("no se pudo calcular el hash del archivo: %w", err)
+	}
+	*sha256 = hash
+	if reused {
+		log.Printf("[FILES] %s ya estaba almacenado como %s, se reutiliza", *filePath, finalPath)
+		*filePath = finalPath
+		*filename = filepath.Base(finalPath)
+	}
+	return reused, nil
+}
+
+// PictureFileForSize returns the path and content type to serve for the requested size (thumb, medium, original).
+// Pictures stored before derivatives existed get them generated on first request.
+func (s *ArtefactService) PictureFileForSize(picture *models.PictureModel, size string) (string, string) {
+	if size == utils.ImageSizeOriginal {
+		return picture.FilePath, picture.ContentType
+	}
+
+	if !storage.Exists(s.store, picture.ThumbnailPath) || !storage.Exists(s.store, picture.MediumPath) {
+		thumbPath, mediumPath, err := generateImageDerivatives(s.store, picture.FilePath)
+		if err != nil {
+			log.Printf("[PICTURES] No se pudieron generar derivados para %s: %v", picture.FilePath, err)
+			return picture.FilePath, picture.ContentType
+		}
+		if err := s.db.Model(&models.PictureModel{}).Where("id = ?", picture.ID).Updates(map[string]interface{}{
+			"thumbnail_path": thumbPath,
+			"medium_path":    mediumPath,
+		}).Error; err != nil {
+			log.Printf("[PICTURES] No se pudieron guardar los derivados de la foto %d: %v", picture.ID, err)
+		}
+		picture.ThumbnailPath = thumbPath
+		picture.MediumPath = mediumPath
+		s.invalidateArtefactFilesCache(picture.ArtefactID)
+	}
+
+	if size == utils.ImageSizeThumb {
+		return picture.ThumbnailPath, "image/jpeg"
+	}
+	return picture.MediumPath, "image/jpeg"
+}
+
+// UpdatePictureMetadata updates caption, photographer, date and primary flag of a picture
+func (s *ArtefactService) UpdatePictureMetadata(artefactID, pictureID int, metadata *dtos.PictureMetadataDTO) (*models.PictureModel, error) {
+	var picture models.PictureModel
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("artefact_id = ? AND id = ?", artefactID, pictureID).First(&picture).Error; err != nil {
+			return err
+		}
+
+		// Solo se tocan los campos enviados
+		updates := map[string]interface{}{"updated_at": time.Now()}
+		if metadata.Caption != nil {
+			updates["caption"] = *metadata.Caption
+		}
+		if metadata.Photographer != nil {
+			updates["photographer"] = *metadata.Photographer
+		}
+		if metadata.TakenAt != nil {
+			updates["taken_at"] = *metadata.TakenAt
+		}
+		if err := tx.Model(&picture).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if metadata.IsPrimary != nil && *metadata.IsPrimary {
+			if err := setPrimaryFile(tx, &models.PictureModel{}, artefactID, pictureID); err != nil {
+				return err
+			}
+		}
+
+		return tx.First(&picture, pictureID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateArtefactFilesCache(artefactID)
+	return &picture, nil
+}
+
+// ReorderPictures sets the display order of the artefact's pictures
+func (s *ArtefactService) ReorderPictures(artefactID int, ids []int) ([]models.PictureModel, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return reorderFiles(tx, &models.PictureModel{}, artefactID, ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateArtefactFilesCache(artefactID)
+	return s.GetPicturesByArtefactID(artefactID)
+}
+
+// DeletePicture removes a single picture and its file; if it was the primary one, the next picture takes its place
+func (s *ArtefactService) DeletePicture(artefactID, pictureID int) error {
+	var picture models.PictureModel
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("artefact_id = ? AND id = ?", artefactID, pictureID).First(&picture).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&picture).Error; err != nil {
+			return err
+		}
+		return promoteFirstFile(tx, &models.PictureModel{}, artefactID)
+	})
+	if err != nil {
+		return err
+	}
+
+	removeFileIfUnreferenced(s.db, s.store, picture.FilePath, picture.ThumbnailPath, picture.MediumPath)
+
+	s.invalidateArtefactFilesCache(artefactID)
+	return nil
+}
+
+// ======================= DOCUMENTOS HISTÓRICOS =======================
+
+// GetHistoricalRecordByArtefactID returns the primary historical record of the artefact
 func (s *ArtefactService) GetHistoricalRecordByArtefactID(artefactID int) (*models.HistoricalRecordModel, error) {
 	cacheKey := fmt.Sprintf("historical_record_%d", artefactID)
 
@@ -438,7 +762,7 @@ func (s *ArtefactService) GetHistoricalRecordByArtefactID(artefactID int) (*mode
 
 	// If not in cache, query DB
 	var record models.HistoricalRecordModel
-	err := s.db.Where("artefact_id = ?", artefactID).First(&record).Error
+	err := orderArtefactFiles(s.db.Where("artefact_id = ?", artefactID)).First(&record).Error
 	if err != nil {
 		return nil, err
 	}
@@ -449,66 +773,129 @@ func (s *ArtefactService) GetHistoricalRecordByArtefactID(artefactID int) (*mode
 	return &record, nil
 }
 
-func (s *ArtefactService) SavePicture(picture *models.PictureModel) error {
-	var existing models.PictureModel
-	err := s.db.Where("artefact_id = ?", picture.ArtefactID).First(&existing).Error
+// GetHistoricalRecordsByArtefactID lists all the historical records of the artefact
+func (s *ArtefactService) GetHistoricalRecordsByArtefactID(artefactID int) ([]models.HistoricalRecordModel, error) {
+	var records []models.HistoricalRecordModel
+	err := orderArtefactFiles(s.db.Where("artefact_id = ?", artefactID)).Find(&records).Error
+	return records, err
+}
 
-	switch {
-	case errors.Is(err, gorm.ErrRecordNotFound):
-		// no había foto: crear
-		if err := s.db.Create(picture).Error; err != nil {
+// GetHistoricalRecordByID retrieves a single historical record of the artefact
+func (s *ArtefactService) GetHistoricalRecordByID(artefactID, recordID int) (*models.HistoricalRecordModel, error) {
+	var record models.HistoricalRecordModel
+	if err := s.db.Where("artefact_id = ? AND id = ?", artefactID, recordID).First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// SaveHistoricalRecord adds a new historical record at the end of the artefact's records
+func (s *ArtefactService) SaveHistoricalRecord(record *models.HistoricalRecordModel) error {
+	if _, err := s.deduplicateStoredFile(&record.FilePath, &record.Filename, &record.Sha256); err != nil {
+		return err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		order, err := nextSortOrder(tx, &models.HistoricalRecordModel{}, record.ArtefactID)
+		if err != nil {
 			return err
 		}
-	case err != nil:
-		// error real de DB
-		return err
-	default:
-		// había foto: borrar archivo viejo y actualizar registro
-		if existing.FilePath != "" {
-			_ = os.Remove(existing.FilePath)
-		}
-		// aseguramos update sobre el registro existente
-		picture.ID = existing.ID
-		if err := s.db.Model(&existing).Updates(map[string]interface{}{
-			"file_path":    picture.FilePath,
-			"content_type": picture.ContentType,
-			"size":         picture.Size,
-			"updated_at":   time.Now(),
-		}).Error; err != nil {
+		record.SortOrder = order
+
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+
+		if record.IsPrimary {
+			return setPrimaryFile(tx, &models.HistoricalRecordModel{}, record.ArtefactID, record.ID)
+		}
+		if err := promoteFirstFile(tx, &models.HistoricalRecordModel{}, record.ArtefactID); err != nil {
 			return err
 		}
+		return tx.First(record, record.ID).Error
+	})
+	if err != nil {
+		return err
 	}
 
-	s.invalidateCache(fmt.Sprintf("picture_%d", picture.ArtefactID))
-	s.invalidateCache(fmt.Sprintf("artefact_%d", picture.ArtefactID))
-	s.invalidateCache("all_artefacts")
-	s.invalidateCache("artefact_summaries")
+	// Invalidate related cache
+	s.invalidateArtefactFilesCache(record.ArtefactID)
 	return nil
 }
 
-func (s *ArtefactService) SaveHistoricalRecord(record *models.HistoricalRecordModel) error {
-	// Check if a document already exists for this artefact
-	var existing models.HistoricalRecordModel
-	if err := s.db.Where("artefact_id = ?", record.ArtefactID).First(&existing).Error; err == nil {
-		// Already exists, delete previous file
-		_ = os.Remove(existing.FilePath)
-		// Update existing record
-		if err := s.db.Where("artefact_id = ?", record.ArtefactID).Updates(record).Error; err != nil {
+// UpdateHistoricalRecordMetadata updates caption, author, date and primary flag of a historical record
+func (s *ArtefactService) UpdateHistoricalRecordMetadata(artefactID, recordID int, metadata *dtos.HistoricalRecordMetadataDTO) (*models.HistoricalRecordModel, error) {
+	var record models.HistoricalRecordModel
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("artefact_id = ? AND id = ?", artefactID, recordID).First(&record).Error; err != nil {
 			return err
 		}
-	} else {
-		// Does not exist, create new
-		if err := s.db.Create(record).Error; err != nil {
+
+		// Solo se tocan los campos enviados
+		updates := map[string]interface{}{"updated_at": time.Now()}
+		if metadata.Caption != nil {
+			updates["caption"] = *metadata.Caption
+		}
+		if metadata.Author != nil {
+			updates["author"] = *metadata.Author
+		}
+		if metadata.DocumentDate != nil {
+			updates["document_date"] = *metadata.DocumentDate
+		}
+		if err := tx.Model(&record).Updates(updates).Error; err != nil {
 			return err
 		}
+
+		if metadata.IsPrimary != nil && *metadata.IsPrimary {
+			if err := setPrimaryFile(tx, &models.HistoricalRecordModel{}, artefactID, recordID); err != nil {
+				return err
+			}
+		}
+
+		return tx.First(&record, recordID).Error
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Invalidate related cache
-	s.invalidateCache(fmt.Sprintf("historical_record_%d", record.ArtefactID))
-	s.invalidateCache(fmt.Sprintf("artefact_%d", record.ArtefactID))
-	s.invalidateCache("all_artefacts")
-	s.invalidateCache("artefact_summaries")
+	s.invalidateArtefactFilesCache(artefactID)
+	return &record, nil
+}
+
+// ReorderHistoricalRecords sets the display order of the artefact's historical records
+func (s *ArtefactService) ReorderHistoricalRecords(artefactID int, ids []int) ([]models.HistoricalRecordModel, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return reorderFiles(tx, &models.HistoricalRecordModel{}, artefactID, ids)
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	s.invalidateArtefactFilesCache(artefactID)
+	return s.GetHistoricalRecordsByArtefactID(artefactID)
+}
+
+// DeleteHistoricalRecord removes a single historical record and its file
+func (s *ArtefactService) DeleteHistoricalRecord(artefactID, recordID int) error {
+	var record models.HistoricalRecordModel
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("artefact_id = ? AND id = ?", artefactID, recordID).First(&record).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&record).Error; err != nil {
+			return err
+		}
+		return promoteFirstFile(tx, &models.HistoricalRecordModel{}, artefactID)
+	})
+	if err != nil {
+		return err
+	}
+
+	removeFileIfUnreferenced(s.db, s.store, record.FilePath)
+
+	s.invalidateArtefactFilesCache(artefactID)
 	return nil
 }
 
@@ -580,6 +967,11 @@ func (s *ArtefactService) CreateArtefactWithMentions(dto *CreateArtefactWithMent
 		if err := tx.Create(&artefact).Error; err != nil {
 			return err
 		}
+		if artefact.PhysicalLocationID != nil {
+			if err := recordLocationAssignment(tx, artefact.ID, artefact.PhysicalLocationID, models.LocationSourceManual); err != nil {
+				return err
+			}
+		}
 
 		// 3) Crear menciones (si hay)
 		if len(dto.Mentions) > 0 {
@@ -1291,6 +1683,9 @@ func (s *ArtefactService) ImportArtefactsFromExcel(r io.Reader) (*ImportResult,
 
 		// Actualizar artefacto con PhysicalLocationID si se asignó
 		if physicalLocationID != nil {
+			if err := trackLocationChange(s.db, artefact.ID, physicalLocationID, models.LocationSourceImport); err != nil {
+				log.Printf("[IMPORT] ERROR registrando el historial de ubicación de %s: %v", name, err)
+			}
 			if err := s.db.Model(&artefact).Update("physical_location_id", *physicalLocationID).Error; err != nil {
 				log.Printf("[IMPORT] ERROR actualizando ubicación física para %s: %v", name, err)
 				result.Errors = append(result.Errors, fmt.Sprintf("Fila %d: error actualizando ubicación física: %v", i+1, err))
@@ -1917,46 +2312,20 @@ func (s *ArtefactService) associatePictureFromPath(artefact *models.ArtefactMode
 	}
 	defer sourceFile.Close()
 
-	// Obtener información del archivo
-	fileInfo, err := sourceFile.Stat()
+	// Validar el archivo por su contenido (tipo real, tamaño y estructura)
+	upload, err := utils.ValidateUpload(utils.UploadKindPicture, sourceFile, originalFilename)
 	if err != nil {
-		return fmt.Errorf("no se pudo obtener información del archivo: %w", err)
-	}
-
-	// Determinar content type basado en la extensión del archivo original
-	ext := strings.ToLower(filepath.Ext(originalFilename))
-	if ext == "" {
-		ext = strings.ToLower(filepath.Ext(actualPath))
-	}
-	contentType := "image/jpeg"
-	switch ext {
-	case ".png":
-		contentType = "image/png"
-	case ".gif":
-		contentType = "image/gif"
-	case ".webp":
-		contentType = "image/webp"
+		return fmt.Errorf("archivo rechazado: %w", err)
 	}
 
-	// Crear directorio de destino
 	uploadDir := "uploads/pictures"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return fmt.Errorf("no se pudo crear directorio: %w", err)
-	}
 
 	// Generar nombre único usando el nombre original del archivo
-	filename := fmt.Sprintf("artefact_%d_%d_%s", artefact.ID, time.Now().Unix(), originalFilename)
+	filename := fmt.Sprintf("artefact_%d_%d_%s", artefact.ID, time.Now().Unix(), upload.Filename)
 	destPath := filepath.Join(uploadDir, filename)
 
-	// Copiar archivo
-	destFile, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("no se pudo crear archivo destino: %w", err)
-	}
-	defer destFile.Close()
-
-	if _, err := io.Copy(destFile, sourceFile); err != nil {
-		os.Remove(destPath)
+	// Copiar archivo al almacenamiento
+	if err := s.store.Put(destPath, bytes.NewReader(upload.Data), int64(len(upload.Data)), upload.ContentType); err != nil {
 		return fmt.Errorf("no se pudo copiar archivo: %w", err)
 	}
 
@@ -1966,14 +2335,14 @@ func (s *ArtefactService) associatePictureFromPath(artefact *models.ArtefactMode
 		Filename:     filename,
 		OriginalName: originalFilename,
 		FilePath:     destPath,
-		ContentType:  contentType,
-		Size:         fileInfo.Size(),
+		ContentType:  upload.ContentType,
+		Size:         int64(len(upload.Data)),
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
 
 	if err := s.SavePicture(&picture); err != nil {
-		os.Remove(destPath)
+		removeStoredFiles(s.store, destPath)
 		return fmt.Errorf("no se pudo guardar metadata: %w", err)
 	}
 
@@ -2018,43 +2387,20 @@ func (s *ArtefactService) associateHistoricalRecordFromPath(artefact *models.Art
 	}
 	defer sourceFile.Close()
 
-	// Obtener información del archivo
-	fileInfo, err := sourceFile.Stat()
+	// Validar el archivo por su contenido (tipo real, tamaño y estructura)
+	upload, err := utils.ValidateUpload(utils.UploadKindDocument, sourceFile, originalFilename)
 	if err != nil {
-		return fmt.Errorf("no se pudo obtener información del archivo: %w", err)
+		return fmt.Errorf("archivo rechazado: %w", err)
 	}
 
-	// Determinar content type basado en la extensión del archivo original
-	ext := strings.ToLower(filepath.Ext(originalFilename))
-	if ext == "" {
-		ext = strings.ToLower(filepath.Ext(actualPath))
-	}
-	contentType := "application/pdf"
-	if strings.HasPrefix(ext, ".jpg") || strings.HasPrefix(ext, ".jpeg") {
-		contentType = "image/jpeg"
-	} else if ext == ".png" {
-		contentType = "image/png"
-	}
-
-	// Crear directorio de destino
 	uploadDir := "uploads/historical_records"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return fmt.Errorf("no se pudo crear directorio: %w", err)
-	}
 
 	// Generar nombre único usando el nombre original del archivo
-	filename := fmt.Sprintf("record_%d_%d_%s", artefact.ID, time.Now().Unix(), originalFilename)
+	filename := fmt.Sprintf("record_%d_%d_%s", artefact.ID, time.Now().Unix(), upload.Filename)
 	destPath := filepath.Join(uploadDir, filename)
 
-	// Copiar archivo
-	destFile, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("no se pudo crear archivo destino: %w", err)
-	}
-	defer destFile.Close()
-
-	if _, err := io.Copy(destFile, sourceFile); err != nil {
-		os.Remove(destPath)
+	// Copiar archivo al almacenamiento
+	if err := s.store.Put(destPath, bytes.NewReader(upload.Data), int64(len(upload.Data)), upload.ContentType); err != nil {
 		return fmt.Errorf("no se pudo copiar archivo: %w", err)
 	}
 
@@ -2064,14 +2410,14 @@ func (s *ArtefactService) associateHistoricalRecordFromPath(artefact *models.Art
 		Filename:     filename,
 		OriginalName: originalFilename,
 		FilePath:     destPath,
-		ContentType:  contentType,
-		Size:         fileInfo.Size(),
+		ContentType:  upload.ContentType,
+		Size:         int64(len(upload.Data)),
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
 
 	if err := s.SaveHistoricalRecord(&record); err != nil {
-		os.Remove(destPath)
+		removeStoredFiles(s.store, destPath)
 		return fmt.Errorf("no se pudo guardar metadata: %w", err)
 	}
 
@@ -2116,25 +2462,10 @@ func (s *ArtefactService) associateINPLFromPath(artefact *models.ArtefactModel,
 	}
 	defer sourceFile.Close()
 
-	// Obtener información del archivo
-	fileInfo, err := sourceFile.Stat()
+	// Validar el archivo por su contenido (tipo real, tamaño y estructura)
+	upload, err := utils.ValidateUpload(utils.UploadKindFicha, sourceFile, originalFilename)
 	if err != nil {
-		return fmt.Errorf("no se pudo obtener información del archivo: %w", err)
-	}
-
-	// Determinar content type basado en la extensión del archivo original (INPL son imágenes)
-	ext := strings.ToLower(filepath.Ext(originalFilename))
-	if ext == "" {
-		ext = strings.ToLower(filepath.Ext(actualPath))
-	}
-	contentType := "image/jpeg"
-	switch ext {
-	case ".png":
-		contentType = "image/png"
-	case ".gif":
-		contentType = "image/gif"
-	case ".webp":
-		contentType = "image/webp"
+		return fmt.Errorf("archivo rechazado: %w", err)
 	}
 
 	// Crear o obtener INPLClassifier para este artefacto
@@ -2156,29 +2487,40 @@ func (s *ArtefactService) associateINPLFromPath(artefact *models.ArtefactModel,
 		}
 	}
 
-	// Crear directorio de destino (una sola carpeta para todas las fichas INPL)
+	// Una sola carpeta para todas las fichas INPL
 	uploadRoot := "uploads/inpl"
 	if envRoot := os.Getenv("INPL_UPLOAD_ROOT"); envRoot != "" {
 		uploadRoot = envRoot
 	}
-	if err := os.MkdirAll(uploadRoot, 0755); err != nil {
-		return fmt.Errorf("no se pudo crear directorio: %w", err)
-	}
 
 	// Generar nombre único usando el ID del clasificador y el nombre original del archivo
-	filename := fmt.Sprintf("ficha_%d_%d_%s", inplClassifier.ID, time.Now().Unix(), originalFilename)
+	filename := fmt.Sprintf("ficha_%d_%d_%s", inplClassifier.ID, time.Now().Unix(), upload.Filename)
 	destPath := filepath.Join(uploadRoot, filename)
 
-	// Copiar archivo
-	destFile, err := os.Create(destPath)
+	// Copiar archivo al almacenamiento
+	if err := s.store.Put(destPath, bytes.NewReader(upload.Data), int64(len(upload.Data)), upload.ContentType); err != nil {
+		return fmt.Errorf("no se pudo copiar archivo: %w", err)
+	}
+
+	// Reutilizar el archivo si la misma ficha ya está almacenada
+	var fichaHash string
+	reused, err := s.deduplicateStoredFile(&destPath, &filename, &fichaHash)
 	if err != nil {
-		return fmt.Errorf("no se pudo crear archivo destino: %w", err)
+		removeStoredFiles(s.store, destPath)
+		return err
 	}
-	defer destFile.Close()
 
-	if _, err := io.Copy(destFile, sourceFile); err != nil {
-		os.Remove(destPath)
-		return fmt.Errorf("no se pudo copiar archivo: %w", err)
+	// Generar miniatura y tamaño medio junto al original
+	thumbPath, mediumPath, err := imageDerivativesFor(s.db, s.store, destPath)
+	if err != nil {
+		log.Printf("[IMPORT] No se pudieron generar derivados para %s: %v", destPath, err)
+	}
+
+	// discardNewFiles elimina lo creado por esta importación si no se pudo guardar el registro
+	discardNewFiles := func() {
+		if !reused {
+			removeStoredFiles(s.store, destPath, thumbPath, mediumPath)
+		}
 	}
 
 	// Verificar si ya existe una ficha INPL para este clasificador (solo debe haber una)
@@ -2186,22 +2528,26 @@ func (s *ArtefactService) associateINPLFromPath(artefact *models.ArtefactModel,
 	err = s.db.Where("inpl_classifier_id = ?", inplClassifier.ID).First(&existingFicha).Error
 
 	if err == nil {
-		// Ya existe una ficha, reemplazarla (eliminar archivo anterior y actualizar registro)
-		if existingFicha.FilePath != "" {
-			_ = os.Remove(existingFicha.FilePath)
-		}
+		// Ya existe una ficha, reemplazarla (actualizar registro y eliminar archivo anterior)
+		previous := existingFicha
 		// Actualizar la ficha existente
 		existingFicha.Filename = filename
 		existingFicha.OriginalName = originalFilename
 		existingFicha.FilePath = destPath
-		existingFicha.ContentType = contentType
-		existingFicha.Size = fileInfo.Size()
+		existingFicha.ContentType = upload.ContentType
+		existingFicha.Size = int64(len(upload.Data))
+		existingFicha.ThumbnailPath = thumbPath
+		existingFicha.MediumPath = mediumPath
+		existingFicha.Sha256 = fichaHash
 		existingFicha.UpdatedAt = time.Now()
 
 		if err := s.db.Save(&existingFicha).Error; err != nil {
-			os.Remove(destPath)
+			discardNewFiles()
 			return fmt.Errorf("no se pudo actualizar ficha INPL: %w", err)
 		}
+		if previous.FilePath != destPath {
+			removeFileIfUnreferenced(s.db, s.store, previous.FilePath, previous.ThumbnailPath, previous.MediumPath)
+		}
 		log.Printf("[IMPORT] Ficha INPL reemplazada para clasificador ID %d", inplClassifier.ID)
 	} else if errors.Is(err, gorm.ErrRecordNotFound) {
 		// No existe ficha, crear nueva
@@ -2210,20 +2556,23 @@ func (s *ArtefactService) associateINPLFromPath(artefact *models.ArtefactModel,
 			Filename:         filename,
 			OriginalName:     originalFilename,
 			FilePath:         destPath,
-			ContentType:      contentType,
-			Size:             fileInfo.Size(),
+			ContentType:      upload.ContentType,
+			Size:             int64(len(upload.Data)),
+			ThumbnailPath:    thumbPath,
+			MediumPath:       mediumPath,
+			Sha256:           fichaHash,
 			CreatedAt:        time.Now(),
 			UpdatedAt:        time.Now(),
 		}
 
 		if err := s.db.Create(&ficha).Error; err != nil {
-			os.Remove(destPath)
+			discardNewFiles()
 			return fmt.Errorf("no se pudo guardar ficha INPL: %w", err)
 		}
 		log.Printf("[IMPORT] Nueva ficha INPL creada para clasificador ID %d", inplClassifier.ID)
 	} else {
 		// Error al buscar
-		os.Remove(destPath)
+		discardNewFiles()
 		return fmt.Errorf("error verificando ficha INPL existente: %w", err)
 	}
 
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidCalendarToken is returned when a feed is requested with an unknown or revoked token
+	ErrInvalidCalendarToken = errors.New("el token del calendario no es válido")
+	// ErrCalendarFeedForbidden is returned when a user whose role can't see the feed asks for a token
+	ErrCalendarFeedForbidden = errors.New("solo administradores y registradores pueden suscribirse al calendario")
+)
+
+type CalendarFeedService struct {
+	db *gorm.DB
+}
+
+// NewCalendarFeedService creates a new instance of CalendarFeedService
+func NewCalendarFeedService(db *gorm.DB) *CalendarFeedService {
+	return &CalendarFeedService{db: db}
+}
+
+// canSubscribeToCalendar reports whether the role may see the feed, which names the requesters of every
+// loan and reservation
+func canSubscribeToCalendar(role string) bool {
+	return role == models.RoleAdmin || role == models.RoleRegistrar
+}
+
+// hashCalendarToken returns the value stored for a calendar token; the token itself is never saved
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RotateCalendarToken issues a new feed token for the user, invalidating the previous one.
+// The token is only returned here: calendar apps can't send an Authorization header, so it goes in the URL.
+func (s *CalendarFeedService) RotateCalendarToken(userId int) (string, error) {
+	var user models.UserModel
+	if err := s.db.First(&user, userId).Error; err != nil {
+		return "", err
+	}
+	if !canSubscribeToCalendar(user.Role) {
+		return "", ErrCalendarFeedForbidden
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(secret)
+
+	if err := s.db.Model(&user).Update("calendar_token_hash", hashCalendarToken(token)).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RevokeCalendarToken disables the user's feed until a new token is issued
+func (s *CalendarFeedService) RevokeCalendarToken(userId int) error {
+	result := s.db.Model(&models.UserModel{}).Where("id = ?", userId).Update("calendar_token_hash", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetCalendarFeed builds the iCalendar feed for the owner of the token: one event per due date of an
+// open loan, per active reservation and per scheduled return of an internal movement.
+// The owner's role is checked on every request, so a demoted user's token stops working.
+func (s *CalendarFeedService) GetCalendarFeed(token string) ([]byte, error) {
+	var user models.UserModel
+	if err := s.db.Where("calendar_token_hash = ?", hashCalendarToken(token)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidCalendarToken
+		}
+		return nil, err
+	}
+	if !canSubscribeToCalendar(user.Role) {
+		return nil, ErrInvalidCalendarToken
+	}
+
+	cal := newICalendar("ARQAP - Préstamos y devoluciones")
+
+	var loans []models.LoanModel
+	if err := s.db.
+		Preload("Artefact").
+		Preload("Artefact.InternalClassifier").
+		Preload("Artefact.InplClassifier").
+		Preload("Requester").
+		Where("return_date IS NULL AND due_date IS NOT NULL").
+		Order("due_date ASC").
+		Find(&loans).Error; err != nil {
+		return nil, err
+	}
+	day := today()
+	for _, loan := range loans {
+		summary := "Vence préstamo: " + artefactLabel(loan.Artefact)
+		if loanStatus(&loan, day, loanDueSoonDays()) == models.LoanStatusOverdue {
+			summary = "VENCIDO - " + summary
+		}
+		description := []string{
+			"Solicitante: " + requesterLabel(loan.Requester),
+			"Fecha de préstamo: " + loan.LoanDate.Format("02/01/2006"),
+		}
+		cal.event(fmt.Sprintf("loan-%d@arqap", loan.Id), *loan.DueDate, nil, summary, description)
+	}
+
+	var reservations []models.ReservationModel
+	if err := s.db.
+		Preload("Artefact").
+		Preload("Artefact.InternalClassifier").
+		Preload("Artefact.InplClassifier").
+		Preload("Requester").
+		Where("status = ?", models.ReservationStatusActive).
+		Order("start_date ASC").
+		Find(&reservations).Error; err != nil {
+		return nil, err
+	}
+	for _, reservation := range reservations {
+		description := []string{"Solicitante: " + requesterLabel(reservation.Requester)}
+		if reservation.Purpose != nil {
+			description = append(description, "Motivo: "+*reservation.Purpose)
+		}
+		cal.event(fmt.Sprintf("reservation-%d@arqap", reservation.Id), reservation.StartDate, reservation.EndDate,
+			"Reserva: "+artefactLabel(reservation.Artefact), description)
+	}
+
+	var movements []models.InternalMovementModel
+	if err := s.db.
+		Preload("Artefact").
+		Preload("Artefact.InternalClassifier").
+		Preload("Artefact.InplClassifier").
+		Preload("FromPhysicalLocation").
+		Preload("FromPhysicalLocation.Shelf").
+		Preload("ToPhysicalLocation").
+		Preload("ToPhysicalLocation.Shelf").
+		Where("return_date IS NULL AND voided_at IS NULL AND expected_return_date IS NOT NULL").
+		Order("expected_return_date ASC").
+		Find(&movements).Error; err != nil {
+		return nil, err
+	}
+	for _, movement := range movements {
+		description := []string{
+			"Desde: " + locationLabel(movement.ToPhysicalLocation),
+			"Hacia: " + locationLabel(movement.FromPhysicalLocation),
+		}
+		if movement.Reason != nil {
+			description = append(description, "Motivo: "+*movement.Reason)
+		}
+		cal.event(fmt.Sprintf("internal-movement-%d@arqap", movement.Id), *movement.ExpectedReturnDate, nil,
+			"Retorno programado: "+artefactLabel(movement.Artefact), description)
+	}
+
+	return cal.bytes(), nil
+}
+
+// artefactLabel names a piece by name and inventory code (calendar events, reminder emails)
+func artefactLabel(artefact *models.ArtefactModel) string {
+	if artefact == nil {
+		return "pieza sin datos"
+	}
+	return fmt.Sprintf("%s (%s)", artefact.Name, inventoryCode(artefact))
+}
+
+// requesterLabel names a requester with their type
+func requesterLabel(requester *models.RequesterModel) string {
+	if requester == nil {
+		return "Sin solicitante"
+	}
+	if requester.AnonymizedAt != nil {
+		return fmt.Sprintf("Solicitante anonimizado (%s)", requester.Type)
+	}
+	name := strings.TrimSpace(valueOr(requester.FirstName, "") + " " + valueOr(requester.LastName, ""))
+	if name == "" {
+		return string(requester.Type)
+	}
+	return fmt.Sprintf("%s (%s)", name, requester.Type)
+}
+
+// iCalendar writes an RFC 5545 calendar with all-day events
+type iCalendar struct {
+	b     strings.Builder
+	stamp string
+}
+
+func newICalendar(name string) *iCalendar {
+	cal := &iCalendar{stamp: time.Now().UTC().Format("20060102T150405Z")}
+	cal.line("BEGIN:VCALENDAR")
+	cal.line("VERSION:2.0")
+	cal.line("PRODID:-//ARQAP//ARQAP-Backend//ES")
+	cal.line("CALSCALE:GREGORIAN")
+	cal.line("METHOD:PUBLISH")
+	cal.line("X-WR-CALNAME:" + icsEscape(name))
+	return cal
+}
+
+// event adds an all-day event from start to end (inclusive); a nil end means a single day
+func (c *iCalendar) event(uid string, start time.Time, end *time.Time, summary string, description []string) {
+	last := dateOnly(start)
+	if end != nil && dateOnly(*end).After(last) {
+		last = dateOnly(*end)
+	}
+
+	c.line("BEGIN:VEVENT")
+	c.line("UID:" + uid)
+	c.line("DTSTAMP:" + c.stamp)
+	c.line("DTSTART;VALUE=DATE:" + dateOnly(start).Format("20060102"))
+	// DTEND es exclusivo en los eventos de día completo
+	c.line("DTEND;VALUE=DATE:" + last.AddDate(0, 0, 1).Format("20060102"))
+	c.line("SUMMARY:" + icsEscape(summary))
+	if len(description) > 0 {
+		c.line("DESCRIPTION:" + icsEscape(strings.Join(description, "\n")))
+	}
+	c.line("TRANSP:TRANSPARENT")
+	c.line("END:VEVENT")
+}
+
+func (c *iCalendar) bytes() []byte {
+	c.line("END:VCALENDAR")
+	return []byte(c.b.String())
+}
+
+// line writes a content line folded at 75 octets without splitting UTF-8 characters
+func (c *iCalendar) line(content string) {
+	limit := 75
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(content[cut]) {
+			cut--
+		}
+		c.b.WriteString(content[:cut])
+		c.b.WriteString("\r\n ")
+		content = content[cut:]
+		limit = 74 // las líneas de continuación empiezan con un espacio
+	}
+	c.b.WriteString(content)
+	c.b.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// icsEscape escapes a TEXT value (RFC 5545, section 3.3.11)
+func icsEscape(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", "",
+	).Replace(value)
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/storage"
+	"github.com/ARQAP/ARQAP-Backend/src/utils"
+	"gorm.io/gorm"
+)
+
+// fileReferenceTables are the tables whose rows point to stored files (file_path + sha256)
+var fileReferenceTables = []string{"picture_models", "historical_record_models", "inpl_fichas", "loan_document_models"}
+
+// deduplicatedTables are the ones whose files uploads may reuse. Loan documents are private and are deleted
+// with their loan, so an upload never points to them.
+var deduplicatedTables = []string{"picture_models", "historical_record_models", "inpl_fichas"}
+
+// ErrFixityCheckRunning is returned when a fixity check is requested while another one is in progress
+var ErrFixityCheckRunning = errors.New("ya hay una verificación de integridad en curso")
+
+// FixityIssue describes a stored file that is missing or doesn't match its recorded hash
+type FixityIssue struct {
+	Kind           string `json:"kind"` // picture, historical_record, inpl_ficha, loan_document
+	ID             int    `json:"id"`
+	FilePath       string `json:"filePath"`
+	ExpectedSha256 string `json:"expectedSha256,omitempty"`
+	ActualSha256   string `json:"actualSha256,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// FixityReport is the result of a full fixity check over the stored files
+type FixityReport struct {
+	StartedAt  time.Time     `json:"startedAt"`
+	FinishedAt time.Time     `json:"finishedAt"`
+	Checked    int           `json:"checked"`
+	Backfilled int           `json:"backfilled"` // rows without hash that got one in this run
+	Missing    []FixityIssue `json:"missing"`
+	Corrupted  []FixityIssue `json:"corrupted"`
+}
+
+type FileIntegrityService struct {
+	db         *gorm.DB
+	store      storage.Storage
+	mutex      sync.Mutex
+	running    bool
+	lastReport *FixityReport
+}
+
+// NewFileIntegrityService creates a new instance of FileIntegrityService
+func NewFileIntegrityService(db *gorm.DB, store storage.Storage) *FileIntegrityService {
+	return &FileIntegrityService{db: db, store: store}
+}
+
+// StartFixityScheduler runs a fixity check every interval in the background (interval <= 0 disables it)
+func (s *FileIntegrityService) StartFixityScheduler(interval time.Duration) {
+	if interval <= 0 {
+		log.Println("[FIXITY] Verificación programada deshabilitada")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := s.RunFixityCheck(); err != nil && !errors.Is(err, ErrFixityCheckRunning) {
+				log.Printf("[FIXITY] Error en la verificación programada: %v", err)
+			}
+		}
+	}()
+}
+
+// GetLastFixityReport returns the report of the last completed check (nil if none ran yet)
+func (s *FileIntegrityService) GetLastFixityReport() *FixityReport {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastReport
+}
+
+// RunFixityCheck re-hashes every stored file and compares it with the recorded SHA-256.
+// Rows stored before hashes existed get their hash recorded instead.
+func (s *FileIntegrityService) RunFixityCheck() (*FixityReport, error) {
+	s.mutex.Lock()
+	if s.running {
+		s.mutex.Unlock()
+		return nil, ErrFixityCheckRunning
+	}
+	s.running = true
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		s.running = false
+		s.mutex.Unlock()
+	}()
+
+	report := &FixityReport{
+		StartedAt: time.Now(),
+		Missing:   []FixityIssue{},
+		Corrupted: []FixityIssue{},
+	}
+
+	kinds := map[string]interface{}{
+		"picture":           &models.PictureModel{},
+		"historical_record": &models.HistoricalRecordModel{},
+		"inpl_ficha":        &models.INPLFicha{},
+		"loan_document":     &models.LoanDocumentModel{},
+	}
+
+	for kind, model := range kinds {
+		type storedFile struct {
+			ID       int
+			FilePath string
+			Sha256   string
+		}
+		var files []storedFile
+		if err := s.db.Model(model).Select("id, file_path, sha256").Find(&files).Error; err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			report.Checked++
+			issue := FixityIssue{Kind: kind, ID: f.ID, FilePath: f.FilePath, ExpectedSha256: f.Sha256}
+
+			if _, err := s.store.Stat(f.FilePath); err != nil {
+				issue.Error = err.Error()
+				report.Missing = append(report.Missing, issue)
+				continue
+			}
+
+			actual, err := hashStoredFile(s.store, f.FilePath)
+			if err != nil {
+				issue.Error = err.Error()
+				report.Corrupted = append(report.Corrupted, issue)
+				continue
+			}
+
+			if f.Sha256 == "" {
+				if err := s.db.Model(model).Where("id = ?", f.ID).Update("sha256", actual).Error; err != nil {
+					return nil, err
+				}
+				report.Backfilled++
+				continue
+			}
+
+			if actual != f.Sha256 {
+				issue.ActualSha256 = actual
+				report.Corrupted = append(report.Corrupted, issue)
+			}
+		}
+	}
+
+	report.FinishedAt = time.Now()
+
+	log.Printf("[FIXITY] Verificación completada: %d archivos, %d faltantes, %d corruptos, %d hashes nuevos",
+		report.Checked, len(report.Missing), len(report.Corrupted), report.Backfilled)
+	for _, issue := range report.Missing {
+		log.Printf("[FIXITY] FALTANTE %s %d: %s", issue.Kind, issue.ID, issue.FilePath)
+	}
+	for _, issue := range report.Corrupted {
+		log.Printf("[FIXITY] CORRUPTO %s %d: %s (esperado %s, actual %s)", issue.Kind, issue.ID, issue.FilePath, issue.ExpectedSha256, issue.ActualSha256)
+	}
+
+	s.mutex.Lock()
+	s.lastReport = report
+	s.mutex.Unlock()
+
+	return report, nil
+}
+
+// ======================= ARCHIVOS ALMACENADOS =======================
+
+// hashStoredFile returns the SHA-256 of a stored file
+func hashStoredFile(store storage.Storage, key string) (string, error) {
+	r, err := store.Open(key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	return utils.HashReader(r)
+}
+
+// deduplicateFile hashes a freshly stored file and, if an identical file is already stored
+// (same SHA-256 and still intact), removes the new copy and returns the existing key.
+// The returned bool reports whether an existing file was reused.
+func deduplicateFile(db *gorm.DB, store storage.Storage, key string) (string, string, bool, error) {
+	hash, err := hashStoredFile(store, key)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	for _, table := range deduplicatedTables {
+		var existingKeys []string
+		if err := db.Table(table).
+			Where("sha256 = ? AND file_path <> ?", hash, key).
+			Distinct().
+			Pluck("file_path", &existingKeys).Error; err != nil {
+			return "", "", false, err
+		}
+
+		for _, existing := range existingKeys {
+			// Solo reutilizar si el archivo existente sigue intacto
+			if existingHash, err := hashStoredFile(store, existing); err == nil && existingHash == hash {
+				removeStoredFiles(store, key)
+				return existing, hash, true, nil
+			}
+		}
+	}
+
+	return key, hash, false, nil
+}
+
+// imageDerivativesFor returns the thumbnail and medium versions of a stored image,
+// reusing the ones already generated for a deduplicated file when they are still stored.
+func imageDerivativesFor(db *gorm.DB, store storage.Storage, key string) (string, string, error) {
+	for _, table := range []string{"picture_models", "inpl_fichas"} {
+		var row struct {
+			ThumbnailPath string
+			MediumPath    string
+		}
+		err := db.Table(table).
+			Select("thumbnail_path, medium_path").
+			Where("file_path = ? AND thumbnail_path <> ''", key).
+			Limit(1).
+			Scan(&row).Error
+		if err == nil && storage.Exists(store, row.ThumbnailPath) && storage.Exists(store, row.MediumPath) {
+			return row.ThumbnailPath, row.MediumPath, nil
+		}
+	}
+	return generateImageDerivatives(store, key)
+}
+
+// generateImageDerivatives creates the thumbnail and medium JPEG versions of a stored image next to it
+func generateImageDerivatives(store storage.Storage, key string) (string, string, error) {
+	r, err := store.Open(key)
+	if err != nil {
+		return "", "", fmt.Errorf("no se pudo abrir la imagen: %w", err)
+	}
+	defer r.Close()
+
+	thumb, medium, err := utils.EncodeImageDerivatives(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	thumbKey := utils.DerivativePath(key, utils.ImageSizeThumb)
+	if err := store.Put(thumbKey, bytes.NewReader(thumb), int64(len(thumb)), "image/jpeg"); err != nil {
+		return "", "", fmt.Errorf("no se pudo guardar la miniatura: %w", err)
+	}
+
+	mediumKey := utils.DerivativePath(key, utils.ImageSizeMedium)
+	if err := store.Put(mediumKey, bytes.NewReader(medium), int64(len(medium)), "image/jpeg"); err != nil {
+		removeStoredFiles(store, thumbKey)
+		return "", "", fmt.Errorf("no se pudo guardar la imagen mediana: %w", err)
+	}
+
+	return thumbKey, mediumKey, nil
+}
+
+// removeStoredFiles deletes files from the storage, skipping empty keys. Failures are only logged.
+func removeStoredFiles(store storage.Storage, keys ...string) {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if err := store.Delete(key); err != nil {
+			log.Printf("[FILES] No se pudo eliminar %s: %v", key, err)
+		}
+	}
+}
+
+// removeFileIfUnreferenced deletes a stored file (and its derivatives) only when no row points to it anymore.
+// Deduplicated files are shared by several rows, so they must outlive all of them.
+func removeFileIfUnreferenced(db *gorm.DB, store storage.Storage, key string, derivatives ...string) {
+	if key == "" {
+		return
+	}
+
+	for _, table := range fileReferenceTables {
+		var count int64
+		if err := db.Table(table).Where("file_path = ?", key).Count(&count).Error; err != nil {
+			log.Printf("[FILES] No se pudo verificar referencias de %s: %v", key, err)
+			return
+		}
+		if count > 0 {
+			return
+		}
+	}
+
+	removeStoredFiles(store, append([]string{key}, derivatives...)...)
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/storage"
+	"gorm.io/gorm"
+)
+
+// quarantineRoot is where reconciliation moves orphaned files and backs up dangling rows
+// (outside uploads, which is served statically)
+const quarantineRoot = "private/quarantine"
+
+// defaultOrphanMinAge protects files of uploads still in progress (stored but not yet saved in the DB)
+const defaultOrphanMinAge = 60 * time.Minute
+
+// OrphanFile is a stored file that no picture, historical record, INPL ficha or loan document points to
+type OrphanFile struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// DanglingRecord is a row whose file is no longer in the storage
+type DanglingRecord struct {
+	Kind       string `json:"kind"` // picture, historical_record, inpl_ficha, loan_document
+	ID         int    `json:"id"`
+	ArtefactID *int   `json:"artefactId,omitempty"`
+	FilePath   string `json:"filePath"`
+}
+
+// ReconciliationReport lists orphans in both directions
+type ReconciliationReport struct {
+	ScannedAt       time.Time        `json:"scannedAt"`
+	ScannedRoots    []string         `json:"scannedRoots"`
+	ScannedFiles    int              `json:"scannedFiles"`
+	OrphanFiles     []OrphanFile     `json:"orphanFiles"`
+	DanglingRecords []DanglingRecord `json:"danglingRecords"`
+	// RecentFiles are orphans skipped because they are newer than the minimum age
+	RecentFiles int `json:"recentFiles"`
+}
+
+// ReconciliationResult is the outcome of acting on a report
+type ReconciliationResult struct {
+	Action         string               `json:"action"`
+	QuarantineDir  string               `json:"quarantineDir,omitempty"`
+	FilesHandled   int                  `json:"filesHandled"`
+	RecordsHandled int                  `json:"recordsHandled"`
+	Errors         []string             `json:"errors"`
+	Report         ReconciliationReport `json:"report"`
+}
+
+type FileReconciliationService struct {
+	db              *gorm.DB
+	store           storage.Storage
+	artefactService *ArtefactService
+	uploadRoots     []string
+}
+
+// NewFileReconciliationService creates a new instance of FileReconciliationService.
+// uploadRoots are the storage prefixes scanned for orphaned files.
+func NewFileReconciliationService(db *gorm.DB, store storage.Storage, artefactService *ArtefactService, uploadRoots []string) *FileReconciliationService {
+	return &FileReconciliationService{db: db, store: store, artefactService: artefactService, uploadRoots: uploadRoots}
+}
+
+// Scan compares the upload roots against the rows of pictures, historical records, INPL fichas and loan documents
+func (s *FileReconciliationService) Scan(minAge time.Duration) (*ReconciliationReport, error) {
+	report := &ReconciliationReport{
+		ScannedAt:       time.Now(),
+		ScannedRoots:    s.uploadRoots,
+		OrphanFiles:     []OrphanFile{},
+		DanglingRecords: []DanglingRecord{},
+	}
+
+	referenced, err := s.referencedKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	stored := map[string]bool{}
+	for _, root := range s.uploadRoots {
+		files, err := s.store.List(strings.TrimSuffix(root, "/") + "/")
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo listar %s: %w", root, err)
+		}
+		for _, f := range files {
+			if stored[f.Key] {
+				continue
+			}
+			stored[f.Key] = true
+			report.ScannedFiles++
+
+			if referenced[f.Key] {
+				continue
+			}
+			if time.Since(f.ModTime) < minAge {
+				report.RecentFiles++
+				continue
+			}
+			report.OrphanFiles = append(report.OrphanFiles, OrphanFile{Key: f.Key, Size: f.Size, ModTime: f.ModTime})
+		}
+	}
+
+	dangling, err := s.danglingRecords(stored)
+	if err != nil {
+		return nil, err
+	}
+	report.DanglingRecords = dangling
+
+	return report, nil
+}
+
+// Reconcile scans and then quarantines or purges the orphans of the selected target (files, records or all)
+func (s *FileReconciliationService) Reconcile(dto *dtos.ReconcileFilesDTO) (*ReconciliationResult, error) {
+	if dto.Action != "quarantine" && dto.Action != "purge" {
+		return nil, errors.New("acción inválida: usar quarantine o purge")
+	}
+	target := dto.Target
+	if target == "" {
+		target = "all"
+	}
+	minAge := defaultOrphanMinAge
+	if dto.MinAgeMinutes != nil && *dto.MinAgeMinutes >= 0 {
+		minAge = time.Duration(*dto.MinAgeMinutes) * time.Minute
+	}
+
+	report, err := s.Scan(minAge)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReconciliationResult{Action: dto.Action, Errors: []string{}, Report: *report}
+	if dto.Action == "quarantine" {
+		result.QuarantineDir = path.Join(quarantineRoot, time.Now().Format("20060102_150405"))
+	}
+
+	if target == "files" || target == "all" {
+		for _, orphan := range report.OrphanFiles {
+			var err error
+			if dto.Action == "quarantine" {
+				err = s.quarantineFile(result.QuarantineDir, orphan.Key)
+			} else {
+				err = s.store.Delete(orphan.Key)
+			}
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", orphan.Key, err))
+				continue
+			}
+			result.FilesHandled++
+		}
+	}
+
+	if target == "records" || target == "all" {
+		for _, record := range report.DanglingRecords {
+			if err := s.removeDanglingRecord(result.QuarantineDir, record); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s %d: %v", record.Kind, record.ID, err))
+				continue
+			}
+			result.RecordsHandled++
+			if record.ArtefactID != nil {
+				s.artefactService.invalidateArtefactFilesCache(*record.ArtefactID)
+			}
+		}
+	}
+
+	log.Printf("[RECONCILE] %s: %d archivos, %d registros, %d errores",
+		dto.Action, result.FilesHandled, result.RecordsHandled, len(result.Errors))
+	return result, nil
+}
+
+// referencedKeys collects every storage key pointed to by a row (originals and derivatives)
+func (s *FileReconciliationService) referencedKeys() (map[string]bool, error) {
+	columns := map[string][]string{
+		"picture_models":           {"file_path", "thumbnail_path", "medium_path"},
+		"historical_record_models": {"file_path"},
+		"inpl_fichas":              {"file_path", "thumbnail_path", "medium_path"},
+		"loan_document_models":     {"file_path"},
+	}
+
+	referenced := map[string]bool{}
+	for table, cols := range columns {
+		for _, col := range cols {
+			var keys []string
+			if err := s.db.Table(table).Where(col+" <> ''").Distinct().Pluck(col, &keys).Error; err != nil {
+				return nil, err
+			}
+			for _, k := range keys {
+				referenced[k] = true
+			}
+		}
+	}
+	return referenced, nil
+}
+
+// danglingRecords finds rows whose file isn't stored. Keys outside the scanned roots are checked one by one.
+func (s *FileReconciliationService) danglingRecords(stored map[string]bool) ([]DanglingRecord, error) {
+	type fileRow struct {
+		ID         int
+		ArtefactID *int
+		FilePath   string
+	}
+
+	queries := []struct {
+		kind  string
+		model interface{}
+		cols  string
+	}{
+		{"picture", &models.PictureModel{}, "id, artefact_id, file_path"},
+		{"historical_record", &models.HistoricalRecordModel{}, "id, artefact_id, file_path"},
+		{"inpl_ficha", &models.INPLFicha{}, "id, file_path"},
+		{"loan_document", &models.LoanDocumentModel{}, "id, file_path"},
+	}
+
+	dangling := []DanglingRecord{}
+	for _, q := range queries {
+		var rows []fileRow
+		if err := s.db.Model(q.model).Select(q.cols).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if stored[row.FilePath] || (!s.underUploadRoots(row.FilePath) && storage.Exists(s.store, row.FilePath)) {
+				continue
+			}
+			dangling = append(dangling, DanglingRecord{Kind: q.kind, ID: row.ID, ArtefactID: row.ArtefactID, FilePath: row.FilePath})
+		}
+	}
+	return dangling, nil
+}
+
+// underUploadRoots reports whether the key was covered by the scan
+func (s *FileReconciliationService) underUploadRoots(key string) bool {
+	for _, root := range s.uploadRoots {
+		if strings.HasPrefix(key, strings.TrimSuffix(root, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// quarantineFile moves a file under the quarantine directory, keeping its original key as relative path
+func (s *FileReconciliationService) quarantineFile(dir, key string) error {
+	r, err := s.store.Open(key)
+	if err != nil {
+		return err
+	}
+	info, err := s.store.Stat(key)
+	if err != nil {
+		r.Close()
+		return err
+	}
+
+	dest := path.Join(dir, "files", strings.TrimPrefix(key, "/"))
+	err = s.store.Put(dest, r, info.Size, "")
+	r.Close()
+	if err != nil {
+		return err
+	}
+	return s.store.Delete(key)
+}
+
+// removeDanglingRecord deletes a row whose file is missing. When dir is set (quarantine),
+// the row is first backed up as JSON so it can be restored if the file turns up.
+func (s *FileReconciliationService) removeDanglingRecord(dir string, record DanglingRecord) error {
+	var model interface{}
+	switch record.Kind {
+	case "picture":
+		model = &models.PictureModel{}
+	case "historical_record":
+		model = &models.HistoricalRecordModel{}
+	case "loan_document":
+		model = &models.LoanDocumentModel{}
+	default:
+		model = &models.INPLFicha{}
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(model, record.ID).Error; err != nil {
+			return err
+		}
+
+		if dir != "" {
+			backup, err := json.MarshalIndent(model, "", "  ")
+			if err != nil {
+				return err
+			}
+			key := path.Join(dir, "records", fmt.Sprintf("%s_%d.json", record.Kind, record.ID))
+			if err := s.store.Put(key, bytes.NewReader(backup), int64(len(backup)), "application/json"); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Delete(model, record.ID).Error; err != nil {
+			return err
+		}
+
+		// Si se borró la foto/documento principal, el siguiente pasa a serlo
+		if record.ArtefactID != nil && record.Kind != "inpl_ficha" {
+			return promoteFirstFile(tx, model, *record.ArtefactID)
+		}
+		return nil
+	})
+}
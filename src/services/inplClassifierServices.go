@@ -1,15 +1,18 @@
 package services
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
-	"os"
+	"log"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/storage"
+	"github.com/ARQAP/ARQAP-Backend/src/utils"
 	"gorm.io/gorm"
 )
 
@@ -22,12 +25,18 @@ type FichaUpload struct {
 
 type INPLService struct {
 	db         *gorm.DB
+	store      storage.Storage
 	uploadRoot string
 }
 
 // NewINPLService creates a new INPLService instance
-func NewINPLService(db *gorm.DB, uploadRoot string) *INPLService {
-	return &INPLService{db: db, uploadRoot: uploadRoot}
+func NewINPLService(db *gorm.DB, store storage.Storage, uploadRoot string) *INPLService {
+	return &INPLService{db: db, store: store, uploadRoot: uploadRoot}
+}
+
+// Storage returns the backend where the fichas are stored
+func (s *INPLService) Storage() storage.Storage {
+	return s.store
 }
 
 // CreateClassifierWithFichas creates a new INPLClassifier with associated fichas (photos)
@@ -35,13 +44,9 @@ func (s *INPLService) CreateClassifierWithFichas(files []FichaUpload) (*models.I
 	if len(files) == 0 {
 		return nil, nil, errors.New("at least one photo is required")
 	}
-	for i, f := range files {
-		if f.Reader == nil || f.Size <= 0 {
-			return nil, nil, fmt.Errorf("photo %d is invalid", i)
-		}
-		if !isAllowedImageType(f.ContentType) {
-			return nil, nil, fmt.Errorf("photo %d: content-type not allowed: %s", i, f.ContentType)
-		}
+	uploads, err := validateFichaUploads(files)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	tx := s.db.Begin()
@@ -55,37 +60,39 @@ func (s *INPLService) CreateClassifierWithFichas(files []FichaUpload) (*models.I
 		return nil, nil, err
 	}
 
-	// Crear carpeta única para todas las fichas INPL (sin subcarpetas por clasificador)
-	if err := os.MkdirAll(s.uploadRoot, 0o755); err != nil {
-		tx.Rollback()
-		return nil, nil, err
-	}
-
 	var saved []string
 	var fichas []models.INPLFicha
 	for i, fu := range files {
+		upload := uploads[i]
 		// Generar nombre único incluyendo el ID del clasificador en el nombre del archivo
-		name := fmt.Sprintf("ficha_%d_%s", cls.ID, buildSafeFilename(fu.OriginalName, i))
+		name := fichaFilename(cls.ID, upload.Filename, i)
 		path := filepath.Join(s.uploadRoot, name)
-		if err := saveToFile(path, fu.Reader); err != nil {
-			cleanupFiles(saved)
+		if err := s.store.Put(path, bytes.NewReader(upload.Data), int64(len(upload.Data)), upload.ContentType); err != nil {
+			removeStoredFiles(s.store, saved...)
 			tx.Rollback()
 			return nil, nil, err
 		}
-		saved = append(saved, path)
 
 		rec := models.INPLFicha{
 			INPLClassifierID: cls.ID,
 			Filename:         name,
 			OriginalName:     fu.OriginalName,
 			FilePath:         path,
-			ContentType:      fu.ContentType,
-			Size:             fu.Size,
+			ContentType:      upload.ContentType,
+			Size:             int64(len(upload.Data)),
 			CreatedAt:        time.Now(),
 			UpdatedAt:        time.Now(),
 		}
+		stored, err := storeFichaFile(tx, s.store, &rec)
+		if err != nil {
+			removeStoredFiles(s.store, path)
+			removeStoredFiles(s.store, saved...)
+			tx.Rollback()
+			return nil, nil, err
+		}
+		saved = append(saved, stored...)
 		if err := tx.Create(&rec).Error; err != nil {
-			cleanupFiles(saved)
+			removeStoredFiles(s.store, saved...)
 			tx.Rollback()
 			return nil, nil, err
 		}
@@ -93,37 +100,31 @@ func (s *INPLService) CreateClassifierWithFichas(files []FichaUpload) (*models.I
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		cleanupFiles(saved)
+		removeStoredFiles(s.store, saved...)
 		return nil, nil, err
 	}
 	return cls, fichas, nil
 }
 
-// isAllowedImageType checks if the content type is one of the allowed image types
-func isAllowedImageType(ct string) bool {
-	ct = strings.ToLower(ct)
-	return ct == "image/jpeg" || ct == "image/jpg" || ct == "image/png" || ct == "image/webp"
-}
-
-// sanitizeFilename removes potentially dangerous characters from filenames
-func sanitizeFilename(name string) string {
-	name = strings.TrimSpace(name)
-	name = strings.ReplaceAll(name, "..", "")
-	name = strings.Map(func(r rune) rune {
-		if (r >= 'a' && r <= 'z') ||
-			(r >= 'A' && r <= 'Z') ||
-			(r >= '0' && r <= '9') ||
-			strings.ContainsRune("._- ", r) {
-			return r
+// validateFichaUploads checks every ficha by content (type, size, structure) before anything is stored
+func validateFichaUploads(files []FichaUpload) ([]*utils.ValidatedUpload, error) {
+	uploads := make([]*utils.ValidatedUpload, len(files))
+	for i, f := range files {
+		if f.Reader == nil {
+			return nil, fmt.Errorf("photo %d is invalid", i)
 		}
-		return '_'
-	}, name)
-	return name
+		upload, err := utils.ValidateUpload(utils.UploadKindFicha, f.Reader, f.OriginalName)
+		if err != nil {
+			return nil, fmt.Errorf("photo %d: %w", i, err)
+		}
+		uploads[i] = upload
+	}
+	return uploads, nil
 }
 
 // buildSafeFilename creates a safe filename, appending an index if needed
 func buildSafeFilename(original string, idx int) string {
-	base := sanitizeFilename(original)
+	base := utils.SanitizeFilename(original)
 	if base == "" {
 		base = fmt.Sprintf("photo_%d", time.Now().UnixNano())
 	}
@@ -136,22 +137,49 @@ func buildSafeFilename(original string, idx int) string {
 	return base
 }
 
-// saveToFile saves the content from the reader to the specified path
-func saveToFile(path string, r io.Reader) error {
-	f, err := os.Create(path)
+// fichaFilename builds the storage name of a ficha. The timestamp keeps two uploads with the same name
+// from sharing a key, since an overwrite would also change the fichas deduplicated onto that file.
+func fichaFilename(classifierID int, original string, idx int) string {
+	return fmt.Sprintf("ficha_%d_%d_%s", classifierID, time.Now().UnixNano(), buildSafeFilename(original, idx))
+}
+
+// attachFichaDerivatives generates the thumbnail and medium versions of a ficha image.
+// Failures are logged and the ficha keeps only its original. Returns the created paths.
+func attachFichaDerivatives(store storage.Storage, rec *models.INPLFicha) []string {
+	thumbPath, mediumPath, err := generateImageDerivatives(store, rec.FilePath)
 	if err != nil {
-		return err
+		log.Printf("[INPL] No se pudieron generar derivados para %s: %v", rec.FilePath, err)
+		return nil
 	}
-	defer f.Close()
-	_, err = io.Copy(f, r)
-	return err
+	rec.ThumbnailPath = thumbPath
+	rec.MediumPath = mediumPath
+	return []string{thumbPath, mediumPath}
 }
 
-// cleanupFiles removes files at the given paths
-func cleanupFiles(paths []string) {
-	for _, p := range paths {
-		_ = os.Remove(p)
+// storeFichaFile records the SHA-256 of a freshly saved ficha, reuses an identical stored file if there is one
+// and attaches its derivatives. Returns the paths created for this ficha, to clean up if saving it fails.
+func storeFichaFile(db *gorm.DB, store storage.Storage, rec *models.INPLFicha) ([]string, error) {
+	finalPath, hash, reused, err := deduplicateFile(db, store, rec.FilePath)
+	if err != nil {
+		return nil, err
 	}
+	rec.Sha256 = hash
+
+	if reused {
+		rec.FilePath = finalPath
+		rec.Filename = filepath.Base(finalPath)
+		thumbPath, mediumPath, err := imageDerivativesFor(db, store, finalPath)
+		if err != nil {
+			log.Printf("[INPL] No se pudieron generar derivados para %s: %v", finalPath, err)
+		} else {
+			rec.ThumbnailPath = thumbPath
+			rec.MediumPath = mediumPath
+		}
+		// El archivo pertenece también a otras fichas, no debe limpiarse
+		return nil, nil
+	}
+
+	return append([]string{rec.FilePath}, attachFichaDerivatives(store, rec)...), nil
 }
 
 // AddFichasToClassifier adds multiple fichas to an existing INPLClassifier
@@ -160,13 +188,9 @@ func (s *INPLService) AddFichasToClassifier(classifierID int, files []FichaUploa
 		return nil, errors.New("no files provided")
 	}
 
-	for i, f := range files {
-		if f.Reader == nil || f.Size <= 0 {
-			return nil, fmt.Errorf("photo %d is invalid", i)
-		}
-		if !isAllowedImageType(f.ContentType) {
-			return nil, fmt.Errorf("photo %d: content-type not allowed: %s", i, f.ContentType)
-		}
+	uploads, err := validateFichaUploads(files)
+	if err != nil {
+		return nil, err
 	}
 
 	tx := s.db.Begin()
@@ -180,38 +204,40 @@ func (s *INPLService) AddFichasToClassifier(classifierID int, files []FichaUploa
 		return nil, errors.New("classifier does not exist")
 	}
 
-	// Crear carpeta única para todas las fichas INPL (sin subcarpetas por clasificador)
-	if err := os.MkdirAll(s.uploadRoot, 0o755); err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-
 	var saved []string
 	var out []models.INPLFicha
 
 	for idx, fu := range files {
+		upload := uploads[idx]
 		// Generar nombre único incluyendo el ID del clasificador en el nombre del archivo
-		filename := fmt.Sprintf("ficha_%d_%s", classifierID, buildSafeFilename(fu.OriginalName, idx))
+		filename := fichaFilename(classifierID, upload.Filename, idx)
 		final := filepath.Join(s.uploadRoot, filename)
-		if err := saveToFile(final, fu.Reader); err != nil {
-			cleanupFiles(saved)
+		if err := s.store.Put(final, bytes.NewReader(upload.Data), int64(len(upload.Data)), upload.ContentType); err != nil {
+			removeStoredFiles(s.store, saved...)
 			tx.Rollback()
 			return nil, err
 		}
-		saved = append(saved, final)
 
 		rec := models.INPLFicha{
 			INPLClassifierID: classifierID,
 			Filename:         filename,
 			OriginalName:     fu.OriginalName,
 			FilePath:         final,
-			ContentType:      fu.ContentType,
-			Size:             fu.Size,
+			ContentType:      upload.ContentType,
+			Size:             int64(len(upload.Data)),
 			CreatedAt:        time.Now(),
 			UpdatedAt:        time.Now(),
 		}
+		stored, err := storeFichaFile(tx, s.store, &rec)
+		if err != nil {
+			removeStoredFiles(s.store, final)
+			removeStoredFiles(s.store, saved...)
+			tx.Rollback()
+			return nil, err
+		}
+		saved = append(saved, stored...)
 		if err := tx.Create(&rec).Error; err != nil {
-			cleanupFiles(saved)
+			removeStoredFiles(s.store, saved...)
 			tx.Rollback()
 			return nil, err
 		}
@@ -219,7 +245,7 @@ func (s *INPLService) AddFichasToClassifier(classifierID int, files []FichaUploa
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		cleanupFiles(saved)
+		removeStoredFiles(s.store, saved...)
 		return nil, err
 	}
 	return out, nil
@@ -234,7 +260,7 @@ func (s *INPLService) DeleteFicha(fichaID int) error {
 	if err := s.db.Delete(&models.INPLFicha{}, fichaID).Error; err != nil {
 		return err
 	}
-	_ = os.Remove(f.FilePath)
+	removeFileIfUnreferenced(s.db, s.store, f.FilePath, f.ThumbnailPath, f.MediumPath)
 	return nil
 }
 
@@ -295,7 +321,7 @@ func (s *INPLService) DeleteClassifier(id int) error {
 
 	// Eliminar archivos de fichas (ahora están directamente en uploads/inpl/)
 	for _, f := range fichas {
-		_ = os.Remove(f.FilePath)
+		removeFileIfUnreferenced(s.db, s.store, f.FilePath, f.ThumbnailPath, f.MediumPath)
 	}
 
 	return nil
@@ -303,11 +329,12 @@ func (s *INPLService) DeleteClassifier(id int) error {
 
 // ReplaceFicha replaces the file of an existing INPLFicha
 func (s *INPLService) ReplaceFicha(fichaID int, file FichaUpload) (*models.INPLFicha, error) {
-	if file.Reader == nil || file.Size <= 0 {
+	if file.Reader == nil {
 		return nil, errors.New("invalid file")
 	}
-	if !isAllowedImageType(file.ContentType) {
-		return nil, fmt.Errorf("content-type not allowed: %s", file.ContentType)
+	upload, err := utils.ValidateUpload(utils.UploadKindFicha, file.Reader, file.OriginalName)
+	if err != nil {
+		return nil, err
 	}
 
 	var f models.INPLFicha
@@ -317,27 +344,38 @@ func (s *INPLService) ReplaceFicha(fichaID int, file FichaUpload) (*models.INPLF
 
 	// Usar la carpeta única de INPL (sin subcarpetas por clasificador)
 	// Obtener el ID del clasificador desde la ficha existente
-	name := fmt.Sprintf("ficha_%d_%s", f.INPLClassifierID, buildSafeFilename(file.OriginalName, 0))
+	name := fichaFilename(f.INPLClassifierID, upload.Filename, 0)
 	newPath := filepath.Join(s.uploadRoot, name)
 
-	if err := saveToFile(newPath, file.Reader); err != nil {
+	if err := s.store.Put(newPath, bytes.NewReader(upload.Data), int64(len(upload.Data)), upload.ContentType); err != nil {
+		return nil, err
+	}
+	replacement := models.INPLFicha{Filename: name, FilePath: newPath}
+	stored, err := storeFichaFile(s.db, s.store, &replacement)
+	if err != nil {
+		removeStoredFiles(s.store, newPath)
 		return nil, err
 	}
 
 	upd := map[string]any{
-		"filename":      name,
-		"original_name": file.OriginalName,
-		"file_path":     newPath,
-		"content_type":  file.ContentType,
-		"size":          file.Size,
-		"updated_at":    time.Now(),
+		"filename":       replacement.Filename,
+		"original_name":  file.OriginalName,
+		"file_path":      replacement.FilePath,
+		"content_type":   upload.ContentType,
+		"size":           int64(len(upload.Data)),
+		"thumbnail_path": replacement.ThumbnailPath,
+		"medium_path":    replacement.MediumPath,
+		"sha256":         replacement.Sha256,
+		"updated_at":     time.Now(),
 	}
 	if err := s.db.Model(&models.INPLFicha{}).Where("id = ?", fichaID).Updates(upd).Error; err != nil {
-		_ = os.Remove(newPath)
+		removeStoredFiles(s.store, stored...)
 		return nil, err
 	}
 
-	_ = os.Remove(f.FilePath)
+	if f.FilePath != replacement.FilePath {
+		removeFileIfUnreferenced(s.db, s.store, f.FilePath, f.ThumbnailPath, f.MediumPath)
+	}
 
 	if err := s.db.First(&f, fichaID).Error; err != nil {
 		return nil, err
@@ -345,6 +383,31 @@ func (s *INPLService) ReplaceFicha(fichaID int, file FichaUpload) (*models.INPLF
 	return &f, nil
 }
 
+// FichaFileForSize returns the path and content type to serve for the requested size (thumb, medium, original).
+// Fichas stored before derivatives existed get them generated on first request.
+func (s *INPLService) FichaFileForSize(f *models.INPLFicha, size string) (string, string) {
+	if size == utils.ImageSizeOriginal {
+		return f.FilePath, f.ContentType
+	}
+
+	if !storage.Exists(s.store, f.ThumbnailPath) || !storage.Exists(s.store, f.MediumPath) {
+		if len(attachFichaDerivatives(s.store, f)) == 0 {
+			return f.FilePath, f.ContentType
+		}
+		if err := s.db.Model(&models.INPLFicha{}).Where("id = ?", f.ID).Updates(map[string]any{
+			"thumbnail_path": f.ThumbnailPath,
+			"medium_path":    f.MediumPath,
+		}).Error; err != nil {
+			log.Printf("[INPL] No se pudieron guardar los derivados de la ficha %d: %v", f.ID, err)
+		}
+	}
+
+	if size == utils.ImageSizeThumb {
+		return f.ThumbnailPath, "image/jpeg"
+	}
+	return f.MediumPath, "image/jpeg"
+}
+
 // GetFichaByID retrieves an INPLFicha by its ID
 func (s *INPLService) GetFichaByID(id int) (*models.INPLFicha, error) {
 	var f models.INPLFicha
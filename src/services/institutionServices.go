@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInstitutionNotFound is returned when a requester, loan or loan request points to a missing institution
+	ErrInstitutionNotFound = errors.New("la institución no existe")
+	// ErrDuplicateInstitution is returned when another institution already has the same name
+	ErrDuplicateInstitution = errors.New("ya existe una institución con ese nombre")
+	// ErrInstitutionInUse is returned when deleting an institution that loans or loan requests are attributed to
+	ErrInstitutionInUse = errors.New("la institución tiene préstamos o solicitudes de préstamo asociados")
+)
+
+type InstitutionService struct {
+	db *gorm.DB
+}
+
+// NewInstitutionService creates a new instance of InstitutionService
+func NewInstitutionService(db *gorm.DB) *InstitutionService {
+	return &InstitutionService{db: db}
+}
+
+// GetAllInstitutions retrieves the institutions, optionally those whose name contains the given text
+func (s *InstitutionService) GetAllInstitutions(name string) ([]models.InstitutionModel, error) {
+	var institutions []models.InstitutionModel
+
+	query := s.db.Preload("Country")
+	if name = strings.TrimSpace(name); name != "" {
+		query = query.Where("name ILIKE ?", "%"+name+"%")
+	}
+
+	if err := query.Order("name ASC").Find(&institutions).Error; err != nil {
+		return nil, err
+	}
+	return institutions, nil
+}
+
+// GetInstitutionByID retrieves an institution with its contact people
+func (s *InstitutionService) GetInstitutionByID(id int) (*models.InstitutionModel, error) {
+	var institution models.InstitutionModel
+	if err := s.db.
+		Preload("Country").
+		Preload("Contacts", func(db *gorm.DB) *gorm.DB {
+			return db.Order("lastname ASC, firstname ASC")
+		}).
+		First(&institution, id).Error; err != nil {
+		return nil, err
+	}
+	return &institution, nil
+}
+
+// CreateInstitution creates a new institution. Contacts are linked from the requesters, not here.
+func (s *InstitutionService) CreateInstitution(institution *models.InstitutionModel) (*models.InstitutionModel, error) {
+	institution.Name = strings.TrimSpace(institution.Name)
+	if err := s.checkNameAvailable(institution.Name, 0); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Omit("Contacts").Create(institution).Error; err != nil {
+		return nil, err
+	}
+	return s.GetInstitutionByID(institution.Id)
+}
+
+// UpdateInstitution updates an existing institution
+func (s *InstitutionService) UpdateInstitution(id int, updatedInstitution *models.InstitutionModel) (*models.InstitutionModel, error) {
+	var institution models.InstitutionModel
+	if err := s.db.First(&institution, id).Error; err != nil {
+		return nil, err
+	}
+
+	updatedInstitution.Id = id
+	if updatedInstitution.Name != "" {
+		updatedInstitution.Name = strings.TrimSpace(updatedInstitution.Name)
+		if err := s.checkNameAvailable(updatedInstitution.Name, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.Model(&institution).Omit("Contacts", "created_at").Updates(updatedInstitution).Error; err != nil {
+		return nil, err
+	}
+	return s.GetInstitutionByID(id)
+}
+
+// DeleteInstitution deletes an institution that no loan or loan request is attributed to.
+// Its contact people are kept as individual requesters.
+func (s *InstitutionService) DeleteInstitution(id int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var institution models.InstitutionModel
+		if err := tx.First(&institution, id).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{&models.LoanModel{}, &models.LoanHeaderModel{}, &models.LoanRequestModel{}} {
+			var count int64
+			if err := tx.Model(model).Where("institution_id = ?", id).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrInstitutionInUse
+			}
+		}
+
+		if err := tx.Model(&models.RequesterModel{}).
+			Where("institution_id = ?", id).
+			Update("institution_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&institution).Error
+	})
+}
+
+// GetInstitutionContacts lists the requesters that act as contact people of an institution
+func (s *InstitutionService) GetInstitutionContacts(id int) ([]models.RequesterModel, error) {
+	if err := checkInstitutionExists(s.db, id); err != nil {
+		return nil, err
+	}
+
+	var contacts []models.RequesterModel
+	if err := s.db.Where("institution_id = ?", id).
+		Order("lastname ASC, firstname ASC").
+		Find(&contacts).Error; err != nil {
+		return nil, err
+	}
+	return contacts, nil
+}
+
+// checkNameAvailable fails when another institution (other than exceptId) has the same name, ignoring case
+func (s *InstitutionService) checkNameAvailable(name string, exceptId int) error {
+	var count int64
+	if err := s.db.Model(&models.InstitutionModel{}).
+		Where("LOWER(name) = LOWER(?) AND id <> ?", name, exceptId).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDuplicateInstitution
+	}
+	return nil
+}
+
+// checkInstitutionExists returns ErrInstitutionNotFound when there is no institution with that ID
+func checkInstitutionExists(db *gorm.DB, id int) error {
+	var count int64
+	if err := db.Model(&models.InstitutionModel{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInstitutionNotFound
+	}
+	return nil
+}
+
+// resolveLoanInstitution returns the institution a loan is attributed to: the given one (which must exist)
+// or, when none is given, the institution of the requester
+func resolveLoanInstitution(db *gorm.DB, requesterId, institutionId *int) (*int, error) {
+	if institutionId != nil {
+		if err := checkInstitutionExists(db, *institutionId); err != nil {
+			return nil, err
+		}
+		return institutionId, nil
+	}
+	if requesterId == nil {
+		return nil, nil
+	}
+
+	var requester models.RequesterModel
+	if err := db.Select("id", "institution_id").First(&requester, *requesterId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return requester.InstitutionId, nil
+}
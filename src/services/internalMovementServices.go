@@ -4,10 +4,26 @@ import (
 	"errors"
 	"time"
 
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
 	"github.com/ARQAP/ARQAP-Backend/src/models"
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrMovementAlreadyClosed is returned when returning a movement that was already finished
+	ErrMovementAlreadyClosed = errors.New("el movimiento interno ya fue finalizado")
+	// ErrInvalidMovementReturnDate is returned when the return date is before the movement date
+	ErrInvalidMovementReturnDate = errors.New("la fecha de retorno no puede ser anterior a la fecha del movimiento")
+	// ErrArtefactNotAvailableForMovement is returned when moving an artefact that is lent
+	ErrArtefactNotAvailableForMovement = errors.New("la pieza arqueológica no está disponible para movimientos internos (ya está prestada)")
+	// ErrMovementVoided is returned when operating on a voided movement
+	ErrMovementVoided = errors.New("el movimiento interno fue anulado")
+	// ErrMovementHasLaterMovements is returned when voiding a movement the piece already left with another one
+	ErrMovementHasLaterMovements = errors.New("la pieza tiene movimientos posteriores; deben anularse primero")
+	// ErrMovementDestinationLocked is returned when changing the destination of a movement that is no longer the piece's current one
+	ErrMovementDestinationLocked = errors.New("solo se puede cambiar el destino del último movimiento de la pieza mientras no haya sido finalizado")
+)
+
 type InternalMovementService struct {
 	db *gorm.DB
 }
@@ -22,112 +38,24 @@ func stringPtr(s string) *string {
 	return &s
 }
 
-// CreateBatchInternalMovements creates multiple internal movements in a single transaction
-// All movements in the batch share the same groupMovementId for visual grouping
-func (s *InternalMovementService) CreateBatchInternalMovements(movements []*models.InternalMovementModel) ([]*models.InternalMovementModel, error) {
+// CreateBatchInternalMovements creates multiple internal movements in a single transaction.
+// All movements in the batch belong to a new movement group, which takes the reason and requester of the first one.
+func (s *InternalMovementService) CreateBatchInternalMovements(movements []*models.InternalMovementModel, createdById *int) ([]*models.InternalMovementModel, error) {
 	if len(movements) == 0 {
 		return []*models.InternalMovementModel{}, nil
 	}
 
-	// Generate a unique group ID (using timestamp + random or just timestamp)
-	// For simplicity, we'll use the current timestamp as the group ID
-	groupMovementId := int(time.Now().Unix())
-
-	var createdMovements []*models.InternalMovementModel
-
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		for _, movement := range movements {
-			// Assign the same group ID to all movements in the batch
-			movement.GroupMovementId = &groupMovementId
-
-			// 0) Verificar que la pieza esté disponible antes de crear el movimiento
-			var artefact models.ArtefactModel
-			if err := tx.First(&artefact, movement.ArtefactId).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					return errors.New("la pieza arqueológica no existe")
-				}
-				return err
-			}
-			
-			if !artefact.Available {
-				return errors.New("la pieza arqueológica no está disponible para movimientos internos (ya está prestada)")
-			}
-
-			// Use the existing CreateInternalMovement logic for each movement
-			// But we need to do it within this transaction
-			// 1) Buscar movimientos activos previos de la misma pieza
-			var activeMovements []models.InternalMovementModel
-			if err := tx.Where("artefact_id = ? AND return_date IS NULL AND return_time IS NULL", movement.ArtefactId).
-				Order("movement_date DESC, movement_time DESC").
-				Find(&activeMovements).Error; err != nil {
-				return err
-			}
-
-			// Si hay un movimiento activo previo, usar su ubicación destino como origen del nuevo movimiento
-			if len(activeMovements) > 0 {
-				mostRecentActive := activeMovements[0]
-				if movement.FromPhysicalLocationId == nil && mostRecentActive.ToPhysicalLocationId != nil {
-					movement.FromPhysicalLocationId = mostRecentActive.ToPhysicalLocationId
-				}
-
-				// Finalizar todos los movimientos activos previos
-				now := time.Now()
-				for _, activeMovement := range activeMovements {
-					activeMovement.ReturnDate = &now
-					activeMovement.ReturnTime = &now
-					if err := tx.Model(&activeMovement).Updates(map[string]interface{}{
-						"return_date": activeMovement.ReturnDate,
-						"return_time": activeMovement.ReturnTime,
-					}).Error; err != nil {
-						return err
-					}
-				}
-			} else {
-				// Si no hay movimiento activo previo, obtener la ubicación actual de la pieza
-				var artefact models.ArtefactModel
-				if err := tx.First(&artefact, movement.ArtefactId).Error; err != nil {
-					return err
-				}
-
-				if movement.FromPhysicalLocationId == nil && artefact.PhysicalLocationID != nil {
-					fromLocationId := *artefact.PhysicalLocationID
-					movement.FromPhysicalLocationId = &fromLocationId
-				}
-			}
-
-			// 2) Crear el nuevo movimiento
-			if err := tx.Create(movement).Error; err != nil {
-				return err
-			}
-
-			// 3) Mover la pieza a la ubicación destino del nuevo movimiento
-			if movement.ArtefactId != 0 {
-				updateData := map[string]interface{}{}
-				if movement.ToPhysicalLocationId != nil {
-					updateData["physical_location_id"] = *movement.ToPhysicalLocationId
-				} else {
-					updateData["physical_location_id"] = nil
-				}
-
-				if err := tx.Model(&models.ArtefactModel{}).
-					Where("id = ?", movement.ArtefactId).
-					Updates(updateData).Error; err != nil {
-					return err
-				}
-			}
-
-			createdMovements = append(createdMovements, movement)
-		}
-
-		return nil
-	})
-
-	if err != nil {
+	group := &models.MovementGroupModel{
+		Reason:      movements[0].Reason,
+		RequesterId: movements[0].RequesterId,
+		CreatedById: createdById,
+	}
+	if err := s.createMovementGroup(group, movements); err != nil {
 		return nil, err
 	}
 
 	// Preload relationships for all created movements
-	for i := range createdMovements {
+	for i := range movements {
 		if err := s.db.
 			Preload("Artefact").
 			Preload("Artefact.InternalClassifier").
@@ -136,19 +64,28 @@ func (s *InternalMovementService) CreateBatchInternalMovements(movements []*mode
 			Preload("ToPhysicalLocation").
 			Preload("ToPhysicalLocation.Shelf").
 			Preload("Requester").
-			First(createdMovements[i], createdMovements[i].Id).Error; err != nil {
+			First(movements[i], movements[i].Id).Error; err != nil {
 			return nil, err
 		}
 	}
 
-	return createdMovements, nil
+	return movements, nil
+}
+
+// excludeVoidedMovements leaves voided movements out of a query unless includeVoided is set
+func excludeVoidedMovements(db *gorm.DB, includeVoided bool) *gorm.DB {
+	if includeVoided {
+		return db
+	}
+	return db.Where("voided_at IS NULL")
 }
 
-// GetAllInternalMovements retrieves all InternalMovement records from the database
-func (s *InternalMovementService) GetAllInternalMovements() ([]models.InternalMovementModel, error) {
+// GetAllInternalMovements retrieves all InternalMovement records from the database.
+// Voided movements are only included when asked for.
+func (s *InternalMovementService) GetAllInternalMovements(includeVoided bool) ([]models.InternalMovementModel, error) {
 	var movements []models.InternalMovementModel
 
-	result := s.db.
+	result := excludeVoidedMovements(s.db, includeVoided).
 		Preload("Artefact").
 		Preload("Artefact.InternalClassifier").
 		Preload("FromPhysicalLocation").
@@ -182,11 +119,12 @@ func (s *InternalMovementService) GetInternalMovementByID(id int) (*models.Inter
 	return &movement, nil
 }
 
-// GetInternalMovementsByArtefactID retrieves all movements for a specific artefact
-func (s *InternalMovementService) GetInternalMovementsByArtefactID(artefactId int) ([]models.InternalMovementModel, error) {
+// GetInternalMovementsByArtefactID retrieves all movements for a specific artefact.
+// Voided movements are only included when asked for.
+func (s *InternalMovementService) GetInternalMovementsByArtefactID(artefactId int, includeVoided bool) ([]models.InternalMovementModel, error) {
 	var movements []models.InternalMovementModel
 
-	result := s.db.
+	result := excludeVoidedMovements(s.db, includeVoided).
 		Where("artefact_id = ?", artefactId).
 		Preload("Artefact").
 		Preload("Artefact.InternalClassifier").
@@ -201,12 +139,12 @@ func (s *InternalMovementService) GetInternalMovementsByArtefactID(artefactId in
 	return movements, result.Error
 }
 
-// GetActiveInternalMovementByArtefactID retrieves the active movement (not finished) for a specific artefact
+// GetActiveInternalMovementByArtefactID retrieves the active movement (not finished nor voided) for a specific artefact
 func (s *InternalMovementService) GetActiveInternalMovementByArtefactID(artefactId int) (*models.InternalMovementModel, error) {
 	var movement models.InternalMovementModel
 
 	result := s.db.
-		Where("artefact_id = ? AND return_date IS NULL AND return_time IS NULL", artefactId).
+		Where("artefact_id = ? AND return_date IS NULL AND return_time IS NULL AND voided_at IS NULL", artefactId).
 		Preload("Artefact").
 		Preload("Artefact.InternalClassifier").
 		Preload("FromPhysicalLocation").
@@ -227,89 +165,11 @@ func (s *InternalMovementService) GetActiveInternalMovementByArtefactID(artefact
 // y actualiza la ubicación física de la pieza
 // Si la pieza ya tiene un movimiento activo, lo finaliza primero y usa su ubicación destino como origen del nuevo
 func (s *InternalMovementService) CreateInternalMovement(movement *models.InternalMovementModel) (*models.InternalMovementModel, error) {
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		// 0) Verificar que la pieza esté disponible antes de crear el movimiento
-		var artefact models.ArtefactModel
-		if err := tx.First(&artefact, movement.ArtefactId).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return errors.New("la pieza arqueológica no existe")
-			}
-			return err
-		}
-		
-		if !artefact.Available {
-			return errors.New("la pieza arqueológica no está disponible para movimientos internos (ya está prestada)")
-		}
+	// Un movimiento suelto no pertenece a ningún grupo
+	movement.GroupMovementId = nil
 
-		// 1) Buscar movimientos activos previos de la misma pieza
-		var activeMovements []models.InternalMovementModel
-		if err := tx.Where("artefact_id = ? AND return_date IS NULL AND return_time IS NULL", movement.ArtefactId).
-			Order("movement_date DESC, movement_time DESC").
-			Find(&activeMovements).Error; err != nil {
-			return err
-		}
-
-		// Si hay un movimiento activo previo, usar su ubicación destino como origen del nuevo movimiento
-		if len(activeMovements) > 0 {
-			// Tomar el movimiento más reciente (el primero después de ordenar DESC)
-			mostRecentActive := activeMovements[0]
-
-			// Si el nuevo movimiento no tiene FromPhysicalLocation especificado,
-			// usar la ubicación destino del movimiento activo previo
-			if movement.FromPhysicalLocationId == nil && mostRecentActive.ToPhysicalLocationId != nil {
-				movement.FromPhysicalLocationId = mostRecentActive.ToPhysicalLocationId
-			}
-
-			// Finalizar todos los movimientos activos previos
-			now := time.Now()
-			for _, activeMovement := range activeMovements {
-				activeMovement.ReturnDate = &now
-				activeMovement.ReturnTime = &now
-
-				if err := tx.Model(&activeMovement).Updates(map[string]interface{}{
-					"return_date": activeMovement.ReturnDate,
-					"return_time": activeMovement.ReturnTime,
-				}).Error; err != nil {
-					return err
-				}
-			}
-			// NO devolvemos la pieza a la ubicación origen aquí porque vamos a crear un nuevo movimiento
-		} else {
-			// Si no hay movimiento activo previo, obtener la ubicación actual de la pieza
-			var artefact models.ArtefactModel
-			if err := tx.First(&artefact, movement.ArtefactId).Error; err != nil {
-				return err
-			}
-
-			// Si no se especificó FromPhysicalLocation, usar la ubicación actual de la pieza
-			if movement.FromPhysicalLocationId == nil && artefact.PhysicalLocationID != nil {
-				fromLocationId := *artefact.PhysicalLocationID
-				movement.FromPhysicalLocationId = &fromLocationId
-			}
-		}
-
-		// 2) Crear el nuevo movimiento
-		if err := tx.Create(movement).Error; err != nil {
-			return err
-		}
-
-		// 3) Mover la pieza a la ubicación destino del nuevo movimiento
-		if movement.ArtefactId != 0 {
-			updateData := map[string]interface{}{}
-			if movement.ToPhysicalLocationId != nil {
-				updateData["physical_location_id"] = *movement.ToPhysicalLocationId
-			} else {
-				updateData["physical_location_id"] = nil
-			}
-
-			if err := tx.Model(&models.ArtefactModel{}).
-				Where("id = ?", movement.ArtefactId).
-				Updates(updateData).Error; err != nil {
-				return err
-			}
-		}
-
-		return nil
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return applyInternalMovement(tx, movement)
 	})
 
 	if err != nil {
@@ -332,109 +192,270 @@ func (s *InternalMovementService) CreateInternalMovement(movement *models.Intern
 	return movement, nil
 }
 
-// DeleteInternalMovement deletes an InternalMovement record by its ID
-func (s *InternalMovementService) DeleteInternalMovement(id int) error {
-	result := s.db.Delete(&models.InternalMovementModel{}, id)
-	return result.Error
+// applyInternalMovement creates a movement inside the given transaction and moves the artefact to its destination.
+// The active movements of the piece are finished, recording which movement closed them so it can be voided later.
+func applyInternalMovement(tx *gorm.DB, movement *models.InternalMovementModel) error {
+	// Los datos de cierre y anulación los registra solo el servicio
+	movement.ClosedByMovementId = nil
+	movement.VoidedAt = nil
+	movement.VoidedById = nil
+	movement.VoidReason = nil
+
+	// 0) Verificar que la pieza esté disponible antes de crear el movimiento (bloqueada hasta el commit)
+	artefact, err := lockArtefact(tx, movement.ArtefactId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrArtefactNotFound
+		}
+		return err
+	}
+	if !artefact.Available {
+		return ErrArtefactNotAvailableForMovement
+	}
+
+	// 1) Buscar movimientos activos previos de la misma pieza
+	var activeMovements []models.InternalMovementModel
+	if err := tx.Where("artefact_id = ? AND return_date IS NULL AND return_time IS NULL AND voided_at IS NULL", movement.ArtefactId).
+		Order("movement_date DESC, movement_time DESC").
+		Find(&activeMovements).Error; err != nil {
+		return err
+	}
+
+	// Si no se especificó el origen, la pieza sale del destino del movimiento activo más reciente
+	// o, si no tiene movimientos activos, de su ubicación actual
+	if movement.FromPhysicalLocationId == nil {
+		if len(activeMovements) > 0 {
+			movement.FromPhysicalLocationId = activeMovements[0].ToPhysicalLocationId
+		} else if artefact.PhysicalLocationID != nil {
+			fromLocationId := *artefact.PhysicalLocationID
+			movement.FromPhysicalLocationId = &fromLocationId
+		}
+	}
+
+	// 2) Crear el nuevo movimiento
+	if err := tx.Create(movement).Error; err != nil {
+		return err
+	}
+
+	// 3) Finalizar los movimientos activos previos
+	if len(activeMovements) > 0 {
+		ids := make([]int, len(activeMovements))
+		for i, activeMovement := range activeMovements {
+			ids[i] = activeMovement.Id
+		}
+		now := time.Now()
+		if err := tx.Model(&models.InternalMovementModel{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"return_date":           now,
+				"return_time":           now,
+				"closed_by_movement_id": movement.Id,
+			}).Error; err != nil {
+			return err
+		}
+	}
+
+	// 4) Mover la pieza a la ubicación destino del nuevo movimiento (sin ubicación si no tiene destino)
+	return tx.Model(&models.ArtefactModel{}).
+		Where("id = ?", movement.ArtefactId).
+		Update("physical_location_id", movement.ToPhysicalLocationId).Error
+}
+
+// ReturnInternalMovement closes an active movement because the piece came back:
+// stamps the return date and time and puts the artefact back in the origin location
+func (s *InternalMovementService) ReturnInternalMovement(id int, dto *dtos.ReturnInternalMovementDTO) (*models.InternalMovementModel, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var movement models.InternalMovementModel
+		if err := tx.First(&movement, id).Error; err != nil {
+			return err
+		}
+		return returnInternalMovement(tx, &movement, dto)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetInternalMovementByID(id)
+}
+
+// returnInternalMovement records the return of a movement inside the given transaction
+func returnInternalMovement(tx *gorm.DB, movement *models.InternalMovementModel, dto *dtos.ReturnInternalMovementDTO) error {
+	// Bloquear la pieza para no competir con un movimiento nuevo de la misma pieza, y releer el movimiento
+	if _, err := lockArtefact(tx, movement.ArtefactId); err != nil {
+		return err
+	}
+	if err := tx.First(movement, movement.Id).Error; err != nil {
+		return err
+	}
+	if movement.VoidedAt != nil {
+		return ErrMovementVoided
+	}
+	if movement.ReturnDate != nil || movement.ReturnTime != nil {
+		return ErrMovementAlreadyClosed
+	}
+	if dateOnly(dto.ReturnDate).Before(dateOnly(movement.MovementDate)) {
+		return ErrInvalidMovementReturnDate
+	}
+
+	updates := map[string]interface{}{
+		"return_date": dto.ReturnDate,
+		"return_time": dto.ReturnTime,
+	}
+	if dto.Observations != nil {
+		updates["observations"] = *dto.Observations
+	}
+	if err := tx.Model(movement).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	// La pieza vuelve a donde estaba antes del movimiento (sin ubicación si no tenía)
+	return tx.Model(&models.ArtefactModel{}).
+		Where("id = ?", movement.ArtefactId).
+		Update("physical_location_id", movement.FromPhysicalLocationId).Error
+}
+
+// DeleteInternalMovement voids an InternalMovement record by its ID.
+// The row is kept for audit; see VoidInternalMovement.
+func (s *InternalMovementService) DeleteInternalMovement(id int, userId *int) error {
+	_, err := s.VoidInternalMovement(id, &dtos.VoidInternalMovementDTO{}, userId)
+	return err
+}
+
+// VoidInternalMovement cancels a movement registered by mistake, keeping the record for audit.
+// The piece goes back to the origin location and the movements it had finished are reopened;
+// it's refused when the piece was moved again afterwards.
+func (s *InternalMovementService) VoidInternalMovement(id int, dto *dtos.VoidInternalMovementDTO, userId *int) (*models.InternalMovementModel, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var movement models.InternalMovementModel
+		if err := tx.First(&movement, id).Error; err != nil {
+			return err
+		}
+		return voidInternalMovement(tx, &movement, userId, dto.Reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetInternalMovementByID(id)
+}
+
+// voidInternalMovement undoes a movement inside the given transaction, keeping the record for audit:
+// the artefact goes back to the origin location and the movements it had finished are reopened
+func voidInternalMovement(tx *gorm.DB, movement *models.InternalMovementModel, userId *int, reason *string) error {
+	if _, err := lockArtefact(tx, movement.ArtefactId); err != nil {
+		return err
+	}
+	if err := tx.First(movement, movement.Id).Error; err != nil {
+		return err
+	}
+	if movement.VoidedAt != nil {
+		return ErrMovementVoided
+	}
+
+	// Un movimiento posterior de la pieza partió de donde este la dejó
+	var later int64
+	if err := tx.Model(&models.InternalMovementModel{}).
+		Where("artefact_id = ? AND id > ? AND voided_at IS NULL", movement.ArtefactId, movement.Id).
+		Count(&later).Error; err != nil {
+		return err
+	}
+	if later > 0 {
+		return ErrMovementHasLaterMovements
+	}
+
+	if err := tx.Model(&models.InternalMovementModel{}).
+		Where("closed_by_movement_id = ?", movement.Id).
+		Updates(map[string]interface{}{
+			"return_date":           nil,
+			"return_time":           nil,
+			"closed_by_movement_id": nil,
+		}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.ArtefactModel{}).
+		Where("id = ?", movement.ArtefactId).
+		Update("physical_location_id", movement.FromPhysicalLocationId).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(movement).Updates(map[string]interface{}{
+		"voided_at":    time.Now(),
+		"voided_by_id": userId,
+		"void_reason":  reason,
+	}).Error
 }
 
 // UpdateInternalMovement updates an existing InternalMovement record
-// Si se está finalizando el movimiento (returnDate/returnTime se establecen), devuelve la pieza a la ubicación origen
+// Si se está finalizando el movimiento (returnDate/returnTime se establecen), registra la devolución
+// igual que ReturnInternalMovement; el retorno de un movimiento ya finalizado no se edita.
+// La pieza y el origen no se editan, y el destino solo mientras sea el movimiento actual de la pieza.
 func (s *InternalMovementService) UpdateInternalMovement(id int, updatedMovement *models.InternalMovementModel) (*models.InternalMovementModel, error) {
 	var movement models.InternalMovementModel
 
 	err := s.db.Transaction(func(tx *gorm.DB) error {
-		// 1) Obtener el movimiento actual
+		// 1) Obtener el movimiento actual y bloquear la pieza para no competir con otro movimiento
 		if err := tx.First(&movement, id).Error; err != nil {
 			return err
 		}
+		if _, err := lockArtefact(tx, movement.ArtefactId); err != nil {
+			return err
+		}
+		if err := tx.First(&movement, id).Error; err != nil {
+			return err
+		}
+		if movement.VoidedAt != nil {
+			return ErrMovementVoided
+		}
 
 		// 2) Verificar si se está finalizando el movimiento
 		isFinishing := (movement.ReturnDate == nil && movement.ReturnTime == nil) &&
 			(updatedMovement.ReturnDate != nil && updatedMovement.ReturnTime != nil)
 
-		// 3) Asegurar que el ID se mantenga
+		// 3) El destino solo se corrige en el movimiento actual de la pieza: los posteriores, la anulación
+		// y el historial de ubicaciones parten de él
+		changesDestination := updatedMovement.ToPhysicalLocationId != nil &&
+			(movement.ToPhysicalLocationId == nil || *movement.ToPhysicalLocationId != *updatedMovement.ToPhysicalLocationId)
+		if changesDestination {
+			if movement.ReturnDate != nil || movement.ReturnTime != nil {
+				return ErrMovementDestinationLocked
+			}
+			var later int64
+			if err := tx.Model(&models.InternalMovementModel{}).
+				Where("artefact_id = ? AND id > ? AND voided_at IS NULL", movement.ArtefactId, movement.Id).
+				Count(&later).Error; err != nil {
+				return err
+			}
+			if later > 0 {
+				return ErrMovementDestinationLocked
+			}
+		}
+
+		// 4) Asegurar que el ID se mantenga
 		updatedMovement.Id = id
 
-		// 4) Actualizar campos del movimiento
-		if err := tx.Model(&movement).Updates(updatedMovement).Error; err != nil {
+		// 5) Actualizar campos del movimiento (la pieza, el origen, el grupo, el cierre, la anulación y el retorno no se editan)
+		if err := tx.Model(&movement).
+			Omit("artefact_id", "from_physical_location_id", "group_movement_id", "closed_by_movement_id",
+				"voided_at", "voided_by_id", "void_reason", "return_date", "return_time").
+			Updates(updatedMovement).Error; err != nil {
 			return err
 		}
 
-		// 5) Si se está finalizando, crear movimiento de devolución y devolver la pieza a la ubicación origen original
+		// 6) Si se está finalizando, la pieza vuelve a la ubicación origen del movimiento
 		if isFinishing {
-			// Buscar el primer movimiento de la cadena (el más antiguo) para obtener la ubicación origen original
-			var firstMovement models.InternalMovementModel
-			if err := tx.Where("artefact_id = ?", movement.ArtefactId).
-				Order("movement_date ASC, movement_time ASC").
-				First(&firstMovement).Error; err != nil {
-				// Si no se encuentra el primer movimiento, usar la ubicación origen del movimiento actual
-				if movement.FromPhysicalLocationId != nil {
-					if err := tx.Model(&models.ArtefactModel{}).
-						Where("id = ?", movement.ArtefactId).
-						Update("physical_location_id", *movement.FromPhysicalLocationId).Error; err != nil {
-						return err
-					}
-				}
-			} else {
-				// Obtener la ubicación origen original del primer movimiento
-				originalLocationId := firstMovement.FromPhysicalLocationId
-
-				// Crear un nuevo movimiento de devolución desde la ubicación actual hacia la ubicación origen original
-				if originalLocationId != nil && movement.ToPhysicalLocationId != nil {
-					// Solo crear el movimiento de devolución si la ubicación actual es diferente a la original
-					if *movement.ToPhysicalLocationId != *originalLocationId {
-						returnMovement := &models.InternalMovementModel{
-							MovementDate:           *updatedMovement.ReturnDate,
-							MovementTime:           *updatedMovement.ReturnTime,
-							ReturnDate:             updatedMovement.ReturnDate, // Ya finalizado
-							ReturnTime:             updatedMovement.ReturnTime, // Ya finalizado
-							ArtefactId:             movement.ArtefactId,
-							FromPhysicalLocationId: movement.ToPhysicalLocationId, // Desde donde está ahora
-							ToPhysicalLocationId:   originalLocationId,            // Hacia la ubicación original
-							Reason:                 stringPtr("Devolución a ubicación original"),
-							Observations:           stringPtr("Movimiento automático de devolución"),
-							RequesterId:            movement.RequesterId, // Mantener el mismo requester si existe
-						}
-
-						if err := tx.Create(returnMovement).Error; err != nil {
-							return err
-						}
-					}
-				}
-
-				// Actualizar la ubicación de la pieza a la ubicación origen original
-				if originalLocationId != nil {
-					if err := tx.Model(&models.ArtefactModel{}).
-						Where("id = ?", movement.ArtefactId).
-						Update("physical_location_id", *originalLocationId).Error; err != nil {
-						return err
-					}
-				} else {
-					// Si no hay ubicación origen original, dejar la pieza sin ubicación
-					if err := tx.Model(&models.ArtefactModel{}).
-						Where("id = ?", movement.ArtefactId).
-						Update("physical_location_id", nil).Error; err != nil {
-						return err
-					}
-				}
-			}
-		} else if !isFinishing {
-			// Si no se está finalizando pero cambió la ubicación destino, actualizar la pieza
-			if updatedMovement.ToPhysicalLocationId != nil &&
-				(movement.ToPhysicalLocationId == nil || *movement.ToPhysicalLocationId != *updatedMovement.ToPhysicalLocationId) {
-				updateData := map[string]interface{}{}
-				if updatedMovement.ToPhysicalLocationId != nil {
-					updateData["physical_location_id"] = *updatedMovement.ToPhysicalLocationId
-				} else {
-					updateData["physical_location_id"] = nil
-				}
-
-				if err := tx.Model(&models.ArtefactModel{}).
-					Where("id = ?", movement.ArtefactId).
-					Updates(updateData).Error; err != nil {
-					return err
-				}
+			return returnInternalMovement(tx, &movement, &dtos.ReturnInternalMovementDTO{
+				ReturnDate: *updatedMovement.ReturnDate,
+				ReturnTime: *updatedMovement.ReturnTime,
+			})
+		}
+
+		// Si no se está finalizando pero cambió la ubicación destino, la pieza pasa a estar allí
+		if changesDestination {
+			if err := tx.Model(&models.ArtefactModel{}).
+				Where("id = ?", movement.ArtefactId).
+				Update("physical_location_id", *updatedMovement.ToPhysicalLocationId).Error; err != nil {
+				return err
 			}
 		}
 
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrEmptyLabelSelection is returned when asking for a label sheet without pieces, locations or shelves
+	ErrEmptyLabelSelection = errors.New("no se seleccionó nada para imprimir")
+	// ErrUnknownLabel is returned when a scanned code doesn't match any piece or location
+	ErrUnknownLabel = errors.New("el código no corresponde a ninguna pieza ni ubicación")
+)
+
+// QR payloads: "ARQAP:A:<artefact id>:<inventory code>" and "ARQAP:L:<shelf code>:<level>:<column>".
+// Locations are encoded by position, so a shelf label stays valid even if its rows are recreated.
+const (
+	labelPrefix       = "ARQAP"
+	labelKindArtefact = "A"
+	labelKindLocation = "L"
+)
+
+// QR image sizes in pixels
+const (
+	DefaultQRSize = 256
+	MinQRSize     = 64
+	MaxQRSize     = 1024
+)
+
+// Label sheet layout (A4, 3 x 8 labels of 70 x 37 mm)
+const (
+	labelColumns  = 3
+	labelRows     = 8
+	labelWidth    = 70.0
+	labelHeight   = 37.0
+	labelPadding  = 3.0
+	labelSheetTop = 0.5
+	labelNameMax  = 60
+)
+
+type LabelService struct {
+	db *gorm.DB
+}
+
+// NewLabelService creates a new instance of LabelService
+func NewLabelService(db *gorm.DB) *LabelService {
+	return &LabelService{db: db}
+}
+
+// artefactLabelPayload is the content of the QR code of a piece (needs its classifiers preloaded)
+func artefactLabelPayload(artefact *models.ArtefactModel) string {
+	return fmt.Sprintf("%s:%s:%d:%s", labelPrefix, labelKindArtefact, artefact.ID, inventoryCode(artefact))
+}
+
+// locationLabelPayload is the content of the QR code of a shelf position (needs its shelf preloaded)
+func locationLabelPayload(location *models.PhysicalLocationModel) string {
+	return fmt.Sprintf("%s:%s:%d:%d:%s", labelPrefix, labelKindLocation, location.Shelf.Code, location.Level, location.Column)
+}
+
+// parseLabelPayload splits a QR payload into its kind and fields; ok is false when the code is not a payload
+func parseLabelPayload(code string) (kind string, fields []string, ok bool) {
+	parts := strings.SplitN(strings.TrimSpace(code), ":", 3)
+	if len(parts) < 3 || !strings.EqualFold(parts[0], labelPrefix) {
+		return "", nil, false
+	}
+	return strings.ToUpper(parts[1]), strings.Split(parts[2], ":"), true
+}
+
+// artefactIdFromPayload returns the artefact ID of a piece label, or ok false when the code is not one
+func artefactIdFromPayload(code string) (id int, ok bool) {
+	kind, fields, ok := parseLabelPayload(code)
+	if !ok || kind != labelKindArtefact {
+		return 0, false
+	}
+	id, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// resolveLocationCode finds the location a shelf position label refers to
+func resolveLocationCode(db *gorm.DB, code string) (*models.PhysicalLocationModel, error) {
+	kind, fields, ok := parseLabelPayload(code)
+	if !ok || kind != labelKindLocation || len(fields) < 3 {
+		return nil, ErrPhysicalLocationNotFound
+	}
+	shelfCode, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, ErrPhysicalLocationNotFound
+	}
+	level, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, ErrPhysicalLocationNotFound
+	}
+
+	var location models.PhysicalLocationModel
+	if err := db.Preload("Shelf").
+		Joins("JOIN shelf_models ON shelf_models.id = physical_location_models.shelf_id").
+		Where("shelf_models.code = ? AND physical_location_models.level = ? AND physical_location_models.\"column\" = ?",
+			shelfCode, level, strings.ToUpper(strings.TrimSpace(fields[2]))).
+		Order("physical_location_models.id ASC").
+		First(&location).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPhysicalLocationNotFound
+		}
+		return nil, err
+	}
+	return &location, nil
+}
+
+// clampQRSize keeps a requested QR size within the allowed range
+func clampQRSize(size int) int {
+	if size < MinQRSize {
+		return MinQRSize
+	}
+	if size > MaxQRSize {
+		return MaxQRSize
+	}
+	return size
+}
+
+// preloadLabelArtefact loads what an artefact label prints
+func preloadLabelArtefact(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("InternalClassifier").
+		Preload("InplClassifier").
+		Preload("PhysicalLocation.Shelf")
+}
+
+// GetArtefactQR renders the QR code (PNG) of a piece
+func (s *LabelService) GetArtefactQR(id int, size int) ([]byte, error) {
+	var artefact models.ArtefactModel
+	if err := preloadLabelArtefact(s.db).First(&artefact, id).Error; err != nil {
+		return nil, err
+	}
+	return qrcode.Encode(artefactLabelPayload(&artefact), qrcode.Medium, clampQRSize(size))
+}
+
+// GetLocationQR renders the QR code (PNG) of a shelf position
+func (s *LabelService) GetLocationQR(id int, size int) ([]byte, error) {
+	var location models.PhysicalLocationModel
+	if err := s.db.Preload("Shelf").First(&location, id).Error; err != nil {
+		return nil, err
+	}
+	return qrcode.Encode(locationLabelPayload(&location), qrcode.Medium, clampQRSize(size))
+}
+
+// ResolveLabel identifies a scanned code: a shelf position label, a piece label or a typed inventory code
+func (s *LabelService) ResolveLabel(code string) (*dtos.ResolvedLabelDTO, error) {
+	code = strings.TrimSpace(code)
+	resolved := &dtos.ResolvedLabelDTO{Code: code}
+
+	if kind, _, ok := parseLabelPayload(code); ok && kind == labelKindLocation {
+		location, err := resolveLocationCode(s.db, code)
+		if err != nil {
+			if errors.Is(err, ErrPhysicalLocationNotFound) {
+				return nil, ErrUnknownLabel
+			}
+			return nil, err
+		}
+		resolved.Kind = dtos.LabelKindLocation
+		resolved.PhysicalLocationId = &location.ID
+		resolved.PhysicalLocation = location
+		return resolved, nil
+	}
+
+	artefactId, err := resolveInventoryCode(s.db, code)
+	if err != nil {
+		return nil, err
+	}
+	if artefactId == nil {
+		return nil, ErrUnknownLabel
+	}
+
+	var artefact models.ArtefactModel
+	if err := preloadLabelArtefact(s.db).First(&artefact, *artefactId).Error; err != nil {
+		return nil, err
+	}
+	resolved.Kind = dtos.LabelKindArtefact
+	resolved.ArtefactId = artefactId
+	resolved.Artefact = &artefact
+	return resolved, nil
+}
+
+// sheetLabel is a label of the sheet: the QR code, a bold title and some detail lines
+type sheetLabel struct {
+	payload string
+	title   string
+	lines   []string
+}
+
+// GenerateLabelSheet renders a printable A4 sheet (PDF) with the labels of the selected pieces and shelf
+// positions, in the order requested; shelves add all of their positions
+func (s *LabelService) GenerateLabelSheet(dto *dtos.LabelSheetDTO) ([]byte, error) {
+	if len(dto.ArtefactIds) == 0 && len(dto.PhysicalLocationIds) == 0 && len(dto.ShelfIds) == 0 {
+		return nil, ErrEmptyLabelSelection
+	}
+
+	var labels []sheetLabel
+
+	artefactIds := uniqueInts(dto.ArtefactIds)
+	if len(artefactIds) > 0 {
+		var artefacts []models.ArtefactModel
+		if err := preloadLabelArtefact(s.db).Where("id IN ?", artefactIds).Find(&artefacts).Error; err != nil {
+			return nil, err
+		}
+		byId := make(map[int]*models.ArtefactModel, len(artefacts))
+		for i := range artefacts {
+			byId[artefacts[i].ID] = &artefacts[i]
+		}
+		for _, id := range artefactIds {
+			artefact, ok := byId[id]
+			if !ok {
+				return nil, fmt.Errorf("pieza %d: %w", id, ErrArtefactNotFound)
+			}
+			labels = append(labels, sheetLabel{
+				payload: artefactLabelPayload(artefact),
+				title:   inventoryCode(artefact),
+				lines:   []string{truncateLabel(artefact.Name, labelNameMax), shortLocationLabel(artefact.PhysicalLocation)},
+			})
+		}
+	}
+
+	locationIds := uniqueInts(dto.PhysicalLocationIds)
+	if len(locationIds) > 0 {
+		var locations []models.PhysicalLocationModel
+		if err := s.db.Preload("Shelf").Where("id IN ?", locationIds).Find(&locations).Error; err != nil {
+			return nil, err
+		}
+		byId := make(map[int]*models.PhysicalLocationModel, len(locations))
+		for i := range locations {
+			byId[locations[i].ID] = &locations[i]
+		}
+		for _, id := range locationIds {
+			location, ok := byId[id]
+			if !ok {
+				return nil, fmt.Errorf("ubicación %d: %w", id, ErrPhysicalLocationNotFound)
+			}
+			labels = append(labels, locationSheetLabel(location))
+		}
+	}
+
+	for _, shelfId := range uniqueInts(dto.ShelfIds) {
+		var shelf models.ShelfModel
+		if err := s.db.First(&shelf, shelfId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("estante %d: %w", shelfId, ErrShelfNotFound)
+			}
+			return nil, err
+		}
+		var locations []models.PhysicalLocationModel
+		if err := s.db.Preload("Shelf").
+			Where("shelf_id = ?", shelfId).
+			Order("level ASC, \"column\" ASC").
+			Find(&locations).Error; err != nil {
+			return nil, err
+		}
+		for i := range locations {
+			labels = append(labels, locationSheetLabel(&locations[i]))
+		}
+	}
+
+	if len(labels) == 0 {
+		return nil, ErrEmptyLabelSelection
+	}
+	return renderLabelSheet(labels)
+}
+
+// locationSheetLabel is the label of a shelf position
+func locationSheetLabel(location *models.PhysicalLocationModel) sheetLabel {
+	return sheetLabel{
+		payload: locationLabelPayload(location),
+		title:   fmt.Sprintf("Estantería %d", location.Shelf.Code),
+		lines:   []string{fmt.Sprintf("Nivel %d", location.Level), fmt.Sprintf("Columna %s", location.Column)},
+	}
+}
+
+// shortLocationLabel describes a location in the little room of a label
+func shortLocationLabel(location *models.PhysicalLocationModel) string {
+	if location == nil {
+		return "Sin ubicación"
+	}
+	return fmt.Sprintf("Est. %d - Niv. %d - Col. %s", location.Shelf.Code, location.Level, location.Column)
+}
+
+// truncateLabel shortens a text to max characters, marking the cut with an ellipsis
+func truncateLabel(text string, max int) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) <= max {
+		return string(runes)
+	}
+	return strings.TrimSpace(string(runes[:max-3])) + "..."
+}
+
+// uniqueInts drops repeated values keeping the first occurrence
+func uniqueInts(values []int) []int {
+	seen := make(map[int]bool, len(values))
+	result := make([]int, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// renderLabelSheet lays out the labels on as many A4 pages as needed, with a light border as cutting guide
+func renderLabelSheet(labels []sheetLabel) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle(tr("Etiquetas"), false)
+	pdf.SetCreator(institutionName, false)
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetDrawColor(200, 200, 200)
+
+	pageWidth, _ := pdf.GetPageSize()
+	left := (pageWidth - labelColumns*labelWidth) / 2
+	qrSize := labelHeight - 2*labelPadding
+	textWidth := labelWidth - qrSize - 3*labelPadding
+
+	for i, label := range labels {
+		slot := i % (labelColumns * labelRows)
+		if slot == 0 {
+			pdf.AddPage()
+		}
+		x := left + float64(slot%labelColumns)*labelWidth
+		y := labelSheetTop + float64(slot/labelColumns)*labelHeight
+		pdf.Rect(x, y, labelWidth, labelHeight, "D")
+
+		png, err := qrcode.Encode(label.payload, qrcode.Medium, DefaultQRSize)
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprintf("qr-%d", i)
+		options := gofpdf.ImageOptions{ImageType: "PNG"}
+		pdf.RegisterImageOptionsReader(name, options, bytes.NewReader(png))
+		pdf.ImageOptions(name, x+labelPadding, y+labelPadding, qrSize, qrSize, false, options, 0, "")
+
+		textX := x + qrSize + 2*labelPadding
+		pdf.SetXY(textX, y+labelPadding+1)
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.MultiCell(textWidth, 4, tr(label.title), "", "L", false)
+		pdf.SetFont("Helvetica", "", 7)
+		for _, line := range label.lines {
+			pdf.SetX(textX)
+			pdf.MultiCell(textWidth, 3.2, tr(line), "", "L", false)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
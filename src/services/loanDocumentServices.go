@@ -0,0 +1,448 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/storage"
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+)
+
+// loanDocumentsRoot is where generated loan documents are stored. They carry personal data, so they are
+// kept outside uploads (served statically) and only downloaded through the authenticated loan routes.
+const loanDocumentsRoot = "private/loans"
+
+// institutionName heads every generated document
+const institutionName = "ARQAP - Colección Arqueológica"
+
+// ErrLoanHasNoArtefact is returned when generating documents for a loan without a piece
+var ErrLoanHasNoArtefact = errors.New("el préstamo no tiene una pieza asociada")
+
+type LoanDocumentService struct {
+	db    *gorm.DB
+	store storage.Storage
+}
+
+// NewLoanDocumentService creates a new instance of LoanDocumentService
+func NewLoanDocumentService(db *gorm.DB, store storage.Storage) *LoanDocumentService {
+	return &LoanDocumentService{db: db, store: store}
+}
+
+// Storage returns the file storage where the documents are kept
+func (s *LoanDocumentService) Storage() storage.Storage {
+	return s.store
+}
+
+// GetLoanDocuments lists the documents generated for a loan, newest first
+func (s *LoanDocumentService) GetLoanDocuments(loanId int) ([]models.LoanDocumentModel, error) {
+	var count int64
+	if err := s.db.Model(&models.LoanModel{}).Where("id = ?", loanId).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	documents := []models.LoanDocumentModel{}
+	if err := s.db.Where("loan_id = ?", loanId).Order("created_at DESC, id DESC").Find(&documents).Error; err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// GetLoanDocument retrieves a document of a loan
+func (s *LoanDocumentService) GetLoanDocument(loanId, documentId int) (*models.LoanDocumentModel, error) {
+	var document models.LoanDocumentModel
+	if err := s.db.Where("id = ? AND loan_id = ?", documentId, loanId).First(&document).Error; err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// GenerateLoanAgreement renders the loan agreement PDF, stores it and links it to the loan
+func (s *LoanDocumentService) GenerateLoanAgreement(loanId int, userId *int) (*models.LoanDocumentModel, error) {
+	loan, err := s.loadLoan(loanId)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := newLoanPDF("Contrato de préstamo", loan)
+	s.writeLoanDetails(doc, loan)
+
+	doc.section("Condiciones")
+	doc.paragraph("El solicitante se compromete a conservar la pieza en las condiciones de seguridad, " +
+		"temperatura y humedad indicadas por la institución, a no intervenirla ni reproducirla sin autorización " +
+		"escrita y a devolverla en la fecha pactada en el mismo estado en que la recibe. " +
+		"Cualquier daño o pérdida debe informarse de inmediato.")
+
+	doc.signatures("Entrega (por la institución)", "Recibe (solicitante)")
+
+	return s.storeDocument(loan, models.LoanDocumentAgreement, doc, nil, userId)
+}
+
+// GenerateConditionReport renders the condition report of a piece at dispatch or return,
+// stores it and links it to the loan
+func (s *LoanDocumentService) GenerateConditionReport(loanId int, dto *dtos.ConditionReportDTO, userId *int) (*models.LoanDocumentModel, error) {
+	loan, err := s.loadLoan(loanId)
+	if err != nil {
+		return nil, err
+	}
+
+	kind, title := models.LoanDocumentConditionDispatch, "Informe de estado - Salida"
+	condition := dto.Condition
+	if dto.Stage == "return" {
+		kind, title = models.LoanDocumentConditionReturn, "Informe de estado - Devolución"
+		if condition == nil {
+			condition = loan.ReturnCondition
+		}
+	}
+
+	doc := newLoanPDF(title, loan)
+	s.writeLoanDetails(doc, loan)
+
+	doc.section("Estado de conservación")
+	doc.field("Estado", valueOr(condition, "Sin observaciones"))
+	if dto.Observations != nil {
+		doc.field("Observaciones", *dto.Observations)
+	}
+	doc.field("Examinado por", s.username(userId))
+	doc.field("Fecha del examen", time.Now().Format("02/01/2006 15:04"))
+
+	doc.signatures("Examinador", "Solicitante")
+
+	return s.storeDocument(loan, kind, doc, condition, userId)
+}
+
+// loadLoan loads a loan with everything the documents show
+func (s *LoanDocumentService) loadLoan(loanId int) (*models.LoanModel, error) {
+	var loan models.LoanModel
+	if err := s.db.
+		Preload("Requester").
+		Preload("Institution").
+		Preload("Artefact").
+		Preload("Artefact.InternalClassifier").
+		Preload("Artefact.InplClassifier").
+		Preload("Artefact.Collection").
+		Preload("Artefact.ArchaeologicalSite").
+		Preload("Artefact.PhysicalLocation").
+		Preload("Artefact.PhysicalLocation.Shelf").
+		First(&loan, loanId).Error; err != nil {
+		return nil, err
+	}
+	if loan.Artefact == nil {
+		return nil, ErrLoanHasNoArtefact
+	}
+	return &loan, nil
+}
+
+// writeLoanDetails writes the requester, institution, piece and dates sections shared by every document
+func (s *LoanDocumentService) writeLoanDetails(doc *loanPDF, loan *models.LoanModel) {
+	doc.section("Solicitante")
+	if r := loan.Requester; r != nil {
+		doc.field("Nombre", strings.TrimSpace(valueOr(r.FirstName, "")+" "+valueOr(r.LastName, "")))
+		doc.field("Tipo", string(r.Type))
+		doc.field("DNI", valueOr(r.Dni, "-"))
+		doc.field("Email", valueOr(r.Email, "-"))
+		doc.field("Teléfono", valueOr(r.PhoneNumber, "-"))
+	} else {
+		doc.field("Nombre", "-")
+	}
+
+	if i := loan.Institution; i != nil {
+		doc.section("Institución")
+		doc.field("Nombre", i.Name)
+		doc.field("CUIT", valueOr(i.TaxId, "-"))
+		address := strings.Join(nonEmpty(i.Address, i.City, i.Province), ", ")
+		doc.field("Domicilio", valueOr(&address, "-"))
+		doc.field("Representante legal", strings.TrimSpace(valueOr(i.LegalRepresentativeName, "-")+" "+
+			parenthesized(i.LegalRepresentativePosition)))
+		doc.field("DNI del representante", valueOr(i.LegalRepresentativeDni, "-"))
+	}
+
+	artefact := loan.Artefact
+	doc.section("Pieza")
+	photoTop := doc.pdf.GetY()
+	doc.field("Nombre", artefact.Name)
+	doc.field("Código de inventario", inventoryCode(artefact))
+	doc.field("Material", artefact.Material)
+	if artefact.Collection != nil {
+		doc.field("Colección", artefact.Collection.Name)
+	}
+	if artefact.ArchaeologicalSite != nil {
+		doc.field("Sitio arqueológico", artefact.ArchaeologicalSite.Name)
+	}
+	doc.field("Ubicación de origen", locationLabel(artefact.PhysicalLocation))
+	if artefact.Description != nil {
+		doc.field("Descripción", *artefact.Description)
+	}
+	s.writeArtefactPhoto(doc, artefact.ID, photoTop)
+
+	doc.section("Préstamo")
+	doc.field("Número de préstamo", fmt.Sprintf("%d", loan.Id))
+	doc.field("Fecha de salida", loan.LoanDate.Format("02/01/2006")+" "+loan.LoanTime.Format("15:04"))
+	if loan.DueDate != nil {
+		doc.field("Devolución pactada", loan.DueDate.Format("02/01/2006"))
+	} else {
+		doc.field("Devolución pactada", "Sin fecha")
+	}
+	if loan.ReturnDate != nil {
+		returned := loan.ReturnDate.Format("02/01/2006")
+		if loan.ReturnTime != nil {
+			returned += " " + loan.ReturnTime.Format("15:04")
+		}
+		doc.field("Devuelto", returned)
+	}
+}
+
+// writeArtefactPhoto places the primary picture of the artefact at the right of the piece section
+func (s *LoanDocumentService) writeArtefactPhoto(doc *loanPDF, artefactId int, top float64) {
+	var picture models.PictureModel
+	if err := s.db.Where("artefact_id = ?", artefactId).
+		Order("is_primary DESC, sort_order ASC, id ASC").
+		First(&picture).Error; err != nil {
+		return
+	}
+
+	key, imageType := picture.FilePath, ""
+	switch {
+	case picture.MediumPath != "":
+		key, imageType = picture.MediumPath, "JPG"
+	case picture.ContentType == "image/jpeg":
+		imageType = "JPG"
+	case picture.ContentType == "image/png":
+		imageType = "PNG"
+	default:
+		return
+	}
+
+	r, err := s.store.Open(key)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	name := fmt.Sprintf("picture_%d", picture.ID)
+	info := doc.pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: imageType}, r)
+	if info == nil || doc.pdf.Err() {
+		// Una foto ilegible no impide generar el documento
+		doc.pdf.ClearError()
+		return
+	}
+
+	pageWidth, _ := doc.pdf.GetPageSize()
+	_, _, right, _ := doc.pdf.GetMargins()
+	const photoWidth = 50.0
+	bottom := doc.pdf.GetY()
+	doc.pdf.ImageOptions(name, pageWidth-right-photoWidth, top, photoWidth, 0, false, gofpdf.ImageOptions{}, 0, "")
+	if photoBottom := top + photoWidth*info.Height()/info.Width(); photoBottom > bottom {
+		doc.pdf.SetY(photoBottom + 2)
+	}
+}
+
+// storeDocument writes the PDF to the storage and records it
+func (s *LoanDocumentService) storeDocument(loan *models.LoanModel, kind string, doc *loanPDF, condition *string, userId *int) (*models.LoanDocumentModel, error) {
+	var buf bytes.Buffer
+	if err := doc.pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("no se pudo generar el PDF: %w", err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	filename := fmt.Sprintf("%s_%d_%s.pdf", kind, loan.Id, time.Now().Format("20060102_150405"))
+	key := fmt.Sprintf("%s/%d/%s", loanDocumentsRoot, loan.Id, filename)
+
+	if err := s.store.Put(key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "application/pdf"); err != nil {
+		return nil, fmt.Errorf("no se pudo guardar el PDF: %w", err)
+	}
+
+	document := models.LoanDocumentModel{
+		LoanId:      loan.Id,
+		Kind:        kind,
+		Filename:    filename,
+		FilePath:    key,
+		Size:        int64(buf.Len()),
+		Sha256:      hex.EncodeToString(sum[:]),
+		Condition:   condition,
+		CreatedById: userId,
+	}
+	if err := s.db.Create(&document).Error; err != nil {
+		removeStoredFiles(s.store, key)
+		return nil, err
+	}
+	return &document, nil
+}
+
+// username returns the name of a user for the documents (without loading the password hash)
+func (s *LoanDocumentService) username(userId *int) string {
+	if userId == nil {
+		return "-"
+	}
+	var name string
+	if err := s.db.Model(&models.UserModel{}).Where("id = ?", *userId).Pluck("username", &name).Error; err != nil || name == "" {
+		return "-"
+	}
+	return name
+}
+
+// ======================= PDF =======================
+
+// deleteLoanDocuments removes the document rows of the loans inside the given transaction and returns them,
+// so their files can be deleted once it commits
+func deleteLoanDocuments(tx *gorm.DB, loanIds []int) ([]models.LoanDocumentModel, error) {
+	var documents []models.LoanDocumentModel
+	if len(loanIds) == 0 {
+		return documents, nil
+	}
+	if err := tx.Where("loan_id IN ?", loanIds).Find(&documents).Error; err != nil {
+		return nil, err
+	}
+	if len(documents) > 0 {
+		if err := tx.Delete(&documents).Error; err != nil {
+			return nil, err
+		}
+	}
+	return documents, nil
+}
+
+// removeLoanDocumentFiles deletes the stored files of removed documents (after the commit: a failure leaves
+// an orphaned file rather than a row without its file)
+func removeLoanDocumentFiles(store storage.Storage, documents []models.LoanDocumentModel) {
+	for _, document := range documents {
+		removeStoredFiles(store, document.FilePath)
+	}
+}
+
+// loanPDF wraps gofpdf with the layout shared by loan documents
+type loanPDF struct {
+	pdf *gofpdf.Fpdf
+	tr  func(string) string // UTF-8 -> cp1252 de las fuentes estándar
+}
+
+// newLoanPDF starts an A4 document with the institution header and the document title
+func newLoanPDF(title string, loan *models.LoanModel) *loanPDF {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	doc := &loanPDF{pdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor("")}
+
+	pdf.SetTitle(doc.tr(title), false)
+	pdf.SetCreator(institutionName, false)
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 10, doc.tr(fmt.Sprintf("Préstamo %d - Página %d/{nb}", loan.Id, pdf.PageNo())), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, doc.tr(institutionName), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, doc.tr(title), "B", 1, "L", false, 0, "")
+	pdf.Ln(4)
+	return doc
+}
+
+// section writes a section heading
+func (d *loanPDF) section(title string) {
+	d.pdf.Ln(3)
+	d.pdf.SetFont("Helvetica", "B", 12)
+	d.pdf.CellFormat(0, 8, d.tr(title), "", 1, "L", false, 0, "")
+}
+
+// field writes a label and its value, wrapping long values
+func (d *loanPDF) field(label, value string) {
+	d.pdf.SetFont("Helvetica", "B", 10)
+	d.pdf.CellFormat(50, 6, d.tr(label+":"), "", 0, "L", false, 0, "")
+	d.pdf.SetFont("Helvetica", "", 10)
+	d.pdf.MultiCell(70, 6, d.tr(value), "", "L", false)
+}
+
+// paragraph writes a block of text across the page
+func (d *loanPDF) paragraph(text string) {
+	d.pdf.SetFont("Helvetica", "", 10)
+	d.pdf.MultiCell(0, 5, d.tr(text), "", "J", false)
+}
+
+// signatures writes two signature boxes side by side (signature, name, ID and date)
+func (d *loanPDF) signatures(left, right string) {
+	d.pdf.Ln(10)
+	if _, pageHeight := d.pdf.GetPageSize(); d.pdf.GetY() > pageHeight-70 {
+		d.pdf.AddPage()
+	}
+	d.section("Firmas")
+	d.pdf.Ln(15)
+
+	const width = 80.0
+	d.pdf.SetFont("Helvetica", "", 10)
+	for _, line := range []string{"Firma", "Aclaración", "DNI", "Fecha"} {
+		d.pdf.CellFormat(width, 8, d.tr(line+": ______________________"), "", 0, "L", false, 0, "")
+		d.pdf.CellFormat(10, 8, "", "", 0, "L", false, 0, "")
+		d.pdf.CellFormat(width, 8, d.tr(line+": ______________________"), "", 1, "L", false, 0, "")
+	}
+	d.pdf.SetFont("Helvetica", "B", 10)
+	d.pdf.CellFormat(width, 8, d.tr(left), "", 0, "L", false, 0, "")
+	d.pdf.CellFormat(10, 8, "", "", 0, "L", false, 0, "")
+	d.pdf.CellFormat(width, 8, d.tr(right), "", 1, "L", false, 0, "")
+}
+
+// inventoryCode returns the identification of a piece (internal classifier, then INPL)
+func inventoryCode(artefact *models.ArtefactModel) string {
+	var codes []string
+	if c := artefact.InternalClassifier; c != nil {
+		code := c.Name
+		if c.Number != nil {
+			code = fmt.Sprintf("%s %d", c.Name, *c.Number)
+		}
+		codes = append(codes, code)
+	}
+	if artefact.InplClassifier != nil {
+		codes = append(codes, fmt.Sprintf("INPL %d", artefact.InplClassifier.ID))
+	}
+	if len(codes) == 0 {
+		return fmt.Sprintf("Pieza %d", artefact.ID)
+	}
+	return strings.Join(codes, " / ")
+}
+
+// locationLabel describes a physical location as shelf, level and column
+func locationLabel(location *models.PhysicalLocationModel) string {
+	if location == nil {
+		return "Sin ubicación"
+	}
+	return fmt.Sprintf("Estantería %d, nivel %d, columna %s", location.Shelf.Code, location.Level, location.Column)
+}
+
+// valueOr dereferences an optional string, using fallback when it's nil or empty
+func valueOr(value *string, fallback string) string {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return fallback
+	}
+	return *value
+}
+
+// nonEmpty keeps the optional strings that are set and not blank
+func nonEmpty(values ...*string) []string {
+	var result []string
+	for _, value := range values {
+		if value != nil && strings.TrimSpace(*value) != "" {
+			result = append(result, strings.TrimSpace(*value))
+		}
+	}
+	return result
+}
+
+// parenthesized returns "(value)" for a set value and "" otherwise
+func parenthesized(value *string) string {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return ""
+	}
+	return "(" + strings.TrimSpace(*value) + ")"
+}
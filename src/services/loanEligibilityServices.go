@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrRequesterNotEligible is returned when lending to a requester that breaks an eligibility rule
+var ErrRequesterNotEligible = errors.New("el solicitante no puede recibir nuevos préstamos")
+
+// RequesterNotEligibleError lists the eligibility rules a requester breaks
+type RequesterNotEligibleError struct {
+	RequesterId int
+	Reasons     []string
+}
+
+func (e *RequesterNotEligibleError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrRequesterNotEligible.Error(), strings.Join(e.Reasons, "; "))
+}
+
+func (e *RequesterNotEligibleError) Is(target error) bool {
+	return target == ErrRequesterNotEligible
+}
+
+// loanEligibilityRules are the conditions a requester must meet to receive new loans, read from the environment:
+//   - LOAN_ALLOW_OVERDUE_REQUESTERS: lend even while the requester has an overdue loan (false)
+//   - LOAN_MAX_OPEN_PER_REQUESTER: open loan lines a requester may have at once (0, no limit)
+//   - LOAN_MAX_LATE_RETURNS: late returns after which the requester is blocked (0, no limit)
+//   - LOAN_LATE_RETURNS_WINDOW_MONTHS: only late returns of the last months count (0, the whole history)
+type loanEligibilityRules struct {
+	BlockOverdue      bool
+	MaxOpenLoans      int
+	MaxLateReturns    int
+	LateReturnsMonths int
+}
+
+func currentLoanEligibilityRules() loanEligibilityRules {
+	allowOverdue, _ := strconv.ParseBool(os.Getenv("LOAN_ALLOW_OVERDUE_REQUESTERS"))
+	return loanEligibilityRules{
+		BlockOverdue:      !allowOverdue,
+		MaxOpenLoans:      positiveIntEnv("LOAN_MAX_OPEN_PER_REQUESTER", 0),
+		MaxLateReturns:    positiveIntEnv("LOAN_MAX_LATE_RETURNS", 0),
+		LateReturnsMonths: positiveIntEnv("LOAN_LATE_RETURNS_WINDOW_MONTHS", 0),
+	}
+}
+
+// requesterLoanEligibility evaluates the rules for lending newLines more artefacts to the requester
+func requesterLoanEligibility(db *gorm.DB, requester *models.RequesterModel, newLines int) (*dtos.LoanEligibilityDTO, error) {
+	rules := currentLoanEligibilityRules()
+	eligibility := &dtos.LoanEligibilityDTO{Eligible: true, Reasons: []string{}}
+	deny := func(reason string) {
+		eligibility.Eligible = false
+		eligibility.Reasons = append(eligibility.Reasons, reason)
+	}
+
+	if requester.AnonymizedAt != nil {
+		deny("el solicitante fue anonimizado")
+	}
+
+	if rules.BlockOverdue {
+		var overdue int64
+		if err := db.Model(&models.LoanModel{}).
+			Where("requester_id = ? AND return_date IS NULL AND due_date < ?", requester.Id, today()).
+			Count(&overdue).Error; err != nil {
+			return nil, err
+		}
+		if overdue > 0 {
+			deny(fmt.Sprintf("tiene %d préstamo(s) vencido(s) sin devolver", overdue))
+		}
+	}
+
+	if rules.MaxOpenLoans > 0 {
+		var open int64
+		if err := db.Model(&models.LoanModel{}).
+			Where("requester_id = ? AND return_date IS NULL", requester.Id).
+			Count(&open).Error; err != nil {
+			return nil, err
+		}
+		if int(open)+newLines > rules.MaxOpenLoans {
+			deny(fmt.Sprintf("superaría el máximo de %d pieza(s) prestada(s) a la vez (tiene %d)", rules.MaxOpenLoans, open))
+		}
+	}
+
+	if rules.MaxLateReturns > 0 {
+		query := db.Model(&models.LoanModel{}).
+			Where("requester_id = ? AND due_date IS NOT NULL AND return_date > due_date", requester.Id)
+		if rules.LateReturnsMonths > 0 {
+			query = query.Where("return_date >= ?", today().AddDate(0, -rules.LateReturnsMonths, 0))
+		}
+		var late int64
+		if err := query.Count(&late).Error; err != nil {
+			return nil, err
+		}
+		if int(late) >= rules.MaxLateReturns {
+			deny(fmt.Sprintf("registra %d devolución(es) fuera de término (máximo %d)", late, rules.MaxLateReturns))
+		}
+	}
+
+	return eligibility, nil
+}
+
+// checkLoanEligibility refuses the loan when the requester breaks an eligibility rule.
+// The requester is locked so two loans created at once can't both pass the open-loans limit.
+func checkLoanEligibility(tx *gorm.DB, requesterId *int, newLines int) error {
+	if requesterId == nil || *requesterId == 0 {
+		return nil
+	}
+
+	var requester models.RequesterModel
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&requester, *requesterId).Error; err != nil {
+		// Como al resolver la institución, un solicitante inexistente no bloquea el préstamo
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	eligibility, err := requesterLoanEligibility(tx, &requester, newLines)
+	if err != nil {
+		return err
+	}
+	if !eligibility.Eligible {
+		return &RequesterNotEligibleError{RequesterId: requester.Id, Reasons: eligibility.Reasons}
+	}
+	return nil
+}
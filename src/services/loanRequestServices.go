@@ -0,0 +1,448 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrLoanRequestTransition is returned when the request can't move to the asked status
+	ErrLoanRequestTransition = errors.New("la solicitud no admite ese cambio de estado")
+	// ErrLoanRequestNotEditable is returned when editing a request that is no longer a draft
+	ErrLoanRequestNotEditable = errors.New("solo se pueden modificar solicitudes en borrador")
+	// ErrLoanRequestCommentRequired is returned when rejecting without a reason
+	ErrLoanRequestCommentRequired = errors.New("se debe indicar el motivo del rechazo")
+	// ErrLoanRequestForbidden is returned when a user without review permissions cancels an approved request
+	ErrLoanRequestForbidden = errors.New("solo un usuario autorizado puede cancelar una solicitud aprobada")
+	// ErrInvalidRequestedPeriod is returned when the requested end date is before the start date
+	ErrInvalidRequestedPeriod = errors.New("la fecha de fin solicitada no puede ser anterior a la de inicio")
+)
+
+// loanRequestTransitions lists the statuses each status can move to
+var loanRequestTransitions = map[string][]string{
+	models.LoanRequestStatusDraft:     {models.LoanRequestStatusSubmitted, models.LoanRequestStatusCancelled},
+	models.LoanRequestStatusSubmitted: {models.LoanRequestStatusApproved, models.LoanRequestStatusRejected, models.LoanRequestStatusCancelled},
+	models.LoanRequestStatusApproved:  {models.LoanRequestStatusDispatched, models.LoanRequestStatusCancelled},
+}
+
+type LoanRequestService struct {
+	db          *gorm.DB
+	loanService *LoanService
+}
+
+// NewLoanRequestService creates a new instance of LoanRequestService
+func NewLoanRequestService(db *gorm.DB, loanService *LoanService) *LoanRequestService {
+	return &LoanRequestService{db: db, loanService: loanService}
+}
+
+// GetAllLoanRequests retrieves the loan requests, optionally only those in one status
+func (s *LoanRequestService) GetAllLoanRequests(status string) ([]models.LoanRequestModel, error) {
+	var requests []models.LoanRequestModel
+
+	query := s.db.
+		Preload("Requester").
+		Preload("Institution").
+		Preload("Items.Artefact")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Order("created_at DESC").Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// GetLoanRequestByID retrieves a loan request with its pieces and history
+func (s *LoanRequestService) GetLoanRequestByID(id int) (*models.LoanRequestModel, error) {
+	var request models.LoanRequestModel
+
+	if err := s.db.
+		Preload("Requester").
+		Preload("Institution").
+		Preload("Items.Artefact").
+		Preload("Events", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC, id ASC")
+		}).
+		Preload("LoanHeader").
+		First(&request, id).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// CreateLoanRequest drafts a loan request
+func (s *LoanRequestService) CreateLoanRequest(dto *dtos.CreateLoanRequestDTO, userId *int) (*models.LoanRequestModel, error) {
+	request := models.LoanRequestModel{
+		Status:             models.LoanRequestStatusDraft,
+		RequesterId:        dto.RequesterId,
+		Purpose:            dto.Purpose,
+		RequestedFrom:      dto.RequestedFrom,
+		RequestedUntil:     dto.RequestedUntil,
+		AgreementReference: dto.AgreementReference,
+		Observations:       dto.Observations,
+		CreatedById:        userId,
+	}
+	if err := validateRequestedPeriod(request.RequestedFrom, request.RequestedUntil); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		institutionId, err := resolveLoanInstitution(tx, dto.RequesterId, dto.InstitutionId)
+		if err != nil {
+			return err
+		}
+		request.InstitutionId = institutionId
+
+		if err := tx.Create(&request).Error; err != nil {
+			return err
+		}
+		if err := setLoanRequestItems(tx, request.Id, dto.ArtefactIds); err != nil {
+			return err
+		}
+		return recordLoanRequestEvent(tx, request.Id, "", models.LoanRequestStatusDraft, nil, userId)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetLoanRequestByID(request.Id)
+}
+
+// UpdateLoanRequest edits a draft loan request
+func (s *LoanRequestService) UpdateLoanRequest(id int, dto *dtos.UpdateLoanRequestDTO) (*models.LoanRequestModel, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var request models.LoanRequestModel
+		if err := tx.First(&request, id).Error; err != nil {
+			return err
+		}
+		if request.Status != models.LoanRequestStatusDraft {
+			return ErrLoanRequestNotEditable
+		}
+
+		updates := map[string]interface{}{}
+		if dto.RequesterId != nil {
+			updates["requester_id"] = *dto.RequesterId
+		}
+		if dto.InstitutionId != nil {
+			if err := checkInstitutionExists(tx, *dto.InstitutionId); err != nil {
+				return err
+			}
+			updates["institution_id"] = *dto.InstitutionId
+		}
+		if dto.Purpose != nil {
+			updates["purpose"] = *dto.Purpose
+		}
+		if dto.RequestedFrom != nil {
+			request.RequestedFrom = *dto.RequestedFrom
+			updates["requested_from"] = *dto.RequestedFrom
+		}
+		if dto.RequestedUntil != nil {
+			request.RequestedUntil = dto.RequestedUntil
+			updates["requested_until"] = *dto.RequestedUntil
+		}
+		if dto.AgreementReference != nil {
+			updates["agreement_reference"] = *dto.AgreementReference
+		}
+		if dto.Observations != nil {
+			updates["observations"] = *dto.Observations
+		}
+
+		if err := validateRequestedPeriod(request.RequestedFrom, request.RequestedUntil); err != nil {
+			return err
+		}
+
+		if len(updates) > 0 {
+			if err := tx.Model(&request).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		if dto.ArtefactIds != nil {
+			if err := tx.Where("loan_request_id = ?", id).Delete(&models.LoanRequestItemModel{}).Error; err != nil {
+				return err
+			}
+			return setLoanRequestItems(tx, id, dto.ArtefactIds)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetLoanRequestByID(id)
+}
+
+// SubmitLoanRequest sends a draft request for review
+func (s *LoanRequestService) SubmitLoanRequest(id int, comment *string, userId *int) (*models.LoanRequestModel, error) {
+	return s.transition(id, models.LoanRequestStatusSubmitted, comment, userId, nil)
+}
+
+// ApproveLoanRequest approves a submitted request, reserving its pieces for the requested period
+func (s *LoanRequestService) ApproveLoanRequest(id int, comment *string, userId *int) (*models.LoanRequestModel, error) {
+	return s.transition(id, models.LoanRequestStatusApproved, comment, userId, func(tx *gorm.DB, request *models.LoanRequestModel) error {
+		if err := reserveLoanRequestItems(tx, request, userId); err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(request).Updates(map[string]interface{}{
+			"reviewed_by_id": userId,
+			"reviewed_at":    now,
+		}).Error
+	})
+}
+
+// RejectLoanRequest rejects a submitted request. The reason is mandatory.
+func (s *LoanRequestService) RejectLoanRequest(id int, comment *string, userId *int) (*models.LoanRequestModel, error) {
+	if comment == nil || strings.TrimSpace(*comment) == "" {
+		return nil, ErrLoanRequestCommentRequired
+	}
+
+	return s.transition(id, models.LoanRequestStatusRejected, comment, userId, func(tx *gorm.DB, request *models.LoanRequestModel) error {
+		now := time.Now()
+		return tx.Model(request).Updates(map[string]interface{}{
+			"reviewed_by_id": userId,
+			"reviewed_at":    now,
+		}).Error
+	})
+}
+
+// CancelLoanRequest cancels a request that wasn't dispatched yet.
+// Approved requests hold reservations, so only reviewers can cancel them.
+func (s *LoanRequestService) CancelLoanRequest(id int, comment *string, userId *int, canReview bool) (*models.LoanRequestModel, error) {
+	return s.transition(id, models.LoanRequestStatusCancelled, comment, userId, func(tx *gorm.DB, request *models.LoanRequestModel) error {
+		if request.Status == models.LoanRequestStatusApproved && !canReview {
+			return ErrLoanRequestForbidden
+		}
+		return closeLoanRequestReservations(tx, request.Id, models.ReservationStatusCancelled)
+	})
+}
+
+// DispatchLoanRequest creates the loan of an approved request, marking its pieces as out
+func (s *LoanRequestService) DispatchLoanRequest(id int, dto *dtos.DispatchLoanRequestDTO, userId *int) (*models.LoanRequestModel, error) {
+	var artefactIds []int
+
+	request, err := s.transition(id, models.LoanRequestStatusDispatched, dto.Comment, userId, func(tx *gorm.DB, request *models.LoanRequestModel) error {
+		if err := tx.Model(&models.LoanRequestItemModel{}).
+			Where("loan_request_id = ?", request.Id).
+			Order("id ASC").
+			Pluck("artefact_id", &artefactIds).Error; err != nil {
+			return err
+		}
+
+		// Las reservas se cumplen con el préstamo, así no lo bloquean
+		if err := closeLoanRequestReservations(tx, request.Id, models.ReservationStatusFulfilled); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		loanDto := dtos.CreateLoanHeaderDTO{
+			RequesterId:        request.RequesterId,
+			InstitutionId:      request.InstitutionId,
+			Purpose:            request.Purpose,
+			LoanDate:           now,
+			LoanTime:           now,
+			DueDate:            request.RequestedUntil,
+			AgreementReference: request.AgreementReference,
+			Observations:       request.Observations,
+			ArtefactIds:        artefactIds,
+		}
+		if dto.LoanDate != nil {
+			loanDto.LoanDate = *dto.LoanDate
+		}
+		if dto.LoanTime != nil {
+			loanDto.LoanTime = *dto.LoanTime
+		}
+		if dto.DueDate != nil {
+			loanDto.DueDate = dto.DueDate
+		}
+
+		header, err := createLoanHeader(tx, &loanDto)
+		if err != nil {
+			return err
+		}
+		return tx.Model(request).Update("loan_header_id", header.Id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.loanService.invalidateArtefactsCache(artefactIds)
+	return request, nil
+}
+
+// AddLoanRequestComment records a comment on a request without changing its status
+func (s *LoanRequestService) AddLoanRequestComment(id int, comment string, userId *int) (*models.LoanRequestModel, error) {
+	var request models.LoanRequestModel
+	if err := s.db.First(&request, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := recordLoanRequestEvent(s.db, id, request.Status, request.Status, &comment, userId); err != nil {
+		return nil, err
+	}
+	return s.GetLoanRequestByID(id)
+}
+
+// DeleteLoanRequest deletes a draft or cancelled request with its pieces and history
+func (s *LoanRequestService) DeleteLoanRequest(id int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var request models.LoanRequestModel
+		if err := tx.First(&request, id).Error; err != nil {
+			return err
+		}
+		if request.Status != models.LoanRequestStatusDraft && request.Status != models.LoanRequestStatusCancelled {
+			return ErrLoanRequestNotEditable
+		}
+
+		if err := tx.Where("loan_request_id = ?", id).Delete(&models.LoanRequestItemModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("loan_request_id = ?", id).Delete(&models.LoanRequestEventModel{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.LoanRequestModel{}, id).Error
+	})
+}
+
+// transition moves a request to another status, running apply (if any) and recording the event
+// in the same transaction. apply sees the request still in its previous status.
+func (s *LoanRequestService) transition(
+	id int,
+	to string,
+	comment *string,
+	userId *int,
+	apply func(tx *gorm.DB, request *models.LoanRequestModel) error,
+) (*models.LoanRequestModel, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Bloquear la solicitud: dos aprobaciones simultáneas reservarían las piezas dos veces
+		var request models.LoanRequestModel
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, id).Error; err != nil {
+			return err
+		}
+
+		from := request.Status
+		if !canTransition(from, to) {
+			return fmt.Errorf("%w (%s -> %s)", ErrLoanRequestTransition, from, to)
+		}
+
+		if apply != nil {
+			if err := apply(tx, &request); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&request).Update("status", to).Error; err != nil {
+			return err
+		}
+		return recordLoanRequestEvent(tx, id, from, to, comment, userId)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetLoanRequestByID(id)
+}
+
+// canTransition reports whether a request in status from can move to status to
+func canTransition(from, to string) bool {
+	for _, allowed := range loanRequestTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// reserveLoanRequestItems books every piece of the request for the requested period.
+// Fails with a ReservationConflictError when one of them is reserved, lent or moved in that period.
+func reserveLoanRequestItems(tx *gorm.DB, request *models.LoanRequestModel, userId *int) error {
+	var artefactIds []int
+	if err := tx.Model(&models.LoanRequestItemModel{}).
+		Where("loan_request_id = ?", request.Id).
+		Order("artefact_id ASC").
+		Pluck("artefact_id", &artefactIds).Error; err != nil {
+		return err
+	}
+
+	for _, artefactId := range artefactIds {
+		reservation := models.ReservationModel{
+			ArtefactId:    artefactId,
+			StartDate:     request.RequestedFrom,
+			EndDate:       request.RequestedUntil,
+			Purpose:       request.Purpose,
+			RequesterId:   request.RequesterId,
+			LoanRequestId: &request.Id,
+			CreatedById:   userId,
+		}
+		if err := createReservation(tx, &reservation, calendarIgnore{LoanRequestId: request.Id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeLoanRequestReservations moves the active reservations of a request to the given status
+func closeLoanRequestReservations(tx *gorm.DB, requestId int, status string) error {
+	return tx.Model(&models.ReservationModel{}).
+		Where("loan_request_id = ? AND status = ?", requestId, models.ReservationStatusActive).
+		Update("status", status).Error
+}
+
+// setLoanRequestItems adds the requested pieces to a request
+func setLoanRequestItems(tx *gorm.DB, requestId int, artefactIds []int) error {
+	seen := make(map[int]bool, len(artefactIds))
+	for _, artefactId := range artefactIds {
+		if seen[artefactId] {
+			return ErrDuplicateLoanLine
+		}
+		seen[artefactId] = true
+
+		var count int64
+		if err := tx.Model(&models.ArtefactModel{}).Where("id = ?", artefactId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("pieza %d: %w", artefactId, ErrArtefactNotFound)
+		}
+
+		if err := tx.Create(&models.LoanRequestItemModel{
+			LoanRequestId: requestId,
+			ArtefactId:    artefactId,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordLoanRequestEvent appends an entry to the history of a request
+func recordLoanRequestEvent(tx *gorm.DB, requestId int, from, to string, comment *string, userId *int) error {
+	return tx.Create(&models.LoanRequestEventModel{
+		LoanRequestId: requestId,
+		FromStatus:    from,
+		ToStatus:      to,
+		Comment:       comment,
+		UserId:        userId,
+	}).Error
+}
+
+// validateRequestedPeriod checks that the requested end date is not before the start date
+func validateRequestedPeriod(from time.Time, until *time.Time) error {
+	if until == nil {
+		return nil
+	}
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	untilDay := time.Date(until.Year(), until.Month(), until.Day(), 0, 0, 0, 0, time.UTC)
+	if untilDay.Before(fromDay) {
+		return ErrInvalidRequestedPeriod
+	}
+	return nil
+}
@@ -2,36 +2,194 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
 
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
 	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/storage"
 	"gorm.io/gorm"
 )
 
+// defaultLoanDueSoonDays is how many days before the due date a loan is reported as due soon
+const defaultLoanDueSoonDays = 7
+
+var (
+	// ErrInvalidDueDate is returned when the due date is before the loan date
+	ErrInvalidDueDate = errors.New("la fecha de devolución pactada no puede ser anterior a la fecha del préstamo")
+	// ErrInvalidLoanStatus is returned when filtering by an unknown status
+	ErrInvalidLoanStatus = errors.New("estado de préstamo inválido (usar active, due_soon, overdue o returned)")
+	// ErrArtefactNotAvailable is returned when lending an artefact that is already out
+	ErrArtefactNotAvailable = errors.New("la pieza arqueológica no está disponible para préstamo (ya está prestada)")
+	// ErrArtefactNotFound is returned when lending an artefact that doesn't exist
+	ErrArtefactNotFound = errors.New("la pieza arqueológica no existe")
+	// ErrDuplicateLoanLine is returned when the same artefact is listed twice in a loan
+	ErrDuplicateLoanLine = errors.New("la pieza arqueológica está repetida en el préstamo")
+	// ErrLoanLineNotInHeader is returned when returning a line that doesn't belong to the loan header
+	ErrLoanLineNotInHeader = errors.New("la línea no pertenece al préstamo o ya fue devuelta")
+	// ErrLoanAlreadyReturned is returned when returning a loan twice
+	ErrLoanAlreadyReturned = errors.New("el préstamo ya fue devuelto")
+	// ErrInvalidReturnDate is returned when the return date is before the loan date
+	ErrInvalidReturnDate = errors.New("la fecha de devolución no puede ser anterior a la fecha del préstamo")
+	// ErrLoanArtefactChange is returned when an update tries to move a loan to another artefact
+	ErrLoanArtefactChange = errors.New("no se puede cambiar la pieza de un préstamo; eliminarlo y registrar uno nuevo")
+	// ErrLoanHeaderFromRequest is returned when deleting a loan header created by dispatching a loan request
+	ErrLoanHeaderFromRequest = errors.New("el préstamo se generó al despachar una solicitud y no se puede eliminar; registrar la devolución")
+)
+
 type LoanService struct {
 	db              *gorm.DB
+	store           storage.Storage  // donde están los documentos generados de los préstamos
 	artefactService *ArtefactService // Referencia opcional para invalidar caché
 }
 
 // NewLoanService creates a new instance of LoanService
 // artefactService puede ser nil si no se necesita invalidar caché
-func NewLoanService(db *gorm.DB, artefactService *ArtefactService) *LoanService {
+func NewLoanService(db *gorm.DB, store storage.Storage, artefactService *ArtefactService) *LoanService {
 	return &LoanService{
 		db:              db,
+		store:           store,
 		artefactService: artefactService,
 	}
 }
 
-// GetAllLoans retrieves all Loan records from the database
-func (s *LoanService) GetAllLoans() ([]models.LoanModel, error) {
+// loanDueSoonDays reads LOAN_DUE_SOON_DAYS (days before the due date a loan counts as due soon)
+func loanDueSoonDays() int {
+	if v := os.Getenv("LOAN_DUE_SOON_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			return days
+		}
+	}
+	return defaultLoanDueSoonDays
+}
+
+// today returns the current date at midnight, local time
+func today() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// loanStatus computes the status of a loan on the given day
+func loanStatus(loan *models.LoanModel, day time.Time, dueSoonDays int) string {
+	if loan.ReturnDate != nil {
+		return models.LoanStatusReturned
+	}
+	if loan.DueDate == nil {
+		return models.LoanStatusActive
+	}
+
+	due := time.Date(loan.DueDate.Year(), loan.DueDate.Month(), loan.DueDate.Day(), 0, 0, 0, 0, day.Location())
+	switch {
+	case due.Before(day):
+		return models.LoanStatusOverdue
+	case !due.After(day.AddDate(0, 0, dueSoonDays)):
+		return models.LoanStatusDueSoon
+	}
+	return models.LoanStatusActive
+}
+
+// setLoanStatuses fills the computed Status of each loan
+func setLoanStatuses(loans []models.LoanModel) {
+	day, dueSoonDays := today(), loanDueSoonDays()
+	for i := range loans {
+		loans[i].Status = loanStatus(&loans[i], day, dueSoonDays)
+	}
+}
+
+// filterLoansByStatus restricts the query to loans in the given computed status
+func filterLoansByStatus(query *gorm.DB, status string) (*gorm.DB, error) {
+	day := today()
+	dueSoonLimit := day.AddDate(0, 0, loanDueSoonDays())
+
+	switch status {
+	case "":
+		return query, nil
+	case models.LoanStatusReturned:
+		return query.Where("return_date IS NOT NULL"), nil
+	case models.LoanStatusOverdue:
+		return query.Where("return_date IS NULL AND due_date < ?", day), nil
+	case models.LoanStatusDueSoon:
+		return query.Where("return_date IS NULL AND due_date >= ? AND due_date <= ?", day, dueSoonLimit), nil
+	case models.LoanStatusActive:
+		return query.Where("return_date IS NULL AND (due_date IS NULL OR due_date > ?)", dueSoonLimit), nil
+	}
+	return nil, ErrInvalidLoanStatus
+}
+
+// validateDueDate checks that the agreed due date is not before the loan date
+func validateDueDate(loan *models.LoanModel) error {
+	if loan.DueDate == nil || loan.LoanDate.IsZero() {
+		return nil
+	}
+	loanDay := time.Date(loan.LoanDate.Year(), loan.LoanDate.Month(), loan.LoanDate.Day(), 0, 0, 0, 0, time.UTC)
+	dueDay := time.Date(loan.DueDate.Year(), loan.DueDate.Month(), loan.DueDate.Day(), 0, 0, 0, 0, time.UTC)
+	if dueDay.Before(loanDay) {
+		return ErrInvalidDueDate
+	}
+	return nil
+}
+
+// GetAllLoans retrieves the Loan records matching the filters (status, requester, institution, loan date range)
+func (s *LoanService) GetAllLoans(filter *dtos.LoanFilterDTO) ([]models.LoanModel, error) {
 	var loans []models.LoanModel
 
-	result := s.db.
+	query := s.db.
 		Preload("Requester").
+		Preload("Institution").
 		Preload("Artefact").
-		Preload("Artefact.InternalClassifier").
-		Find(&loans)
+		Preload("Artefact.InternalClassifier")
 
-	return loans, result.Error
+	if filter != nil {
+		var err error
+		if query, err = filterLoansByStatus(query, filter.Status); err != nil {
+			return nil, err
+		}
+		if filter.RequesterId != nil {
+			query = query.Where("requester_id = ?", *filter.RequesterId)
+		}
+		if filter.InstitutionId != nil {
+			query = query.Where("institution_id = ?", *filter.InstitutionId)
+		}
+		if filter.From != nil {
+			query = query.Where("loan_date >= ?", *filter.From)
+		}
+		if filter.To != nil {
+			query = query.Where("loan_date <= ?", *filter.To)
+		}
+	}
+
+	if err := query.Order("loan_date DESC, id DESC").Find(&loans).Error; err != nil {
+		return nil, err
+	}
+
+	setLoanStatuses(loans)
+	return loans, nil
+}
+
+// GetOverdueLoans lists the loans not returned after their due date, most overdue first
+func (s *LoanService) GetOverdueLoans() ([]dtos.OverdueLoanDTO, error) {
+	loans, err := s.GetAllLoans(&dtos.LoanFilterDTO{Status: models.LoanStatusOverdue})
+	if err != nil {
+		return nil, err
+	}
+
+	day := today()
+	report := make([]dtos.OverdueLoanDTO, 0, len(loans))
+	for _, loan := range loans {
+		due := time.Date(loan.DueDate.Year(), loan.DueDate.Month(), loan.DueDate.Day(), 0, 0, 0, 0, day.Location())
+		report = append(report, dtos.OverdueLoanDTO{
+			Loan:        loan,
+			DaysOverdue: int(day.Sub(due).Hours() / 24),
+		})
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].DaysOverdue > report[j].DaysOverdue
+	})
+	return report, nil
 }
 
 // GetLoanByID retrieves a Loan record by its ID
@@ -40,6 +198,7 @@ func (s *LoanService) GetLoanByID(id int) (*models.LoanModel, error) {
 
 	result := s.db.
 		Preload("Requester").
+		Preload("Institution").
 		Preload("Artefact").
 		Preload("Artefact.InternalClassifier").
 		First(&loan, id)
@@ -47,43 +206,38 @@ func (s *LoanService) GetLoanByID(id int) (*models.LoanModel, error) {
 	if result.Error != nil {
 		return nil, result.Error
 	}
+	loan.Status = loanStatus(&loan, today(), loanDueSoonDays())
 	return &loan, nil
 }
 
 // CreateLoan creates a new Loan record in the database
-// y marca la pieza asociada como no disponible (available = false)
+// y marca la pieza asociada como no disponible (available = false).
+// The requester must meet the eligibility rules (see loanEligibilityRules).
 func (s *LoanService) CreateLoan(loan *models.LoanModel) (*models.LoanModel, error) {
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		// 1) Verificar que la pieza esté disponible antes de crear el préstamo
-		if loan.ArtefactId != nil && *loan.ArtefactId != 0 {
-			var artefact models.ArtefactModel
-			if err := tx.First(&artefact, *loan.ArtefactId).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					return errors.New("la pieza arqueológica no existe")
-				}
-				return err
-			}
+	if err := validateDueDate(loan); err != nil {
+		return nil, err
+	}
 
-			if !artefact.Available {
-				return errors.New("la pieza arqueológica no está disponible para préstamo (ya está prestada)")
-			}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		institutionId, err := resolveLoanInstitution(tx, loan.RequesterId, loan.InstitutionId)
+		if err != nil {
+			return err
 		}
+		loan.InstitutionId = institutionId
 
-		// 2) Crear el préstamo
-		if err := tx.Create(loan).Error; err != nil {
+		if err := checkLoanEligibility(tx, loan.RequesterId, 1); err != nil {
 			return err
 		}
 
-		// 3) Marcar la pieza como NO disponible
+		// 1) Verificar que la pieza esté disponible y marcarla como NO disponible
 		if loan.ArtefactId != nil && *loan.ArtefactId != 0 {
-			if err := tx.Model(&models.ArtefactModel{}).
-				Where("id = ?", *loan.ArtefactId).
-				Update("available", false).Error; err != nil {
+			if err := reserveArtefactForLoan(tx, *loan.ArtefactId, loan.LoanDate, loan.DueDate); err != nil {
 				return err
 			}
 		}
 
-		return nil
+		// 2) Crear el préstamo
+		return tx.Create(loan).Error
 	})
 
 	if err != nil {
@@ -93,6 +247,7 @@ func (s *LoanService) CreateLoan(loan *models.LoanModel) (*models.LoanModel, err
 	// Opcional: devolver el préstamo con las relaciones cargadas
 	if err := s.db.
 		Preload("Requester").
+		Preload("Institution").
 		Preload("Artefact").
 		Preload("Artefact.InternalClassifier").
 		First(loan, loan.Id).Error; err != nil {
@@ -104,37 +259,55 @@ func (s *LoanService) CreateLoan(loan *models.LoanModel) (*models.LoanModel, err
 		s.artefactService.InvalidateArtefactCache(*loan.ArtefactId)
 	}
 
+	loan.Status = loanStatus(loan, today(), loanDueSoonDays())
 	return loan, nil
 }
 
 // DeleteLoan deletes a Loan record by its ID
-// y marca la pieza asociada como disponible nuevamente
+// y recalcula la disponibilidad de la pieza asociada
 func (s *LoanService) DeleteLoan(id int) error {
 	var loan models.LoanModel
-	if err := s.db.First(&loan, id).Error; err != nil {
-		return err
-	}
+	var documents []models.LoanDocumentModel
 
-	// Marcar la pieza como disponible nuevamente
-	if loan.ArtefactId != nil && *loan.ArtefactId != 0 {
-		if err := s.db.Model(&models.ArtefactModel{}).
-			Where("id = ?", *loan.ArtefactId).
-			Update("available", true).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&loan, id).Error; err != nil {
 			return err
 		}
 
-		// Invalidar caché de artefactos
-		if s.artefactService != nil {
-			s.artefactService.InvalidateArtefactCache(*loan.ArtefactId)
+		// Bloquear la pieza antes de borrar para no competir con un préstamo nuevo
+		if loan.ArtefactId != nil && *loan.ArtefactId != 0 {
+			if _, err := lockArtefact(tx, *loan.ArtefactId); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		var err error
+		if documents, err = deleteLoanDocuments(tx, []int{id}); err != nil {
+			return err
 		}
+		if err := tx.Delete(&models.LoanModel{}, id).Error; err != nil {
+			return err
+		}
+
+		if loan.ArtefactId != nil && *loan.ArtefactId != 0 {
+			return syncArtefactAvailability(tx, *loan.ArtefactId)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	removeLoanDocumentFiles(s.store, documents)
 
-	result := s.db.Delete(&models.LoanModel{}, id)
-	return result.Error
+	// Invalidar caché de artefactos
+	if loan.ArtefactId != nil && *loan.ArtefactId != 0 {
+		s.invalidateArtefactsCache([]int{*loan.ArtefactId})
+	}
+	return nil
 }
 
-// UpdateLoan updates an existing Loan record
-// y (asumiendo que se usa para finalizar el préstamo) vuelve a marcar la pieza como disponible
+// UpdateLoan updates an existing Loan record.
+// Never changes the availability of the piece: returns go through ReturnLoan.
 func (s *LoanService) UpdateLoan(id int, updatedLoan *models.LoanModel) (*models.LoanModel, error) {
 	var loan models.LoanModel
 
@@ -147,21 +320,31 @@ func (s *LoanService) UpdateLoan(id int, updatedLoan *models.LoanModel) (*models
 		// 2) Asegurar que el ID se mantenga
 		updatedLoan.Id = id
 
-		// 3) Actualizar campos del préstamo
-		if err := tx.Model(&loan).Updates(updatedLoan).Error; err != nil {
-			return err
+		if updatedLoan.ArtefactId != nil && (loan.ArtefactId == nil || *updatedLoan.ArtefactId != *loan.ArtefactId) {
+			return ErrLoanArtefactChange
 		}
-
-		// 4) Volver a marcar la pieza como disponible
-		if loan.ArtefactId != nil && *loan.ArtefactId != 0 {
-			if err := tx.Model(&models.ArtefactModel{}).
-				Where("id = ?", *loan.ArtefactId).
-				Update("available", true).Error; err != nil {
+		if updatedLoan.InstitutionId != nil {
+			if err := checkInstitutionExists(tx, *updatedLoan.InstitutionId); err != nil {
 				return err
 			}
 		}
 
-		return nil
+		// Validar la fecha pactada contra los valores que quedarán guardados
+		merged := loan
+		if !updatedLoan.LoanDate.IsZero() {
+			merged.LoanDate = updatedLoan.LoanDate
+		}
+		if updatedLoan.DueDate != nil {
+			merged.DueDate = updatedLoan.DueDate
+		}
+		if err := validateDueDate(&merged); err != nil {
+			return err
+		}
+
+		// 3) Actualizar campos del préstamo (la devolución se registra con ReturnLoan)
+		return tx.Model(&loan).
+			Omit("return_date", "return_time", "return_condition", "received_by_id", "loan_header_id", "notifications_opt_out").
+			Updates(updatedLoan).Error
 	})
 
 	if err != nil {
@@ -171,16 +354,411 @@ func (s *LoanService) UpdateLoan(id int, updatedLoan *models.LoanModel) (*models
 	// Devolver el préstamo actualizado con preload
 	if err := s.db.
 		Preload("Requester").
+		Preload("Institution").
 		Preload("Artefact").
 		Preload("Artefact.InternalClassifier").
 		First(&loan, id).Error; err != nil {
 		return nil, err
 	}
 
-	// Invalidar caché de artefactos porque la disponibilidad puede cambiar
-	if s.artefactService != nil && loan.ArtefactId != nil && *loan.ArtefactId != 0 {
-		s.artefactService.InvalidateArtefactCache(*loan.ArtefactId)
+	loan.Status = loanStatus(&loan, today(), loanDueSoonDays())
+	return &loan, nil
+}
+
+// ReturnLoan registers the return of a loan (date, time, condition and receiving user)
+// and marks the piece as available again
+func (s *LoanService) ReturnLoan(id int, dto *dtos.ReturnLoanDTO) (*models.LoanModel, error) {
+	var loan models.LoanModel
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&loan, id).Error; err != nil {
+			return err
+		}
+		if loan.ReturnDate != nil {
+			return ErrLoanAlreadyReturned
+		}
+		return returnLoanLine(tx, &loan, dto)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return &loan, nil
+	if loan.ArtefactId != nil && *loan.ArtefactId != 0 {
+		s.invalidateArtefactsCache([]int{*loan.ArtefactId})
+	}
+	return s.GetLoanByID(id)
+}
+
+// returnLoanLine records the return data on an open loan and releases its artefact
+func returnLoanLine(tx *gorm.DB, loan *models.LoanModel, dto *dtos.ReturnLoanDTO) error {
+	returnDay := time.Date(dto.ReturnDate.Year(), dto.ReturnDate.Month(), dto.ReturnDate.Day(), 0, 0, 0, 0, time.UTC)
+	loanDay := time.Date(loan.LoanDate.Year(), loan.LoanDate.Month(), loan.LoanDate.Day(), 0, 0, 0, 0, time.UTC)
+	if returnDay.Before(loanDay) {
+		return ErrInvalidReturnDate
+	}
+
+	if err := tx.Model(loan).Updates(map[string]interface{}{
+		"return_date":      dto.ReturnDate,
+		"return_time":      dto.ReturnTime,
+		"return_condition": dto.Condition,
+		"received_by_id":   dto.ReceivedById,
+	}).Error; err != nil {
+		return err
+	}
+
+	if loan.ArtefactId != nil && *loan.ArtefactId != 0 {
+		return syncArtefactAvailability(tx, *loan.ArtefactId)
+	}
+	return nil
+}
+
+// invalidateArtefactsCache drops the cached artefacts whose availability changed
+func (s *LoanService) invalidateArtefactsCache(artefactIds []int) {
+	if s.artefactService == nil {
+		return
+	}
+	for _, artefactId := range artefactIds {
+		s.artefactService.InvalidateArtefactCache(artefactId)
+	}
+}
+
+// ======================= PRÉSTAMOS CON VARIAS PIEZAS =======================
+
+// loanHeaderStatus computes the status of a loan header from its lines
+func loanHeaderStatus(lines []models.LoanModel) string {
+	returned := 0
+	for _, line := range lines {
+		if line.ReturnDate != nil {
+			returned++
+		}
+	}
+
+	switch {
+	case len(lines) > 0 && returned == len(lines):
+		return models.LoanHeaderStatusReturned
+	case returned > 0:
+		return models.LoanHeaderStatusPartiallyReturned
+	}
+	return models.LoanHeaderStatusOpen
+}
+
+// preloadLoanHeader loads the requester, the institution and the lines (with their artefacts) of loan headers
+func preloadLoanHeader(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Requester").
+		Preload("Institution").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
+		Preload("Lines.Artefact").
+		Preload("Lines.Artefact.InternalClassifier")
+}
+
+// setLoanHeaderStatuses fills the computed Status of a header and its lines
+func setLoanHeaderStatuses(header *models.LoanHeaderModel) {
+	setLoanStatuses(header.Lines)
+	header.Status = loanHeaderStatus(header.Lines)
+}
+
+// GetAllLoanHeaders retrieves all loan headers with their lines
+func (s *LoanService) GetAllLoanHeaders() ([]models.LoanHeaderModel, error) {
+	var headers []models.LoanHeaderModel
+	if err := preloadLoanHeader(s.db).Order("loan_date DESC, id DESC").Find(&headers).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range headers {
+		setLoanHeaderStatuses(&headers[i])
+	}
+	return headers, nil
+}
+
+// GetLoanHeaderByID retrieves a loan header with its lines
+func (s *LoanService) GetLoanHeaderByID(id int) (*models.LoanHeaderModel, error) {
+	var header models.LoanHeaderModel
+	if err := preloadLoanHeader(s.db).First(&header, id).Error; err != nil {
+		return nil, err
+	}
+
+	setLoanHeaderStatuses(&header)
+	return &header, nil
+}
+
+// addLoanLines creates one loan line per artefact, copying requester, institution and dates from the header
+func addLoanLines(tx *gorm.DB, header *models.LoanHeaderModel, artefactIds []int) error {
+	seen := make(map[int]bool, len(artefactIds))
+	for _, artefactId := range artefactIds {
+		if seen[artefactId] {
+			return ErrDuplicateLoanLine
+		}
+		seen[artefactId] = true
+
+		if err := reserveArtefactForLoan(tx, artefactId, header.LoanDate, header.DueDate); err != nil {
+			return fmt.Errorf("pieza %d: %w", artefactId, err)
+		}
+
+		line := models.LoanModel{
+			LoanDate:      header.LoanDate,
+			LoanTime:      header.LoanTime,
+			DueDate:       header.DueDate,
+			ArtefactId:    &artefactId,
+			RequesterId:   header.RequesterId,
+			InstitutionId: header.InstitutionId,
+			LoanHeaderId:  &header.Id,
+		}
+		if err := tx.Create(&line).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateLoanHeader creates a loan of several artefacts, marking each of them as not available
+func (s *LoanService) CreateLoanHeader(dto *dtos.CreateLoanHeaderDTO) (*models.LoanHeaderModel, error) {
+	var header *models.LoanHeaderModel
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		header, err = createLoanHeader(tx, dto)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateArtefactsCache(dto.ArtefactIds)
+	return s.GetLoanHeaderByID(header.Id)
+}
+
+// createLoanHeader creates a loan header and its lines inside the given transaction
+func createLoanHeader(tx *gorm.DB, dto *dtos.CreateLoanHeaderDTO) (*models.LoanHeaderModel, error) {
+	header := models.LoanHeaderModel{
+		RequesterId:        dto.RequesterId,
+		Purpose:            dto.Purpose,
+		LoanDate:           dto.LoanDate,
+		LoanTime:           dto.LoanTime,
+		DueDate:            dto.DueDate,
+		AgreementReference: dto.AgreementReference,
+		Observations:       dto.Observations,
+	}
+	if err := validateDueDate(&models.LoanModel{LoanDate: header.LoanDate, DueDate: header.DueDate}); err != nil {
+		return nil, err
+	}
+
+	institutionId, err := resolveLoanInstitution(tx, dto.RequesterId, dto.InstitutionId)
+	if err != nil {
+		return nil, err
+	}
+	header.InstitutionId = institutionId
+
+	if err := checkLoanEligibility(tx, dto.RequesterId, len(dto.ArtefactIds)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Create(&header).Error; err != nil {
+		return nil, err
+	}
+	if err := addLoanLines(tx, &header, dto.ArtefactIds); err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
+
+// AddLoanLines adds artefacts to an existing loan header
+func (s *LoanService) AddLoanLines(id int, dto *dtos.AddLoanLinesDTO) (*models.LoanHeaderModel, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var header models.LoanHeaderModel
+		if err := tx.First(&header, id).Error; err != nil {
+			return err
+		}
+
+		var existing []int
+		if err := tx.Model(&models.LoanModel{}).
+			Where("loan_header_id = ? AND artefact_id IN ?", id, dto.ArtefactIds).
+			Pluck("artefact_id", &existing).Error; err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return fmt.Errorf("pieza %d: %w", existing[0], ErrDuplicateLoanLine)
+		}
+		if err := checkLoanEligibility(tx, header.RequesterId, len(dto.ArtefactIds)); err != nil {
+			return err
+		}
+
+		return addLoanLines(tx, &header, dto.ArtefactIds)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateArtefactsCache(dto.ArtefactIds)
+	return s.GetLoanHeaderByID(id)
+}
+
+// UpdateLoanHeader updates the header fields and copies requester, institution and dates to its lines
+func (s *LoanService) UpdateLoanHeader(id int, dto *dtos.UpdateLoanHeaderDTO) (*models.LoanHeaderModel, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var header models.LoanHeaderModel
+		if err := tx.First(&header, id).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{}
+		lineUpdates := map[string]interface{}{}
+		if dto.RequesterId != nil {
+			updates["requester_id"] = *dto.RequesterId
+			lineUpdates["requester_id"] = *dto.RequesterId
+		}
+		if dto.InstitutionId != nil {
+			if err := checkInstitutionExists(tx, *dto.InstitutionId); err != nil {
+				return err
+			}
+			updates["institution_id"] = *dto.InstitutionId
+			lineUpdates["institution_id"] = *dto.InstitutionId
+		}
+		if dto.LoanDate != nil {
+			header.LoanDate = *dto.LoanDate
+			updates["loan_date"] = *dto.LoanDate
+			lineUpdates["loan_date"] = *dto.LoanDate
+		}
+		if dto.LoanTime != nil {
+			updates["loan_time"] = *dto.LoanTime
+			lineUpdates["loan_time"] = *dto.LoanTime
+		}
+		if dto.DueDate != nil {
+			header.DueDate = dto.DueDate
+			updates["due_date"] = *dto.DueDate
+			lineUpdates["due_date"] = *dto.DueDate
+		}
+		if dto.Purpose != nil {
+			updates["purpose"] = *dto.Purpose
+		}
+		if dto.AgreementReference != nil {
+			updates["agreement_reference"] = *dto.AgreementReference
+		}
+		if dto.Observations != nil {
+			updates["observations"] = *dto.Observations
+		}
+
+		if err := validateDueDate(&models.LoanModel{LoanDate: header.LoanDate, DueDate: header.DueDate}); err != nil {
+			return err
+		}
+
+		if len(updates) > 0 {
+			if err := tx.Model(&header).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if len(lineUpdates) > 0 {
+			if err := tx.Model(&models.LoanModel{}).
+				Where("loan_header_id = ?", id).
+				Updates(lineUpdates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetLoanHeaderByID(id)
+}
+
+// ReturnLoanLines registers the return of some (or all) open lines of a loan header,
+// marking their artefacts as available again
+func (s *LoanService) ReturnLoanLines(id int, dto *dtos.ReturnLoanLinesDTO) (*models.LoanHeaderModel, error) {
+	var returned []int
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var header models.LoanHeaderModel
+		if err := tx.First(&header, id).Error; err != nil {
+			return err
+		}
+
+		query := tx.Where("loan_header_id = ? AND return_date IS NULL", id)
+		if len(dto.LoanIds) > 0 {
+			query = query.Where("id IN ?", dto.LoanIds)
+		}
+
+		var lines []models.LoanModel
+		if err := query.Find(&lines).Error; err != nil {
+			return err
+		}
+		if len(dto.LoanIds) > 0 && len(lines) != len(dto.LoanIds) {
+			return ErrLoanLineNotInHeader
+		}
+
+		for i := range lines {
+			if err := returnLoanLine(tx, &lines[i], &dto.ReturnLoanDTO); err != nil {
+				return err
+			}
+			if lines[i].ArtefactId != nil && *lines[i].ArtefactId != 0 {
+				returned = append(returned, *lines[i].ArtefactId)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateArtefactsCache(returned)
+	return s.GetLoanHeaderByID(id)
+}
+
+// DeleteLoanHeader deletes a loan header with all its lines, making the pieces still out available again
+// Headers created by dispatching a loan request are kept, since the request still points to them.
+func (s *LoanService) DeleteLoanHeader(id int) error {
+	var released []int
+	var documents []models.LoanDocumentModel
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var header models.LoanHeaderModel
+		if err := tx.Preload("Lines").First(&header, id).Error; err != nil {
+			return err
+		}
+
+		// La solicitud despachada sigue apuntando al préstamo: es su registro
+		var requests int64
+		if err := tx.Model(&models.LoanRequestModel{}).Where("loan_header_id = ?", id).Count(&requests).Error; err != nil {
+			return err
+		}
+		if requests > 0 {
+			return ErrLoanHeaderFromRequest
+		}
+
+		lineIds := make([]int, len(header.Lines))
+		for i, line := range header.Lines {
+			lineIds[i] = line.Id
+		}
+		var err error
+		if documents, err = deleteLoanDocuments(tx, lineIds); err != nil {
+			return err
+		}
+		if err := tx.Where("loan_header_id = ?", id).Delete(&models.LoanModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.LoanHeaderModel{}, id).Error; err != nil {
+			return err
+		}
+
+		// Recalcular la disponibilidad una vez borradas las líneas
+		for _, line := range header.Lines {
+			if line.ReturnDate == nil && line.ArtefactId != nil && *line.ArtefactId != 0 {
+				if err := syncArtefactAvailability(tx, *line.ArtefactId); err != nil {
+					return err
+				}
+				released = append(released, *line.ArtefactId)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	removeLoanDocumentFiles(s.store, documents)
+
+	s.invalidateArtefactsCache(released)
+	return nil
 }
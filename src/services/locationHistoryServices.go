@@ -0,0 +1,371 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"gorm.io/gorm"
+)
+
+// Kinds of location history events besides the location assignments (import, manual, baseline)
+const (
+	locationEventMovement       = "internal_movement"
+	locationEventMovementReturn = "movement_return"
+	locationEventLoan           = "loan"
+	locationEventLoanReturn     = "loan_return"
+)
+
+// shelfCandidatesQuery lists the artefacts that were ever placed on a location of the shelf
+const shelfCandidatesQuery = `
+	SELECT a.id FROM artefact_models a
+	JOIN physical_location_models p ON p.id = a.physical_location_id
+	WHERE p.shelf_id = ?
+	UNION
+	SELECT l.artefact_id FROM location_assignment_models l
+	JOIN physical_location_models p ON p.id = l.physical_location_id
+	WHERE p.shelf_id = ?
+	UNION
+	SELECT m.artefact_id FROM internal_movement_models m
+	JOIN physical_location_models p ON p.id = m.to_physical_location_id
+	WHERE p.shelf_id = ? AND m.voided_at IS NULL`
+
+type LocationHistoryService struct {
+	db *gorm.DB
+}
+
+// NewLocationHistoryService creates a new instance of LocationHistoryService
+func NewLocationHistoryService(db *gorm.DB) *LocationHistoryService {
+	return &LocationHistoryService{db: db}
+}
+
+// recordLocationAssignment logs a location change made outside internal movements
+func recordLocationAssignment(db *gorm.DB, artefactId int, locationId *int, source string) error {
+	return db.Create(&models.LocationAssignmentModel{
+		ArtefactId:         artefactId,
+		PhysicalLocationId: locationId,
+		Source:             source,
+	}).Error
+}
+
+// trackLocationChange logs an assignment when an update is about to change the artefact's location.
+// It must run before the update; a nil location is left unchanged by Updates, so it's not a change.
+func trackLocationChange(tx *gorm.DB, artefactId int, locationId *int, source string) error {
+	if locationId == nil {
+		return nil
+	}
+
+	var current models.ArtefactModel
+	if err := tx.Select("id", "physical_location_id").First(&current, artefactId).Error; err != nil {
+		return err
+	}
+	if current.PhysicalLocationID != nil && *current.PhysicalLocationID == *locationId {
+		return nil
+	}
+	return recordLocationAssignment(tx, artefactId, locationId, source)
+}
+
+// locationEvent is an entry of the location history before replaying it
+type locationEvent struct {
+	at          time.Time
+	kind        string
+	referenceId int
+	locationId  *int
+	location    *models.PhysicalLocationModel
+	description string
+}
+
+// wallClock keeps the local date and time of a timestamp, to order it together with date and time columns
+func wallClock(t time.Time) time.Time {
+	t = t.In(time.Local)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// dateAndTime joins a date column with its time column
+func dateAndTime(date time.Time, clock *time.Time) time.Time {
+	if clock == nil {
+		return dateOnly(date)
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.UTC)
+}
+
+// loadLocationEvents gathers the location assignments, internal movements (not voided) and loans
+// of the artefacts, ordered in time for each artefact
+func loadLocationEvents(db *gorm.DB, artefactIds []int) (map[int][]locationEvent, error) {
+	events := make(map[int][]locationEvent, len(artefactIds))
+	if len(artefactIds) == 0 {
+		return events, nil
+	}
+
+	var assignments []models.LocationAssignmentModel
+	if err := db.
+		Preload("PhysicalLocation").
+		Preload("PhysicalLocation.Shelf").
+		Where("artefact_id IN ?", artefactIds).
+		Order("created_at ASC, id ASC").
+		Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	for _, assignment := range assignments {
+		events[assignment.ArtefactId] = append(events[assignment.ArtefactId], locationEvent{
+			at:          wallClock(assignment.CreatedAt),
+			kind:        assignment.Source,
+			referenceId: assignment.Id,
+			locationId:  assignment.PhysicalLocationId,
+			location:    assignment.PhysicalLocation,
+		})
+	}
+
+	var movements []models.InternalMovementModel
+	if err := db.
+		Preload("FromPhysicalLocation").
+		Preload("FromPhysicalLocation.Shelf").
+		Preload("ToPhysicalLocation").
+		Preload("ToPhysicalLocation.Shelf").
+		Where("artefact_id IN ? AND voided_at IS NULL", artefactIds).
+		Order("movement_date ASC, movement_time ASC, id ASC").
+		Find(&movements).Error; err != nil {
+		return nil, err
+	}
+	for _, movement := range movements {
+		event := locationEvent{
+			at:          dateAndTime(movement.MovementDate, &movement.MovementTime),
+			kind:        locationEventMovement,
+			referenceId: movement.Id,
+			locationId:  movement.ToPhysicalLocationId,
+			location:    movement.ToPhysicalLocation,
+		}
+		if movement.Reason != nil {
+			event.description = *movement.Reason
+		}
+		events[movement.ArtefactId] = append(events[movement.ArtefactId], event)
+
+		// Los movimientos finalizados por otro movimiento no vuelven al origen
+		if movement.ReturnDate != nil && movement.ClosedByMovementId == nil {
+			events[movement.ArtefactId] = append(events[movement.ArtefactId], locationEvent{
+				at:          dateAndTime(*movement.ReturnDate, movement.ReturnTime),
+				kind:        locationEventMovementReturn,
+				referenceId: movement.Id,
+				locationId:  movement.FromPhysicalLocationId,
+				location:    movement.FromPhysicalLocation,
+			})
+		}
+	}
+
+	var loans []models.LoanModel
+	if err := db.Where("artefact_id IN ?", artefactIds).Order("loan_date ASC, id ASC").Find(&loans).Error; err != nil {
+		return nil, err
+	}
+	for _, loan := range loans {
+		events[*loan.ArtefactId] = append(events[*loan.ArtefactId], locationEvent{
+			at:          dateAndTime(loan.LoanDate, &loan.LoanTime),
+			kind:        locationEventLoan,
+			referenceId: loan.Id,
+		})
+		if loan.ReturnDate != nil {
+			events[*loan.ArtefactId] = append(events[*loan.ArtefactId], locationEvent{
+				at:          dateAndTime(*loan.ReturnDate, loan.ReturnTime),
+				kind:        locationEventLoanReturn,
+				referenceId: loan.Id,
+				description: valueOr(loan.ReturnCondition, ""),
+			})
+		}
+	}
+
+	for artefactId := range events {
+		sort.SliceStable(events[artefactId], func(i, j int) bool {
+			return events[artefactId][i].at.Before(events[artefactId][j].at)
+		})
+	}
+	return events, nil
+}
+
+// replayLocationHistory applies the events in order (those before until, when given) and returns
+// where the piece was after each of them
+func replayLocationHistory(events []locationEvent, until *time.Time) []dtos.LocationHistoryEntryDTO {
+	entries := []dtos.LocationHistoryEntryDTO{}
+	state := dtos.LocationHistoryEntryDTO{Status: dtos.LocationStatusUnknown}
+	onLoan := false
+	movementId := 0 // movimiento que dejó la pieza en su ubicación actual
+
+	for _, event := range events {
+		if until != nil && !event.at.Before(*until) {
+			break
+		}
+
+		switch event.kind {
+		case locationEventLoan:
+			onLoan = true
+		case locationEventLoanReturn:
+			onLoan = false
+		case locationEventMovementReturn:
+			// Solo vuelve al origen si la pieza no se reubicó después del movimiento
+			if movementId != event.referenceId {
+				continue
+			}
+			state.PhysicalLocationId, state.PhysicalLocation = event.locationId, event.location
+			movementId = 0
+		default:
+			state.PhysicalLocationId, state.PhysicalLocation = event.locationId, event.location
+			movementId = 0
+			if event.kind == locationEventMovement {
+				movementId = event.referenceId
+			}
+		}
+
+		state.Status = dtos.LocationStatusInStorage
+		if onLoan {
+			state.Status = dtos.LocationStatusOnLoan
+		}
+		state.At = event.at
+		state.Kind = event.kind
+		state.ReferenceId = event.referenceId
+		state.Description = event.description
+		entries = append(entries, state)
+	}
+	return entries
+}
+
+// lastLocationChange is when the piece was placed where it is after the last entry (loans don't move it)
+func lastLocationChange(entries []dtos.LocationHistoryEntryDTO) time.Time {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Kind != locationEventLoan && entries[i].Kind != locationEventLoanReturn {
+			return entries[i].At
+		}
+	}
+	return time.Time{}
+}
+
+// GetLocationHistory rebuilds the timeline of where a piece has been, from its location
+// assignments (imports, edits), internal movements and loans
+func (s *LocationHistoryService) GetLocationHistory(artefactId int) ([]dtos.LocationHistoryEntryDTO, error) {
+	if err := s.db.Select("id").First(&models.ArtefactModel{}, artefactId).Error; err != nil {
+		return nil, err
+	}
+
+	events, err := loadLocationEvents(s.db, []int{artefactId})
+	if err != nil {
+		return nil, err
+	}
+	return replayLocationHistory(events[artefactId], nil), nil
+}
+
+// GetArtefactLocationAt tells where a piece was at the end of the given day
+func (s *LocationHistoryService) GetArtefactLocationAt(artefactId int, at time.Time) (*dtos.ArtefactLocationDTO, error) {
+	if err := s.db.Select("id").First(&models.ArtefactModel{}, artefactId).Error; err != nil {
+		return nil, err
+	}
+
+	events, err := loadLocationEvents(s.db, []int{artefactId})
+	if err != nil {
+		return nil, err
+	}
+
+	day := dateOnly(at)
+	until := day.AddDate(0, 0, 1)
+	location := &dtos.ArtefactLocationDTO{ArtefactId: artefactId, At: day, Status: dtos.LocationStatusUnknown}
+
+	entries := replayLocationHistory(events[artefactId], &until)
+	if len(entries) == 0 {
+		return location, nil
+	}
+	last := entries[len(entries)-1]
+	location.Status = last.Status
+	location.PhysicalLocationId = last.PhysicalLocationId
+	location.PhysicalLocation = last.PhysicalLocation
+	location.LastEvent = &last
+	if last.Status == dtos.LocationStatusOnLoan {
+		location.LoanId = activeLoanId(entries)
+	}
+	return location, nil
+}
+
+// activeLoanId returns the loan that was open after the last entry
+func activeLoanId(entries []dtos.LocationHistoryEntryDTO) *int {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Kind == locationEventLoan {
+			return &entries[i].ReferenceId
+		}
+	}
+	return nil
+}
+
+// GetShelfContentsAt lists the pieces that were on the shelf at the end of the given day,
+// separating the ones placed there but out on loan at that moment
+func (s *LocationHistoryService) GetShelfContentsAt(shelfId int, at time.Time) (*dtos.ShelfContentsDTO, error) {
+	var shelf models.ShelfModel
+	if err := s.db.First(&shelf, shelfId).Error; err != nil {
+		return nil, err
+	}
+
+	day := dateOnly(at)
+	until := day.AddDate(0, 0, 1)
+	contents := &dtos.ShelfContentsDTO{
+		ShelfId:   shelf.ID,
+		ShelfCode: shelf.Code,
+		At:        day,
+		Artefacts: []dtos.ShelfArtefactDTO{},
+		OnLoan:    []dtos.ShelfArtefactDTO{},
+	}
+
+	var artefactIds []int
+	if err := s.db.Raw(shelfCandidatesQuery, shelfId, shelfId, shelfId).Scan(&artefactIds).Error; err != nil {
+		return nil, err
+	}
+	if len(artefactIds) == 0 {
+		return contents, nil
+	}
+
+	var artefacts []models.ArtefactModel
+	if err := s.db.
+		Preload("InternalClassifier").
+		Preload("InplClassifier").
+		Where("id IN ?", artefactIds).
+		Find(&artefacts).Error; err != nil {
+		return nil, err
+	}
+	events, err := loadLocationEvents(s.db, artefactIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range artefacts {
+		entries := replayLocationHistory(events[artefacts[i].ID], &until)
+		if len(entries) == 0 {
+			continue
+		}
+		last := entries[len(entries)-1]
+		if last.PhysicalLocation == nil || last.PhysicalLocation.ShelfId != shelfId {
+			continue
+		}
+
+		piece := dtos.ShelfArtefactDTO{
+			ArtefactId:       artefacts[i].ID,
+			Name:             artefacts[i].Name,
+			InventoryCode:    inventoryCode(&artefacts[i]),
+			PhysicalLocation: last.PhysicalLocation,
+			Since:            lastLocationChange(entries),
+		}
+		if last.Status == dtos.LocationStatusOnLoan {
+			piece.LoanId = activeLoanId(entries)
+			contents.OnLoan = append(contents.OnLoan, piece)
+		} else {
+			contents.Artefacts = append(contents.Artefacts, piece)
+		}
+	}
+
+	for _, pieces := range [][]dtos.ShelfArtefactDTO{contents.Artefacts, contents.OnLoan} {
+		sort.Slice(pieces, func(i, j int) bool {
+			a, b := pieces[i].PhysicalLocation, pieces[j].PhysicalLocation
+			if a.Level != b.Level {
+				return a.Level < b.Level
+			}
+			if a.Column != b.Column {
+				return a.Column < b.Column
+			}
+			return pieces[i].InventoryCode < pieces[j].InventoryCode
+		})
+	}
+	return contents, nil
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrMovementGroupCancelled is returned when operating on a cancelled movement group
+	ErrMovementGroupCancelled = errors.New("el grupo de movimientos ya fue cancelado")
+	// ErrMovementGroupClosed is returned when returning a group without active movements
+	ErrMovementGroupClosed = errors.New("el grupo no tiene movimientos activos para retornar")
+)
+
+// preloadMovementGroup loads the requester and the movements (with artefacts and locations) of movement groups
+func preloadMovementGroup(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Requester").
+		Preload("Movements", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
+		Preload("Movements.Artefact").
+		Preload("Movements.Artefact.InternalClassifier").
+		Preload("Movements.FromPhysicalLocation").
+		Preload("Movements.FromPhysicalLocation.Shelf").
+		Preload("Movements.ToPhysicalLocation").
+		Preload("Movements.ToPhysicalLocation.Shelf")
+}
+
+// movementGroupStatus computes the status of a group: open while any of its movements is active
+func movementGroupStatus(group *models.MovementGroupModel) string {
+	if group.CancelledAt != nil {
+		return models.MovementGroupStatusCancelled
+	}
+	for _, movement := range group.Movements {
+		if movement.VoidedAt == nil && movement.ReturnDate == nil && movement.ReturnTime == nil {
+			return models.MovementGroupStatusOpen
+		}
+	}
+	return models.MovementGroupStatusReturned
+}
+
+// GetMovementGroupByID retrieves a movement group with its movements
+func (s *InternalMovementService) GetMovementGroupByID(id int) (*models.MovementGroupModel, error) {
+	var group models.MovementGroupModel
+	if err := preloadMovementGroup(s.db).First(&group, id).Error; err != nil {
+		return nil, err
+	}
+
+	group.Status = movementGroupStatus(&group)
+	return &group, nil
+}
+
+// CreateMovementGroup moves several artefacts at once; if any of them can't be moved, none is
+func (s *InternalMovementService) CreateMovementGroup(dto *dtos.CreateMovementGroupDTO, createdById *int) (*models.MovementGroupModel, error) {
+	group := &models.MovementGroupModel{
+		Reason:      dto.Reason,
+		RequesterId: dto.RequesterId,
+		CreatedById: createdById,
+	}
+
+	movements := make([]*models.InternalMovementModel, len(dto.Movements))
+	for i := range dto.Movements {
+		movements[i] = &dto.Movements[i]
+		if movements[i].Reason == nil {
+			movements[i].Reason = dto.Reason
+		}
+		if movements[i].RequesterId == nil {
+			movements[i].RequesterId = dto.RequesterId
+		}
+	}
+
+	if err := s.createMovementGroup(group, movements); err != nil {
+		return nil, err
+	}
+	return s.GetMovementGroupByID(group.Id)
+}
+
+// createMovementGroup saves the group and applies its movements in a single transaction
+func (s *InternalMovementService) createMovementGroup(group *models.MovementGroupModel, movements []*models.InternalMovementModel) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return applyMovementGroup(tx, group, movements)
+	})
+}
+
+// applyMovementGroup saves the group and applies its movements inside the given transaction
+func applyMovementGroup(tx *gorm.DB, group *models.MovementGroupModel, movements []*models.InternalMovementModel) error {
+	if err := tx.Omit("Requester", "Movements").Create(group).Error; err != nil {
+		return err
+	}
+
+	for _, movement := range movements {
+		movement.GroupMovementId = &group.Id
+		if err := applyInternalMovement(tx, movement); err != nil {
+			return fmt.Errorf("pieza %d: %w", movement.ArtefactId, err)
+		}
+	}
+	return nil
+}
+
+// ReturnMovementGroup returns every active movement of the group to its origin location at once
+func (s *InternalMovementService) ReturnMovementGroup(id int, dto *dtos.ReturnInternalMovementDTO) (*models.MovementGroupModel, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var group models.MovementGroupModel
+		if err := tx.First(&group, id).Error; err != nil {
+			return err
+		}
+		if group.CancelledAt != nil {
+			return ErrMovementGroupCancelled
+		}
+
+		var movements []models.InternalMovementModel
+		if err := tx.Where("group_movement_id = ? AND return_date IS NULL AND return_time IS NULL AND voided_at IS NULL", id).
+			Order("id ASC").
+			Find(&movements).Error; err != nil {
+			return err
+		}
+		if len(movements) == 0 {
+			return ErrMovementGroupClosed
+		}
+
+		for i := range movements {
+			if err := returnInternalMovement(tx, &movements[i], dto); err != nil {
+				return fmt.Errorf("pieza %d: %w", movements[i].ArtefactId, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetMovementGroupByID(id)
+}
+
+// CancelMovementGroup voids every movement of the group, putting each artefact back where it was before.
+// It's refused if any of the pieces was moved again afterwards by a movement outside the group.
+func (s *InternalMovementService) CancelMovementGroup(id int, dto *dtos.CancelMovementGroupDTO, userId *int) (*models.MovementGroupModel, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var group models.MovementGroupModel
+		if err := tx.First(&group, id).Error; err != nil {
+			return err
+		}
+		if group.CancelledAt != nil {
+			return ErrMovementGroupCancelled
+		}
+
+		// Del más nuevo al más viejo, por si el grupo movió dos veces la misma pieza
+		var movements []models.InternalMovementModel
+		if err := tx.Where("group_movement_id = ? AND voided_at IS NULL", id).
+			Order("id DESC").
+			Find(&movements).Error; err != nil {
+			return err
+		}
+		for i := range movements {
+			if err := voidInternalMovement(tx, &movements[i], userId, dto.Reason); err != nil {
+				return fmt.Errorf("pieza %d: %w", movements[i].ArtefactId, err)
+			}
+		}
+
+		return tx.Model(&group).Updates(map[string]interface{}{
+			"cancelled_at":    time.Now(),
+			"cancelled_by_id": userId,
+			"cancel_reason":   dto.Reason,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetMovementGroupByID(id)
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/mailer"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrMailerNotConfigured is returned when reminders are requested without an SMTP server
+	ErrMailerNotConfigured = errors.New("el envío de correos no está configurado (SMTP_HOST)")
+	// ErrRemindersRunning is returned when reminders are requested while another run is in progress
+	ErrRemindersRunning = errors.New("ya hay un envío de recordatorios en curso")
+)
+
+var reminderSubjects = map[string]*template.Template{
+	models.NotificationKindDueSoon: template.Must(template.New("due_soon_subject").Parse(
+		`Recordatorio: préstamo con vencimiento el {{.DueDate}}`)),
+	models.NotificationKindOverdue: template.Must(template.New("overdue_subject").Parse(
+		`Préstamo vencido desde el {{.DueDate}}`)),
+}
+
+var reminderBodies = map[string]*template.Template{
+	models.NotificationKindDueSoon: template.Must(template.New("due_soon_body").Parse(
+		`{{if .Staff}}Aviso interno: el préstamo a {{.RequesterName}} vence el {{.DueDate}}.{{else}}Estimado/a {{.RequesterName}}:
+
+Le recordamos que el préstamo de las siguientes piezas vence el {{.DueDate}}.{{end}}
+
+{{range .Artefacts}}  - {{.}}
+{{end}}
+{{if not .Staff}}Por favor, coordine la devolución con el área de colecciones del museo.
+
+{{end}}ARQAP
+`)),
+	models.NotificationKindOverdue: template.Must(template.New("overdue_body").Parse(
+		`{{if .Staff}}Aviso interno: el préstamo a {{.RequesterName}} venció el {{.DueDate}} ({{.DaysOverdue}} días de atraso).{{else}}Estimado/a {{.RequesterName}}:
+
+El préstamo de las siguientes piezas venció el {{.DueDate}} y lleva {{.DaysOverdue}} días de atraso.{{end}}
+
+{{range .Artefacts}}  - {{.}}
+{{end}}
+{{if not .Staff}}Le pedimos que se comunique con el área de colecciones del museo para coordinar la devolución.
+
+{{end}}ARQAP
+`)),
+}
+
+// reminderData is what the subject and body templates receive
+type reminderData struct {
+	Staff         bool
+	RequesterName string
+	DueDate       string
+	DaysOverdue   int
+	Artefacts     []string
+}
+
+// reminderGroup is one email: the open lines of a loan (or loan header) sharing due date and kind
+type reminderGroup struct {
+	kind      string
+	dueDate   time.Time
+	requester *models.RequesterModel
+	loans     []models.LoanModel
+}
+
+type NotificationService struct {
+	db      *gorm.DB
+	sender  mailer.Sender
+	mutex   sync.Mutex
+	running bool
+}
+
+// NewNotificationService creates a new instance of NotificationService. sender may be nil when SMTP isn't configured.
+func NewNotificationService(db *gorm.DB, sender mailer.Sender) *NotificationService {
+	return &NotificationService{db: db, sender: sender}
+}
+
+// reminderDaysBefore is how many days before the due date the first reminder goes out (LOAN_REMINDER_DAYS_BEFORE, default 3)
+func reminderDaysBefore() int {
+	return positiveIntEnv("LOAN_REMINDER_DAYS_BEFORE", 3)
+}
+
+// overdueReminderEveryDays is how often an overdue reminder is repeated (LOAN_OVERDUE_REMINDER_EVERY_DAYS, default 7)
+func overdueReminderEveryDays() int {
+	return positiveIntEnv("LOAN_OVERDUE_REMINDER_EVERY_DAYS", 7)
+}
+
+// reminderStaffEmails are the staff addresses copied on every reminder (LOAN_REMINDER_STAFF_EMAILS, comma separated)
+func reminderStaffEmails() []string {
+	var emails []string
+	for _, email := range strings.Split(os.Getenv("LOAN_REMINDER_STAFF_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+func positiveIntEnv(name string, fallback int) int {
+	if v := os.Getenv(name); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// StartReminderScheduler sends loan reminders every interval in the background.
+// It's disabled when interval <= 0 or there is no SMTP server configured.
+func (s *NotificationService) StartReminderScheduler(interval time.Duration) {
+	if interval <= 0 || s.sender == nil {
+		log.Println("[REMINDERS] Recordatorios de préstamos deshabilitados")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			report, err := s.RunLoanReminders()
+			if err != nil {
+				if !errors.Is(err, ErrRemindersRunning) {
+					log.Printf("[REMINDERS] Error en el envío programado: %v", err)
+				}
+				continue
+			}
+			log.Printf("[REMINDERS] Enviados %d, fallidos %d, sin email %d", report.Sent, report.Failed, report.Skipped)
+		}
+	}()
+}
+
+// RunLoanReminders emails requesters and staff about open loans that are due soon or overdue.
+// Each loan is notified once per due date before it's due and every few days once overdue;
+// failed deliveries are retried in the next run.
+func (s *NotificationService) RunLoanReminders() (*dtos.ReminderRunDTO, error) {
+	if s.sender == nil {
+		return nil, ErrMailerNotConfigured
+	}
+
+	s.mutex.Lock()
+	if s.running {
+		s.mutex.Unlock()
+		return nil, ErrRemindersRunning
+	}
+	s.running = true
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		s.running = false
+		s.mutex.Unlock()
+	}()
+
+	report := &dtos.ReminderRunDTO{StartedAt: time.Now()}
+	day := dateOnly(today())
+
+	var loans []models.LoanModel
+	if err := s.db.
+		Preload("Artefact").
+		Preload("Artefact.InternalClassifier").
+		Preload("Artefact.InplClassifier").
+		Preload("Requester").
+		Where("return_date IS NULL AND due_date IS NOT NULL AND notifications_opt_out = ?", false).
+		Where("due_date <= ?", day.AddDate(0, 0, reminderDaysBefore())).
+		Order("due_date ASC, id ASC").
+		Find(&loans).Error; err != nil {
+		return nil, err
+	}
+
+	staffEmails := reminderStaffEmails()
+	for _, group := range groupReminders(loans, day) {
+		if group.requester == nil || group.requester.Email == nil || strings.TrimSpace(*group.requester.Email) == "" {
+			report.Skipped += len(group.loans)
+		} else {
+			s.sendReminder(report, group, models.NotificationRecipientRequester, strings.TrimSpace(*group.requester.Email), day)
+		}
+		for _, email := range staffEmails {
+			s.sendReminder(report, group, models.NotificationRecipientStaff, email, day)
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// groupReminders puts the lines of the same loan header, due date and kind in a single email
+func groupReminders(loans []models.LoanModel, day time.Time) []*reminderGroup {
+	var groups []*reminderGroup
+	byKey := map[string]*reminderGroup{}
+
+	for _, loan := range loans {
+		dueDate := dateOnly(*loan.DueDate)
+		kind := models.NotificationKindDueSoon
+		if dueDate.Before(day) {
+			kind = models.NotificationKindOverdue
+		}
+
+		key := fmt.Sprintf("loan-%d", loan.Id)
+		if loan.LoanHeaderId != nil {
+			key = fmt.Sprintf("header-%d-%s-%s", *loan.LoanHeaderId, dueDate.Format("2006-01-02"), kind)
+		}
+
+		group, ok := byKey[key]
+		if !ok {
+			group = &reminderGroup{kind: kind, dueDate: dueDate, requester: loan.Requester}
+			byKey[key] = group
+			groups = append(groups, group)
+		}
+		group.loans = append(group.loans, loan)
+	}
+	return groups
+}
+
+// sendReminder emails one recipient about the lines of a group not yet notified and logs the result per line
+func (s *NotificationService) sendReminder(report *dtos.ReminderRunDTO, group *reminderGroup, recipientType, recipient string, day time.Time) {
+	var pending []models.LoanModel
+	for _, loan := range group.loans {
+		notify, err := s.reminderDue(&loan, group, recipient, day)
+		if err != nil {
+			log.Printf("[REMINDERS] Error consultando el historial del préstamo %d: %v", loan.Id, err)
+			continue
+		}
+		if notify {
+			pending = append(pending, loan)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	data := reminderData{
+		Staff:         recipientType == models.NotificationRecipientStaff,
+		RequesterName: requesterLabel(group.requester),
+		DueDate:       group.dueDate.Format("02/01/2006"),
+		DaysOverdue:   int(day.Sub(group.dueDate).Hours() / 24),
+	}
+	for _, loan := range pending {
+		data.Artefacts = append(data.Artefacts, artefactLabel(loan.Artefact))
+	}
+
+	subject, body, err := renderReminder(group.kind, data)
+	if err == nil {
+		err = s.sender.Send([]string{recipient}, subject, body)
+	}
+
+	status := models.NotificationStatusSent
+	var errorMessage *string
+	if err != nil {
+		status = models.NotificationStatusFailed
+		message := err.Error()
+		errorMessage = &message
+		report.Failed++
+		log.Printf("[REMINDERS] No se pudo enviar el recordatorio a %s: %v", recipient, err)
+	} else {
+		report.Sent++
+	}
+
+	dueDate := group.dueDate
+	for _, loan := range pending {
+		entry := models.NotificationLogModel{
+			LoanId:        loan.Id,
+			Kind:          group.kind,
+			RecipientType: recipientType,
+			Recipient:     recipient,
+			Subject:       subject,
+			DueDate:       &dueDate,
+			Status:        status,
+			Error:         errorMessage,
+		}
+		if err := s.db.Create(&entry).Error; err != nil {
+			log.Printf("[REMINDERS] Error registrando la notificación del préstamo %d: %v", loan.Id, err)
+		}
+	}
+}
+
+// reminderDue reports whether the recipient still has to be told about the loan: once per due date
+// before it's due, and again every LOAN_OVERDUE_REMINDER_EVERY_DAYS while it's overdue
+func (s *NotificationService) reminderDue(loan *models.LoanModel, group *reminderGroup, recipient string, day time.Time) (bool, error) {
+	query := s.db.Model(&models.NotificationLogModel{}).
+		Where("loan_id = ? AND kind = ? AND recipient = ? AND status = ?",
+			loan.Id, group.kind, recipient, models.NotificationStatusSent)
+
+	if group.kind == models.NotificationKindOverdue {
+		since := day.AddDate(0, 0, 1-overdueReminderEveryDays())
+		query = query.Where("due_date = ? AND created_at >= ?", group.dueDate, since)
+	} else {
+		query = query.Where("due_date = ?", group.dueDate)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+// renderReminder fills the subject and body templates of a reminder kind
+func renderReminder(kind string, data reminderData) (string, string, error) {
+	var subject, body bytes.Buffer
+	if err := reminderSubjects[kind].Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := reminderBodies[kind].Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
+
+// GetNotificationLog retrieves the reminder log, newest first, optionally of one loan and/or status
+func (s *NotificationService) GetNotificationLog(loanId *int, status string) ([]models.NotificationLogModel, error) {
+	var entries []models.NotificationLogModel
+
+	query := s.db.Model(&models.NotificationLogModel{})
+	if loanId != nil {
+		query = query.Where("loan_id = ?", *loanId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Order("created_at DESC, id DESC").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SetLoanNotificationsOptOut turns the reminder emails of a loan on or off
+func (s *NotificationService) SetLoanNotificationsOptOut(loanId int, optOut bool) (*models.LoanModel, error) {
+	var loan models.LoanModel
+	if err := s.db.First(&loan, loanId).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&loan).Update("notifications_opt_out", optOut).Error; err != nil {
+		return nil, err
+	}
+	loan.Status = loanStatus(&loan, today(), loanDueSoonDays())
+	return &loan, nil
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"github.com/ARQAP/ARQAP-Backend/src/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrRequesterInUse is returned when anonymizing a requester with open loans, active reservations or pending requests
+	ErrRequesterInUse = errors.New("el solicitante tiene préstamos abiertos, reservas activas o solicitudes en curso")
+	// ErrRequesterAnonymized is returned when anonymizing or updating a requester already anonymized
+	ErrRequesterAnonymized = errors.New("el solicitante ya fue anonimizado")
+	// ErrRetentionDisabled is returned when running the retention job without REQUESTER_RETENTION_MONTHS
+	ErrRetentionDisabled = errors.New("el período de retención no está configurado (REQUESTER_RETENTION_MONTHS)")
+)
+
+// anonymizedRecipient replaces the requester's email in the notification log
+const anonymizedRecipient = "anonimizado"
+
+// requesterLastActivityQuery lists the requesters not yet anonymized, without open loans, active reservations
+// or pending requests, whose last activity (loans, returns, requests, reservations, movements) is before the cutoff
+const requesterLastActivityQuery = `
+	SELECT r.id AS requester_id, r.firstname, r.lastname, r.type,
+		GREATEST(r.created_at::date, act.last_activity) AS last_activity
+	FROM requester_models r
+	LEFT JOIN LATERAL (
+		SELECT MAX(d) AS last_activity FROM (
+			SELECT GREATEST(l.loan_date, l.return_date) AS d FROM loan_models l WHERE l.requester_id = r.id
+			UNION ALL
+			SELECT lr.updated_at::date FROM loan_request_models lr WHERE lr.requester_id = r.id
+			UNION ALL
+			SELECT COALESCE(rs.end_date, rs.start_date) FROM reservation_models rs WHERE rs.requester_id = r.id
+			UNION ALL
+			SELECT m.movement_date FROM internal_movement_models m WHERE m.requester_id = r.id
+		) activity
+	) act ON TRUE
+	WHERE r.anonymized_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM loan_models l WHERE l.requester_id = r.id AND l.return_date IS NULL)
+		AND NOT EXISTS (SELECT 1 FROM reservation_models rs WHERE rs.requester_id = r.id AND rs.status = ?)
+		AND NOT EXISTS (SELECT 1 FROM loan_request_models lr WHERE lr.requester_id = r.id AND lr.status IN ?)
+		AND GREATEST(r.created_at::date, act.last_activity) < ?
+	ORDER BY last_activity, r.id`
+
+// pendingLoanRequestStatuses are the loan request statuses that still need the requester's data
+var pendingLoanRequestStatuses = []string{
+	models.LoanRequestStatusDraft,
+	models.LoanRequestStatusSubmitted,
+	models.LoanRequestStatusApproved,
+}
+
+type RequesterPrivacyService struct {
+	db    *gorm.DB
+	store storage.Storage
+}
+
+// NewRequesterPrivacyService creates a new instance of RequesterPrivacyService
+func NewRequesterPrivacyService(db *gorm.DB, store storage.Storage) *RequesterPrivacyService {
+	return &RequesterPrivacyService{db: db, store: store}
+}
+
+// requesterRetentionMonths is how long an inactive requester keeps their personal data
+// (REQUESTER_RETENTION_MONTHS, 0 or unset disables anonymization)
+func requesterRetentionMonths() int {
+	return positiveIntEnv("REQUESTER_RETENTION_MONTHS", 0)
+}
+
+// StartRetentionScheduler anonymizes expired requesters every interval in the background.
+// It's disabled when interval <= 0 or there is no retention period configured.
+func (s *RequesterPrivacyService) StartRetentionScheduler(interval time.Duration) {
+	if interval <= 0 || requesterRetentionMonths() == 0 {
+		log.Println("[RETENTION] Anonimización de solicitantes deshabilitada")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			report, err := s.RunRetention()
+			if err != nil {
+				log.Printf("[RETENTION] Error en la anonimización programada: %v", err)
+				continue
+			}
+			if len(report.Anonymized) > 0 {
+				log.Printf("[RETENTION] Solicitantes anonimizados: %v", report.Anonymized)
+			}
+		}
+	}()
+}
+
+// retentionCutoff is the date before which a requester's last activity makes them expire
+func retentionCutoff(months int) time.Time {
+	return dateOnly(today()).AddDate(0, -months, 0)
+}
+
+// GetRetentionCandidates lists the requesters that the next retention run would anonymize
+func (s *RequesterPrivacyService) GetRetentionCandidates() ([]dtos.RetentionCandidateDTO, error) {
+	months := requesterRetentionMonths()
+	if months == 0 {
+		return nil, ErrRetentionDisabled
+	}
+	return s.retentionCandidates(retentionCutoff(months))
+}
+
+func (s *RequesterPrivacyService) retentionCandidates(cutoff time.Time) ([]dtos.RetentionCandidateDTO, error) {
+	candidates := []dtos.RetentionCandidateDTO{}
+	if err := s.db.Raw(requesterLastActivityQuery,
+		models.ReservationStatusActive, pendingLoanRequestStatuses, cutoff).
+		Scan(&candidates).Error; err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// RunRetention anonymizes every requester inactive for longer than the retention period
+func (s *RequesterPrivacyService) RunRetention() (*dtos.RetentionRunDTO, error) {
+	months := requesterRetentionMonths()
+	if months == 0 {
+		return nil, ErrRetentionDisabled
+	}
+
+	report := &dtos.RetentionRunDTO{RetentionMonths: months, Cutoff: retentionCutoff(months), Anonymized: []int{}}
+	candidates, err := s.retentionCandidates(report.Cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		if _, err := s.AnonymizeRequester(candidate.RequesterId); err != nil {
+			// Pudo haber tenido actividad entre la consulta y la anonimización
+			log.Printf("[RETENTION] No se pudo anonimizar al solicitante %d: %v", candidate.RequesterId, err)
+			continue
+		}
+		report.Anonymized = append(report.Anonymized, candidate.RequesterId)
+	}
+	return report, nil
+}
+
+// AnonymizeRequester erases the personal data of a requester while keeping the rows, so loans,
+// requests and statistics still point to it. The reminder log loses the email and the generated
+// loan documents, which print name and DNI, are removed (they can be generated again).
+// The free text of loans, loan headers, loan requests and reservations (purpose, observations) is kept
+// on purpose: it describes what was lent and why, is part of the collection's records, and must not
+// hold personal data of the requester.
+func (s *RequesterPrivacyService) AnonymizeRequester(id int) (*models.RequesterModel, error) {
+	var documents []models.LoanDocumentModel
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var requester models.RequesterModel
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&requester, id).Error; err != nil {
+			return err
+		}
+		if requester.AnonymizedAt != nil {
+			return ErrRequesterAnonymized
+		}
+		if inUse, err := requesterInUse(tx, id); err != nil {
+			return err
+		} else if inUse {
+			return ErrRequesterInUse
+		}
+
+		if err := tx.Model(&requester).Updates(map[string]interface{}{
+			"firstname":     nil,
+			"lastname":      nil,
+			"dni":           nil,
+			"email":         nil,
+			"phone_number":  nil,
+			"position":      nil,
+			"anonymized_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.NotificationLogModel{}).
+			Where("recipient_type = ? AND loan_id IN (?)", models.NotificationRecipientRequester, requesterLoanIds(tx, id)).
+			Update("recipient", anonymizedRecipient).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("loan_id IN (?)", requesterLoanIds(tx, id)).Find(&documents).Error; err != nil {
+			return err
+		}
+		if len(documents) > 0 {
+			if err := tx.Delete(&documents).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Los archivos se borran después del commit: si falla, quedan huérfanos y no datos a medias
+	for _, document := range documents {
+		if err := s.store.Delete(document.FilePath); err != nil {
+			log.Printf("[RETENTION] No se pudo borrar el documento %s: %v", document.FilePath, err)
+		}
+	}
+
+	var requester models.RequesterModel
+	if err := s.db.Preload("Institution").First(&requester, id).Error; err != nil {
+		return nil, err
+	}
+	return &requester, nil
+}
+
+// requesterLoanIds is the subquery of the IDs of the requester's loans
+func requesterLoanIds(db *gorm.DB, id int) *gorm.DB {
+	return db.Model(&models.LoanModel{}).Select("id").Where("requester_id = ?", id)
+}
+
+// requesterInUse reports whether the requester has open loans, active reservations or pending loan requests
+func requesterInUse(tx *gorm.DB, id int) (bool, error) {
+	checks := []*gorm.DB{
+		tx.Model(&models.LoanModel{}).Where("requester_id = ? AND return_date IS NULL", id),
+		tx.Model(&models.ReservationModel{}).Where("requester_id = ? AND status = ?", id, models.ReservationStatusActive),
+		tx.Model(&models.LoanRequestModel{}).Where("requester_id = ? AND status IN ?", id, pendingLoanRequestStatuses),
+	}
+	for _, check := range checks {
+		var count int64
+		if err := check.Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ExportRequesterData gathers everything stored about a requester (data-subject access request)
+func (s *RequesterPrivacyService) ExportRequesterData(id int) (*dtos.RequesterDataExportDTO, error) {
+	export := &dtos.RequesterDataExportDTO{ExportedAt: time.Now()}
+
+	if err := s.db.Preload("Institution").First(&export.Requester, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.
+		Preload("Institution").
+		Preload("Artefact").
+		Where("requester_id = ?", id).
+		Order("loan_date ASC, id ASC").
+		Find(&export.Loans).Error; err != nil {
+		return nil, err
+	}
+	setLoanStatuses(export.Loans)
+
+	if err := s.db.Where("requester_id = ?", id).Order("loan_date ASC, id ASC").Find(&export.LoanHeaders).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.
+		Preload("Items.Artefact").
+		Preload("Events", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC, id ASC")
+		}).
+		Where("requester_id = ?", id).
+		Order("created_at ASC").
+		Find(&export.LoanRequests).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Preload("Artefact").Where("requester_id = ?", id).Order("start_date ASC").Find(&export.Reservations).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Preload("Artefact").Where("requester_id = ?", id).
+		Order("movement_date ASC, movement_time ASC").
+		Find(&export.InternalMovements).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Where("loan_id IN (?)", requesterLoanIds(s.db, id)).Order("created_at ASC").Find(&export.LoanDocuments).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("loan_id IN (?) AND recipient_type = ?", requesterLoanIds(s.db, id), models.NotificationRecipientRequester).
+		Order("created_at ASC").
+		Find(&export.Notifications).Error; err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
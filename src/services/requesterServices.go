@@ -1,8 +1,10 @@
 package services
 
 import (
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
 	"github.com/ARQAP/ARQAP-Backend/src/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type RequesterService struct {
@@ -17,7 +19,7 @@ func NewRequesterService(db *gorm.DB) *RequesterService {
 // GetAllRequesters retrieves all Requester records from the database
 func (s *RequesterService) GetAllRequesters() ([]models.RequesterModel, error) {
 	var requesters []models.RequesterModel
-	result := s.db.Find(&requesters)
+	result := s.db.Preload("Institution").Find(&requesters)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -27,7 +29,7 @@ func (s *RequesterService) GetAllRequesters() ([]models.RequesterModel, error) {
 // GetRequesterByID retrieves a Requester record by its ID
 func (s *RequesterService) GetRequesterByID(id int) (*models.RequesterModel, error) {
 	var requester models.RequesterModel
-	result := s.db.First(&requester, id)
+	result := s.db.Preload("Institution").First(&requester, id)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -36,7 +38,17 @@ func (s *RequesterService) GetRequesterByID(id int) (*models.RequesterModel, err
 
 // CreateRequester creates a new Requester record in the database
 func (s *RequesterService) CreateRequester(requester *models.RequesterModel) (*models.RequesterModel, error){
-	result := s.db.Create(requester)
+	// La institución se vincula por ID, nunca se crea desde el solicitante
+	if requester.InstitutionId != nil {
+		if err := checkInstitutionExists(s.db, *requester.InstitutionId); err != nil {
+			return nil, err
+		}
+	}
+
+	// Solo la anonimización marca al solicitante como anonimizado
+	requester.AnonymizedAt = nil
+
+	result := s.db.Omit("Institution").Create(requester)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -49,27 +61,106 @@ func (s *RequesterService) DeleteRequester(id int) error {
 	return result.Error
 }
 
-// UpdateRequester updates an existing Requester record
+// UpdateRequester updates an existing Requester record.
+// Anonymized requesters can't be updated, so their personal data isn't written back.
 func (s *RequesterService) UpdateRequester(id int, updatedRequester *models.RequesterModel) (*models.RequesterModel, error) {
 	var requester models.RequesterModel
-	result := s.db.First(&requester, id)
-	if result.Error != nil {
-		return nil, result.Error
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Bloquear la fila para no competir con una anonimización simultánea
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&requester, id).Error; err != nil {
+			return err
+		}
+		if requester.AnonymizedAt != nil {
+			return ErrRequesterAnonymized
+		}
+
+		// Set the ID to ensure we update the correct record
+		updatedRequester.Id = id
+
+		if updatedRequester.InstitutionId != nil {
+			if err := checkInstitutionExists(tx, *updatedRequester.InstitutionId); err != nil {
+				return err
+			}
+		}
+
+		// Use Updates instead of replacing the whole object
+		return tx.Model(&requester).Omit("Institution", "CreatedAt", "AnonymizedAt").Updates(updatedRequester).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch the updated record
+	if err := s.db.Preload("Institution").First(&requester, id).Error; err != nil {
+		return nil, err
 	}
-    // Set the ID to ensure we update the correct record
-    updatedRequester.Id = id
-    
-    // Use Updates instead of replacing the whole object
-    result = s.db.Model(&requester).Updates(updatedRequester)
-    if result.Error != nil {
-        return nil, result.Error
-    }
-    
-    // Fetch the updated record
-    result = s.db.First(&requester, id)
-    if result.Error != nil {
-        return nil, result.Error
-    }
-    
-    return &requester, nil
-}
\ No newline at end of file
+	return &requester, nil
+}
+
+// GetRequesterHistory gathers the loans and internal movements of a requester, with counts of
+// late returns and whether the eligibility rules allow lending them more pieces
+func (s *RequesterService) GetRequesterHistory(id int) (*dtos.RequesterHistoryDTO, error) {
+	history := &dtos.RequesterHistoryDTO{}
+	if err := s.db.Preload("Institution").First(&history.Requester, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.
+		Preload("Institution").
+		Preload("Artefact").
+		Preload("Artefact.InternalClassifier").
+		Where("requester_id = ?", id).
+		Order("loan_date DESC, id DESC").
+		Find(&history.Loans).Error; err != nil {
+		return nil, err
+	}
+	setLoanStatuses(history.Loans)
+
+	if err := s.db.
+		Preload("Artefact").
+		Preload("FromPhysicalLocation").
+		Preload("FromPhysicalLocation.Shelf").
+		Preload("ToPhysicalLocation").
+		Preload("ToPhysicalLocation.Shelf").
+		Where("requester_id = ?", id).
+		Order("movement_date DESC, movement_time DESC").
+		Find(&history.InternalMovements).Error; err != nil {
+		return nil, err
+	}
+
+	history.Summary = summarizeRequesterLoans(history.Loans)
+
+	eligibility, err := requesterLoanEligibility(s.db, &history.Requester, 1)
+	if err != nil {
+		return nil, err
+	}
+	history.Eligibility = *eligibility
+	return history, nil
+}
+
+// summarizeRequesterLoans counts loans by status; a return is late when it happens after the due date
+func summarizeRequesterLoans(loans []models.LoanModel) dtos.RequesterLoanSummaryDTO {
+	summary := dtos.RequesterLoanSummaryDTO{TotalLoans: len(loans)}
+	for _, loan := range loans {
+		switch loan.Status {
+		case models.LoanStatusReturned:
+			summary.ReturnedLoans++
+			if loan.DueDate == nil {
+				continue
+			}
+			daysLate := int(dateOnly(*loan.ReturnDate).Sub(dateOnly(*loan.DueDate)).Hours() / 24)
+			if daysLate > 0 {
+				summary.LateReturns++
+				if daysLate > summary.MaxDaysLate {
+					summary.MaxDaysLate = daysLate
+				}
+			}
+		case models.LoanStatusOverdue:
+			summary.OpenLoans++
+			summary.OverdueLoans++
+		default:
+			summary.OpenLoans++
+		}
+	}
+	return summary
+}
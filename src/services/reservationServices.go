@@ -0,0 +1,403 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"gorm.io/gorm"
+)
+
+// Kinds of busy periods in the artefact calendar
+const (
+	CalendarKindReservation      = "reservation"
+	CalendarKindLoan             = "loan"
+	CalendarKindInternalMovement = "internal_movement"
+)
+
+var (
+	// ErrArtefactReserved is returned when a piece is already reserved, lent or moved in the asked period
+	ErrArtefactReserved = errors.New("la pieza arqueológica ya está reservada o prestada en ese período")
+	// ErrInvalidReservationPeriod is returned when the end date is before the start date
+	ErrInvalidReservationPeriod = errors.New("la fecha de fin de la reserva no puede ser anterior a la de inicio")
+	// ErrReservationNotActive is returned when editing or cancelling a reservation that is no longer active
+	ErrReservationNotActive = errors.New("la reserva no está activa")
+	// ErrReservationFromLoanRequest is returned when touching a reservation owned by a loan request
+	ErrReservationFromLoanRequest = errors.New("la reserva pertenece a una solicitud de préstamo; gestionarla desde la solicitud")
+)
+
+// ReservationConflictError lists the busy periods that overlap a requested one
+type ReservationConflictError struct {
+	ArtefactId int
+	Conflicts  []dtos.CalendarEntryDTO
+}
+
+func (e *ReservationConflictError) Error() string {
+	return fmt.Sprintf("pieza %d: %s", e.ArtefactId, ErrArtefactReserved.Error())
+}
+
+func (e *ReservationConflictError) Is(target error) bool {
+	return target == ErrArtefactReserved
+}
+
+// calendarIgnore excludes entries from a conflict check (the reservation being edited, etc.)
+type calendarIgnore struct {
+	ReservationId int
+	LoanRequestId int
+}
+
+type ReservationService struct {
+	db *gorm.DB
+}
+
+// NewReservationService creates a new instance of ReservationService
+func NewReservationService(db *gorm.DB) *ReservationService {
+	return &ReservationService{db: db}
+}
+
+// GetAllReservations retrieves reservations, optionally of one artefact and/or in one status
+func (s *ReservationService) GetAllReservations(artefactId *int, status string) ([]models.ReservationModel, error) {
+	var reservations []models.ReservationModel
+
+	query := s.db.Preload("Artefact").Preload("Requester")
+	if artefactId != nil {
+		query = query.Where("artefact_id = ?", *artefactId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Order("start_date ASC, id ASC").Find(&reservations).Error; err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// GetReservationByID retrieves a reservation by its ID
+func (s *ReservationService) GetReservationByID(id int) (*models.ReservationModel, error) {
+	var reservation models.ReservationModel
+	if err := s.db.Preload("Artefact").Preload("Requester").First(&reservation, id).Error; err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// CreateReservation books an artefact for a period, failing with a ReservationConflictError
+// when it is already reserved, lent or moved in that period
+func (s *ReservationService) CreateReservation(dto *dtos.CreateReservationDTO, userId *int) (*models.ReservationModel, error) {
+	endDate := dto.EndDate
+	reservation := models.ReservationModel{
+		ArtefactId:  dto.ArtefactId,
+		StartDate:   dto.StartDate,
+		EndDate:     &endDate,
+		Status:      models.ReservationStatusActive,
+		Purpose:     dto.Purpose,
+		RequesterId: dto.RequesterId,
+		CreatedById: userId,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return createReservation(tx, &reservation, calendarIgnore{})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetReservationByID(reservation.Id)
+}
+
+// UpdateReservation changes the period or details of an active reservation, checking conflicts again
+func (s *ReservationService) UpdateReservation(id int, dto *dtos.UpdateReservationDTO) (*models.ReservationModel, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var reservation models.ReservationModel
+		if err := tx.First(&reservation, id).Error; err != nil {
+			return err
+		}
+		if reservation.Status != models.ReservationStatusActive {
+			return ErrReservationNotActive
+		}
+		if reservation.LoanRequestId != nil {
+			return ErrReservationFromLoanRequest
+		}
+
+		updates := map[string]interface{}{}
+		if dto.StartDate != nil {
+			reservation.StartDate = *dto.StartDate
+			updates["start_date"] = *dto.StartDate
+		}
+		if dto.EndDate != nil {
+			reservation.EndDate = dto.EndDate
+			updates["end_date"] = *dto.EndDate
+		}
+		if dto.Purpose != nil {
+			updates["purpose"] = *dto.Purpose
+		}
+		if dto.RequesterId != nil {
+			updates["requester_id"] = *dto.RequesterId
+		}
+
+		if dto.StartDate != nil || dto.EndDate != nil {
+			if err := checkArtefactPeriod(tx, reservation.ArtefactId, reservation.StartDate, reservation.EndDate,
+				calendarIgnore{ReservationId: id}); err != nil {
+				return err
+			}
+		}
+
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(&reservation).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetReservationByID(id)
+}
+
+// CancelReservation cancels an active reservation
+func (s *ReservationService) CancelReservation(id int) (*models.ReservationModel, error) {
+	var reservation models.ReservationModel
+	if err := s.db.First(&reservation, id).Error; err != nil {
+		return nil, err
+	}
+	if reservation.Status != models.ReservationStatusActive {
+		return nil, ErrReservationNotActive
+	}
+	if reservation.LoanRequestId != nil {
+		return nil, ErrReservationFromLoanRequest
+	}
+
+	if err := s.db.Model(&reservation).Update("status", models.ReservationStatusCancelled).Error; err != nil {
+		return nil, err
+	}
+	return s.GetReservationByID(id)
+}
+
+// GetArtefactCalendar lists the periods in which an artefact is reserved, lent or moved,
+// optionally only those overlapping [from, to]
+func (s *ReservationService) GetArtefactCalendar(artefactId int, from, to *time.Time) ([]dtos.CalendarEntryDTO, error) {
+	var count int64
+	if err := s.db.Model(&models.ArtefactModel{}).Where("id = ?", artefactId).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	entries, err := artefactBusyPeriods(s.db, artefactId, calendarIgnore{})
+	if err != nil {
+		return nil, err
+	}
+
+	if from == nil && to == nil {
+		return entries, nil
+	}
+
+	start := time.Time{}
+	if from != nil {
+		start = *from
+	}
+	filtered := []dtos.CalendarEntryDTO{}
+	for _, entry := range entries {
+		if periodsOverlap(start, to, entry.Start, entry.End) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered, nil
+}
+
+// ======================= DISPONIBILIDAD POR PERÍODO =======================
+
+// createReservation checks the period and stores an active reservation inside the given transaction
+func createReservation(tx *gorm.DB, reservation *models.ReservationModel, ignore calendarIgnore) error {
+	if _, err := lockArtefact(tx, reservation.ArtefactId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("pieza %d: %w", reservation.ArtefactId, ErrArtefactNotFound)
+		}
+		return err
+	}
+
+	if err := checkArtefactPeriod(tx, reservation.ArtefactId, reservation.StartDate, reservation.EndDate, ignore); err != nil {
+		return err
+	}
+
+	reservation.Status = models.ReservationStatusActive
+	return tx.Create(reservation).Error
+}
+
+// checkArtefactPeriod fails with a ReservationConflictError when the artefact is busy at some point of [start, end]
+func checkArtefactPeriod(tx *gorm.DB, artefactId int, start time.Time, end *time.Time, ignore calendarIgnore) error {
+	if end != nil && dateOnly(*end).Before(dateOnly(start)) {
+		return ErrInvalidReservationPeriod
+	}
+
+	entries, err := artefactBusyPeriods(tx, artefactId, ignore)
+	if err != nil {
+		return err
+	}
+
+	var conflicts []dtos.CalendarEntryDTO
+	for _, entry := range entries {
+		if periodsOverlap(start, end, entry.Start, entry.End) {
+			conflicts = append(conflicts, entry)
+		}
+	}
+	if len(conflicts) > 0 {
+		return &ReservationConflictError{ArtefactId: artefactId, Conflicts: conflicts}
+	}
+	return nil
+}
+
+// checkLoanPeriod fails when an active reservation or an active internal movement of the artefact
+// overlaps a loan period. Other loans are checked by the availability state instead.
+func checkLoanPeriod(tx *gorm.DB, artefactId int, start time.Time, end *time.Time) error {
+	var reservations []models.ReservationModel
+	if err := tx.Where("artefact_id = ? AND status = ?", artefactId, models.ReservationStatusActive).
+		Find(&reservations).Error; err != nil {
+		return err
+	}
+
+	var conflicts []dtos.CalendarEntryDTO
+	for _, reservation := range reservations {
+		if periodsOverlap(start, end, reservation.StartDate, reservation.EndDate) {
+			conflicts = append(conflicts, reservationEntry(&reservation))
+		}
+	}
+
+	movements, err := activeMovementEntries(tx, artefactId)
+	if err != nil {
+		return err
+	}
+	for _, entry := range movements {
+		if periodsOverlap(start, end, entry.Start, entry.End) {
+			conflicts = append(conflicts, entry)
+		}
+	}
+	if len(conflicts) > 0 {
+		return &ReservationConflictError{ArtefactId: artefactId, Conflicts: conflicts}
+	}
+	return nil
+}
+
+// artefactBusyPeriods builds the calendar of an artefact: active reservations, loans and internal movements
+func artefactBusyPeriods(db *gorm.DB, artefactId int, ignore calendarIgnore) ([]dtos.CalendarEntryDTO, error) {
+	entries := []dtos.CalendarEntryDTO{}
+
+	var reservations []models.ReservationModel
+	query := db.Where("artefact_id = ? AND status = ?", artefactId, models.ReservationStatusActive)
+	if ignore.ReservationId != 0 {
+		query = query.Where("id <> ?", ignore.ReservationId)
+	}
+	if ignore.LoanRequestId != 0 {
+		query = query.Where("loan_request_id IS NULL OR loan_request_id <> ?", ignore.LoanRequestId)
+	}
+	if err := query.Find(&reservations).Error; err != nil {
+		return nil, err
+	}
+	for i := range reservations {
+		entries = append(entries, reservationEntry(&reservations[i]))
+	}
+
+	var loans []models.LoanModel
+	if err := db.Where("artefact_id = ?", artefactId).Find(&loans).Error; err != nil {
+		return nil, err
+	}
+	day := today()
+	for _, loan := range loans {
+		entry := dtos.CalendarEntryDTO{
+			Kind:   CalendarKindLoan,
+			Id:     loan.Id,
+			Start:  dateOnly(loan.LoanDate),
+			Status: loanStatus(&loan, day, loanDueSoonDays()),
+		}
+		switch {
+		case loan.ReturnDate != nil:
+			end := dateOnly(*loan.ReturnDate)
+			entry.End = &end
+		case loan.DueDate != nil && !dateOnly(*loan.DueDate).Before(dateOnly(day)):
+			end := dateOnly(*loan.DueDate)
+			entry.End = &end
+		}
+		// Un préstamo abierto sin fecha pactada o vencido ocupa la pieza hasta que se devuelva
+		entries = append(entries, entry)
+	}
+
+	movements, err := activeMovementEntries(db, artefactId)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, movements...)
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Start.Before(entries[j].Start)
+	})
+	return entries, nil
+}
+
+// activeMovementEntries returns the calendar entries of the internal movements of an artefact
+// that are neither finished nor voided
+func activeMovementEntries(db *gorm.DB, artefactId int) ([]dtos.CalendarEntryDTO, error) {
+	var movements []models.InternalMovementModel
+	if err := db.Where("artefact_id = ? AND return_date IS NULL AND voided_at IS NULL", artefactId).Find(&movements).Error; err != nil {
+		return nil, err
+	}
+
+	day := today()
+	entries := make([]dtos.CalendarEntryDTO, 0, len(movements))
+	for _, movement := range movements {
+		entry := dtos.CalendarEntryDTO{
+			Kind:   CalendarKindInternalMovement,
+			Id:     movement.Id,
+			Start:  dateOnly(movement.MovementDate),
+			Status: "active",
+		}
+		if movement.Reason != nil {
+			entry.Description = *movement.Reason
+		}
+		// Un movimiento con retorno programado vencido sigue ocupando la pieza hasta que vuelva
+		if movement.ExpectedReturnDate != nil && !dateOnly(*movement.ExpectedReturnDate).Before(dateOnly(day)) {
+			end := dateOnly(*movement.ExpectedReturnDate)
+			entry.End = &end
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// reservationEntry converts a reservation into a calendar entry
+func reservationEntry(reservation *models.ReservationModel) dtos.CalendarEntryDTO {
+	entry := dtos.CalendarEntryDTO{
+		Kind:   CalendarKindReservation,
+		Id:     reservation.Id,
+		Start:  dateOnly(reservation.StartDate),
+		Status: reservation.Status,
+	}
+	if reservation.EndDate != nil {
+		end := dateOnly(*reservation.EndDate)
+		entry.End = &end
+	}
+	if reservation.Purpose != nil {
+		entry.Description = *reservation.Purpose
+	}
+	return entry
+}
+
+// periodsOverlap reports whether two day ranges intersect. A nil end means open-ended.
+func periodsOverlap(startA time.Time, endA *time.Time, startB time.Time, endB *time.Time) bool {
+	if endB != nil && dateOnly(*endB).Before(dateOnly(startA)) {
+		return false
+	}
+	if endA != nil && dateOnly(*endA).Before(dateOnly(startB)) {
+		return false
+	}
+	return true
+}
+
+// dateOnly drops the time of day, keeping the calendar date
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
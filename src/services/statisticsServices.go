@@ -0,0 +1,217 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"gorm.io/gorm"
+)
+
+// defaultStatisticsLimit is the size of the rankings when no limit is given
+const defaultStatisticsLimit = 10
+
+// loansPerMonthQuery counts loans by the month they started and by the month they were returned
+const loansPerMonthQuery = `
+	SELECT month, SUM(loans) AS loans, SUM(returned) AS returned
+	FROM (
+		SELECT to_char(loan_date, 'YYYY-MM') AS month, 1 AS loans, 0 AS returned
+		FROM loan_models WHERE %s
+		UNION ALL
+		SELECT to_char(return_date, 'YYYY-MM') AS month, 0 AS loans, 1 AS returned
+		FROM loan_models WHERE return_date IS NOT NULL AND %s
+	) t
+	GROUP BY month
+	ORDER BY month`
+
+// loansPerRequesterTypeQuery counts loans by requester type; loans without requester are grouped apart
+const loansPerRequesterTypeQuery = `
+	SELECT COALESCE(r.type, 'Sin solicitante') AS requester_type, COUNT(*) AS loans
+	FROM loan_models l
+	LEFT JOIN requester_models r ON r.id = l.requester_id
+	WHERE %s
+	GROUP BY COALESCE(r.type, 'Sin solicitante')
+	ORDER BY loans DESC, requester_type`
+
+// loanDurationQuery measures returned loans (loan to return) and open ones (loan to today), in days
+const loanDurationQuery = `
+	SELECT
+		COUNT(*) FILTER (WHERE return_date IS NOT NULL) AS returned_loans,
+		COALESCE(AVG(return_date - loan_date) FILTER (WHERE return_date IS NOT NULL), 0) AS average_days,
+		COALESCE(MIN(return_date - loan_date) FILTER (WHERE return_date IS NOT NULL), 0) AS min_days,
+		COALESCE(MAX(return_date - loan_date) FILTER (WHERE return_date IS NOT NULL), 0) AS max_days,
+		COUNT(*) FILTER (WHERE return_date IS NULL) AS open_loans,
+		COALESCE(AVG(CURRENT_DATE - loan_date) FILTER (WHERE return_date IS NULL), 0) AS average_open_days
+	FROM loan_models
+	WHERE %s`
+
+// mostRequestedArtefactsQuery ranks artefacts by number of loans
+const mostRequestedArtefactsQuery = `
+	SELECT a.id AS artefact_id, a.name, COUNT(*) AS loans
+	FROM loan_models l
+	JOIN artefact_models a ON a.id = l.artefact_id
+	WHERE %s
+	GROUP BY a.id, a.name
+	ORDER BY loans DESC, a.id
+	LIMIT ?`
+
+// mostRequestedCollectionsQuery ranks collections by number of loans of their pieces
+const mostRequestedCollectionsQuery = `
+	SELECT c.id AS collection_id, COALESCE(c.name, 'Sin colección') AS collection_name,
+		COUNT(*) AS loans, COUNT(DISTINCT a.id) AS artefacts
+	FROM loan_models l
+	JOIN artefact_models a ON a.id = l.artefact_id
+	LEFT JOIN collection_models c ON c.id = a.collection_id
+	WHERE %s
+	GROUP BY c.id, c.name
+	ORDER BY loans DESC, collection_name
+	LIMIT ?`
+
+// movementsPerShelfQuery counts movements arriving at and leaving each shelf per month (voided ones are left out)
+const movementsPerShelfQuery = `
+	SELECT month, shelf_id, shelf_code, SUM(incoming) AS incoming, SUM(outgoing) AS outgoing
+	FROM (
+		SELECT to_char(m.movement_date, 'YYYY-MM') AS month, s.id AS shelf_id, s.code AS shelf_code,
+			1 AS incoming, 0 AS outgoing
+		FROM internal_movement_models m
+		JOIN physical_location_models p ON p.id = m.to_physical_location_id
+		JOIN shelf_models s ON s.id = p.shelf_id
+		WHERE m.voided_at IS NULL AND %s
+		UNION ALL
+		SELECT to_char(m.movement_date, 'YYYY-MM') AS month, s.id AS shelf_id, s.code AS shelf_code,
+			0 AS incoming, 1 AS outgoing
+		FROM internal_movement_models m
+		JOIN physical_location_models p ON p.id = m.from_physical_location_id
+		JOIN shelf_models s ON s.id = p.shelf_id
+		WHERE m.voided_at IS NULL AND %s
+	) t
+	GROUP BY month, shelf_id, shelf_code
+	ORDER BY month, shelf_code`
+
+type StatisticsService struct {
+	db *gorm.DB
+}
+
+// NewStatisticsService creates a new instance of StatisticsService
+func NewStatisticsService(db *gorm.DB) *StatisticsService {
+	return &StatisticsService{db: db}
+}
+
+// dateRange builds the WHERE condition restricting column to the period of the filter
+func dateRange(column string, filter *dtos.StatisticsFilterDTO) (string, []interface{}) {
+	condition := "TRUE"
+	var args []interface{}
+	if filter.From != nil {
+		condition += fmt.Sprintf(" AND %s >= ?", column)
+		args = append(args, dateOnly(*filter.From))
+	}
+	if filter.To != nil {
+		condition += fmt.Sprintf(" AND %s <= ?", column)
+		args = append(args, dateOnly(*filter.To))
+	}
+	return condition, args
+}
+
+func statisticsLimit(filter *dtos.StatisticsFilterDTO) int {
+	if filter.Limit <= 0 {
+		return defaultStatisticsLimit
+	}
+	return filter.Limit
+}
+
+// GetLoansPerMonth counts the loans started and returned each month. Months without activity are included with zeros.
+func (s *StatisticsService) GetLoansPerMonth(filter *dtos.StatisticsFilterDTO) ([]dtos.LoansPerMonthDTO, error) {
+	loanCondition, loanArgs := dateRange("loan_date", filter)
+	returnCondition, returnArgs := dateRange("return_date", filter)
+
+	var rows []dtos.LoansPerMonthDTO
+	query := fmt.Sprintf(loansPerMonthQuery, loanCondition, returnCondition)
+	if err := s.db.Raw(query, append(loanArgs, returnArgs...)...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return []dtos.LoansPerMonthDTO{}, nil
+	}
+
+	first, _ := time.Parse("2006-01", rows[0].Month)
+	last, _ := time.Parse("2006-01", rows[len(rows)-1].Month)
+	if filter.From != nil {
+		first = time.Date(filter.From.Year(), filter.From.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	if filter.To != nil {
+		last = time.Date(filter.To.Year(), filter.To.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	byMonth := make(map[string]dtos.LoansPerMonthDTO, len(rows))
+	for _, row := range rows {
+		byMonth[row.Month] = row
+	}
+	months := []dtos.LoansPerMonthDTO{}
+	for month := first; !month.After(last); month = month.AddDate(0, 1, 0) {
+		key := month.Format("2006-01")
+		row, ok := byMonth[key]
+		if !ok {
+			row = dtos.LoansPerMonthDTO{Month: key}
+		}
+		months = append(months, row)
+	}
+	return months, nil
+}
+
+// GetLoansPerRequesterType counts the loans of each requester type (Investigador, Departamento, Exhibición)
+func (s *StatisticsService) GetLoansPerRequesterType(filter *dtos.StatisticsFilterDTO) ([]dtos.LoansPerRequesterTypeDTO, error) {
+	condition, args := dateRange("l.loan_date", filter)
+
+	rows := []dtos.LoansPerRequesterTypeDTO{}
+	if err := s.db.Raw(fmt.Sprintf(loansPerRequesterTypeQuery, condition), args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// GetLoanDuration returns the average, shortest and longest loan in days, plus the age of the open ones
+func (s *StatisticsService) GetLoanDuration(filter *dtos.StatisticsFilterDTO) (*dtos.LoanDurationDTO, error) {
+	condition, args := dateRange("loan_date", filter)
+
+	var duration dtos.LoanDurationDTO
+	if err := s.db.Raw(fmt.Sprintf(loanDurationQuery, condition), args...).Scan(&duration).Error; err != nil {
+		return nil, err
+	}
+	return &duration, nil
+}
+
+// GetMostRequestedArtefacts ranks the artefacts lent most often
+func (s *StatisticsService) GetMostRequestedArtefacts(filter *dtos.StatisticsFilterDTO) ([]dtos.MostRequestedArtefactDTO, error) {
+	condition, args := dateRange("l.loan_date", filter)
+	args = append(args, statisticsLimit(filter))
+
+	rows := []dtos.MostRequestedArtefactDTO{}
+	if err := s.db.Raw(fmt.Sprintf(mostRequestedArtefactsQuery, condition), args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// GetMostRequestedCollections ranks the collections whose pieces are lent most often
+func (s *StatisticsService) GetMostRequestedCollections(filter *dtos.StatisticsFilterDTO) ([]dtos.MostRequestedCollectionDTO, error) {
+	condition, args := dateRange("l.loan_date", filter)
+	args = append(args, statisticsLimit(filter))
+
+	rows := []dtos.MostRequestedCollectionDTO{}
+	if err := s.db.Raw(fmt.Sprintf(mostRequestedCollectionsQuery, condition), args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// GetMovementsPerShelf counts the internal movements into and out of each shelf per month
+func (s *StatisticsService) GetMovementsPerShelf(filter *dtos.StatisticsFilterDTO) ([]dtos.MovementsPerShelfDTO, error) {
+	condition, args := dateRange("m.movement_date", filter)
+
+	rows := []dtos.MovementsPerShelfDTO{}
+	query := fmt.Sprintf(movementsPerShelfQuery, condition, condition)
+	if err := s.db.Raw(query, append(args, args...)...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,539 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ARQAP/ARQAP-Backend/src/dtos"
+	"github.com/ARQAP/ARQAP-Backend/src/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrStocktakeClosed is returned when scanning or correcting in a closed stocktake session
+	ErrStocktakeClosed = errors.New("el recuento de inventario ya fue cerrado")
+	// ErrShelfNotFound is returned when a stocktake is scoped to a missing shelf
+	ErrShelfNotFound = errors.New("el estante no existe")
+	// ErrPhysicalLocationNotFound is returned when scanning at a missing location
+	ErrPhysicalLocationNotFound = errors.New("la ubicación física no existe")
+	// ErrLocationOutOfStocktakeScope is returned when scanning at a location outside the shelves of the session
+	ErrLocationOutOfStocktakeScope = errors.New("la ubicación no pertenece a los estantes del recuento")
+	// ErrNotStocktakeCorrection is returned when asking to correct a piece the report doesn't show out of place
+	ErrNotStocktakeCorrection = errors.New("la pieza no está fuera de lugar en el recuento")
+	// ErrNoStocktakeCorrections is returned when there is nothing to correct
+	ErrNoStocktakeCorrections = errors.New("el recuento no tiene piezas para corregir")
+)
+
+// Inventory codes as printed by inventoryCode: "Pieza 12", "INPL 34" or the internal classifier ("ABC 56").
+// Piece labels (QR) are resolved by their artefact ID.
+var (
+	pieceCodePattern    = regexp.MustCompile(`(?i)^pieza\s*(\d+)$`)
+	inplCodePattern     = regexp.MustCompile(`(?i)^inpl\s*(\d+)$`)
+	internalCodePattern = regexp.MustCompile(`^(.*?)\s*(\d+)?$`)
+)
+
+type StocktakeService struct {
+	db              *gorm.DB
+	movementService *InternalMovementService
+}
+
+// NewStocktakeService creates a new instance of StocktakeService
+func NewStocktakeService(db *gorm.DB, movementService *InternalMovementService) *StocktakeService {
+	return &StocktakeService{db: db, movementService: movementService}
+}
+
+// GetAllStocktakes lists the stocktake sessions, newest first (optionally only those with the given status)
+func (s *StocktakeService) GetAllStocktakes(status string) ([]models.StocktakeSessionModel, error) {
+	var sessions []models.StocktakeSessionModel
+
+	query := s.db.Preload("Shelves.Shelf")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Order("created_at DESC, id DESC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// GetStocktakeByID retrieves a stocktake session with its counted locations, scans and corrections
+func (s *StocktakeService) GetStocktakeByID(id int) (*models.StocktakeSessionModel, error) {
+	var session models.StocktakeSessionModel
+	if err := s.db.
+		Preload("Shelves.Shelf").
+		Preload("Locations", func(db *gorm.DB) *gorm.DB {
+			return db.Order("counted_at ASC, id ASC")
+		}).
+		Preload("Locations.PhysicalLocation.Shelf").
+		Preload("Scans", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
+		Preload("Scans.Artefact").
+		Preload("Corrections", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
+		First(&session, id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// CreateStocktake opens a stocktake session over the given shelves, or the whole store when there are none
+func (s *StocktakeService) CreateStocktake(dto *dtos.CreateStocktakeDTO, userId *int) (*models.StocktakeSessionModel, error) {
+	session := &models.StocktakeSessionModel{
+		Notes:       dto.Notes,
+		Status:      models.StocktakeStatusOpen,
+		CreatedById: userId,
+	}
+
+	seen := make(map[int]bool)
+	for _, shelfId := range dto.ShelfIds {
+		if seen[shelfId] {
+			continue
+		}
+		seen[shelfId] = true
+
+		var count int64
+		if err := s.db.Model(&models.ShelfModel{}).Where("id = ?", shelfId).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("estante %d: %w", shelfId, ErrShelfNotFound)
+		}
+		session.Shelves = append(session.Shelves, models.StocktakeShelfModel{ShelfId: shelfId})
+	}
+
+	if err := s.db.Omit("Shelves.Shelf").Create(session).Error; err != nil {
+		return nil, err
+	}
+	return s.GetStocktakeByID(session.Id)
+}
+
+// lockOpenStocktake locks the session until the end of the transaction, failing if it's closed
+func lockOpenStocktake(tx *gorm.DB, id int) (*models.StocktakeSessionModel, error) {
+	var session models.StocktakeSessionModel
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Shelves").First(&session, id).Error; err != nil {
+		return nil, err
+	}
+	if session.Status != models.StocktakeStatusOpen {
+		return nil, ErrStocktakeClosed
+	}
+	return &session, nil
+}
+
+// sessionShelfIds returns the shelves of the session scope (empty for the whole store)
+func sessionShelfIds(session *models.StocktakeSessionModel) []int {
+	ids := make([]int, 0, len(session.Shelves))
+	for _, shelf := range session.Shelves {
+		ids = append(ids, shelf.ShelfId)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// SubmitStocktakeScans records the codes found at a location, marking it as counted.
+// It returns every scan registered so far at that location.
+func (s *StocktakeService) SubmitStocktakeScans(id int, dto *dtos.SubmitStocktakeScansDTO, userId *int) ([]models.StocktakeScanModel, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		session, err := lockOpenStocktake(tx, id)
+		if err != nil {
+			return err
+		}
+
+		location, err := stocktakeScanLocation(tx, dto)
+		if err != nil {
+			return err
+		}
+		dto.PhysicalLocationId = location.ID
+		if shelfIds := sessionShelfIds(session); len(shelfIds) > 0 && !containsInt(shelfIds, location.ShelfId) {
+			return ErrLocationOutOfStocktakeScope
+		}
+
+		counted := models.StocktakeLocationModel{SessionId: id, PhysicalLocationId: location.ID}
+		if err := tx.Where(&counted).
+			Assign(models.StocktakeLocationModel{CountedById: userId, CountedAt: time.Now()}).
+			FirstOrCreate(&counted).Error; err != nil {
+			return err
+		}
+
+		if dto.Replace {
+			if err := tx.Where("session_id = ? AND physical_location_id = ?", id, location.ID).
+				Delete(&models.StocktakeScanModel{}).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, code := range dto.Codes {
+			code = strings.TrimSpace(code)
+			if code == "" {
+				continue
+			}
+			artefactId, err := resolveInventoryCode(tx, code)
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(&models.StocktakeScanModel{
+				SessionId:          id,
+				PhysicalLocationId: location.ID,
+				Code:               code,
+				ArtefactId:         artefactId,
+				ScannedById:        userId,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var scans []models.StocktakeScanModel
+	if err := s.db.Preload("Artefact").
+		Where("session_id = ? AND physical_location_id = ?", id, dto.PhysicalLocationId).
+		Order("id ASC").
+		Find(&scans).Error; err != nil {
+		return nil, err
+	}
+	return scans, nil
+}
+
+// stocktakeScanLocation finds the location of a scan submission, by its scanned label or its ID
+func stocktakeScanLocation(tx *gorm.DB, dto *dtos.SubmitStocktakeScansDTO) (*models.PhysicalLocationModel, error) {
+	if strings.TrimSpace(dto.LocationCode) != "" {
+		return resolveLocationCode(tx, dto.LocationCode)
+	}
+
+	var location models.PhysicalLocationModel
+	if err := tx.First(&location, dto.PhysicalLocationId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPhysicalLocationNotFound
+		}
+		return nil, err
+	}
+	return &location, nil
+}
+
+// containsInt reports whether the sorted slice contains the value
+func containsInt(sorted []int, value int) bool {
+	i := sort.SearchInts(sorted, value)
+	return i < len(sorted) && sorted[i] == value
+}
+
+// resolveInventoryCode finds the artefact an inventory code or piece label belongs to. Codes made of several
+// parts ("ABC 12 / INPL 34") resolve with the first part that identifies a piece. Codes matching no piece,
+// or more than one, resolve to nil.
+func resolveInventoryCode(db *gorm.DB, code string) (*int, error) {
+	if id, ok := artefactIdFromPayload(code); ok {
+		var ids []int
+		if err := db.Model(&models.ArtefactModel{}).Where("id = ?", id).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, nil
+		}
+		return &ids[0], nil
+	}
+
+	code = strings.Join(strings.Fields(code), " ")
+	for _, part := range strings.Split(code, "/") {
+		id, err := resolveInventoryCodePart(db, strings.TrimSpace(part))
+		if err != nil || id != nil {
+			return id, err
+		}
+	}
+	return nil, nil
+}
+
+func resolveInventoryCodePart(db *gorm.DB, part string) (*int, error) {
+	if part == "" {
+		return nil, nil
+	}
+
+	query := db.Model(&models.ArtefactModel{})
+	if m := pieceCodePattern.FindStringSubmatch(part); m != nil {
+		id, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, nil
+		}
+		query = query.Where("id = ?", id)
+	} else if m := inplCodePattern.FindStringSubmatch(part); m != nil {
+		id, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, nil
+		}
+		query = query.Where("inpl_classifier_id = ?", id)
+	} else {
+		m := internalCodePattern.FindStringSubmatch(part)
+		classifiers := db.Model(&models.InternalClassifierModel{}).Select("id").Where("LOWER(name) = LOWER(?)", m[1])
+		if m[2] != "" {
+			number, err := strconv.Atoi(m[2])
+			if err != nil {
+				return nil, nil
+			}
+			classifiers = classifiers.Where("number = ?", number)
+		} else {
+			classifiers = classifiers.Where("number IS NULL")
+		}
+		query = query.Where("internal_classifier_id IN (?)", classifiers)
+	}
+
+	var ids []int
+	if err := query.Limit(2).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) != 1 {
+		return nil, nil
+	}
+	return &ids[0], nil
+}
+
+// GetStocktakeReport compares what was found in the counted locations of the session with the database
+func (s *StocktakeService) GetStocktakeReport(id int) (*dtos.StocktakeReportDTO, error) {
+	var session models.StocktakeSessionModel
+	if err := s.db.Preload("Shelves").First(&session, id).Error; err != nil {
+		return nil, err
+	}
+	return stocktakeReport(s.db, &session)
+}
+
+// preloadStocktakePiece loads what the report shows of an artefact
+func preloadStocktakePiece(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("InternalClassifier").
+		Preload("InplClassifier").
+		Preload("PhysicalLocation.Shelf")
+}
+
+// stocktakeReport builds the report of a session against the current state of the database:
+//   - missing: available pieces recorded at a counted location that were not found anywhere
+//   - misplaced: pieces found at a location other than the recorded one, which is within the scope
+//   - unexpected: pieces found whose recorded location is outside the scope or unset, or that are on loan
+func stocktakeReport(db *gorm.DB, session *models.StocktakeSessionModel) (*dtos.StocktakeReportDTO, error) {
+	report := &dtos.StocktakeReportDTO{
+		SessionId:        session.Id,
+		Status:           session.Status,
+		ShelfIds:         sessionShelfIds(session),
+		PendingLocations: []models.PhysicalLocationModel{},
+		Missing:          []dtos.StocktakePieceDTO{},
+		Misplaced:        []dtos.StocktakePieceDTO{},
+		Unexpected:       []dtos.StocktakePieceDTO{},
+		UnknownCodes:     []dtos.StocktakeUnknownCodeDTO{},
+	}
+
+	// Ubicaciones del alcance y cuáles ya se contaron
+	var scope []models.PhysicalLocationModel
+	query := db.Preload("Shelf")
+	if len(report.ShelfIds) > 0 {
+		query = query.Where("shelf_id IN ?", report.ShelfIds)
+	}
+	if err := query.Order("shelf_id ASC, level ASC, \"column\" ASC").Find(&scope).Error; err != nil {
+		return nil, err
+	}
+	scopeLocations := make(map[int]*models.PhysicalLocationModel, len(scope))
+	for i := range scope {
+		scopeLocations[scope[i].ID] = &scope[i]
+	}
+
+	var countedIds []int
+	if err := db.Model(&models.StocktakeLocationModel{}).
+		Where("session_id = ?", session.Id).
+		Pluck("physical_location_id", &countedIds).Error; err != nil {
+		return nil, err
+	}
+	counted := make(map[int]bool, len(countedIds))
+	for _, locationId := range countedIds {
+		counted[locationId] = true
+	}
+	report.CountedLocations = len(countedIds)
+	for _, location := range scope {
+		if !counted[location.ID] {
+			report.PendingLocations = append(report.PendingLocations, location)
+		}
+	}
+
+	// Dónde se encontró cada pieza (si se escaneó más de una vez, vale el último escaneo)
+	var scans []models.StocktakeScanModel
+	if err := db.Where("session_id = ?", session.Id).Order("id ASC").Find(&scans).Error; err != nil {
+		return nil, err
+	}
+	foundAt := make(map[int]int)
+	unknown := make(map[dtos.StocktakeUnknownCodeDTO]bool)
+	for _, scan := range scans {
+		if scan.ArtefactId != nil {
+			foundAt[*scan.ArtefactId] = scan.PhysicalLocationId
+			continue
+		}
+		entry := dtos.StocktakeUnknownCodeDTO{Code: scan.Code, PhysicalLocationId: scan.PhysicalLocationId}
+		if !unknown[entry] {
+			unknown[entry] = true
+			report.UnknownCodes = append(report.UnknownCodes, entry)
+		}
+	}
+
+	var expected []models.ArtefactModel
+	if len(countedIds) > 0 {
+		if err := preloadStocktakePiece(db).
+			Where("physical_location_id IN ? AND available = ?", countedIds, true).
+			Order("id ASC").
+			Find(&expected).Error; err != nil {
+			return nil, err
+		}
+	}
+	report.Expected = len(expected)
+
+	foundIds := make([]int, 0, len(foundAt))
+	for artefactId := range foundAt {
+		foundIds = append(foundIds, artefactId)
+	}
+	var found []models.ArtefactModel
+	if len(foundIds) > 0 {
+		if err := preloadStocktakePiece(db).Where("id IN ?", foundIds).Order("id ASC").Find(&found).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range expected {
+		if _, ok := foundAt[expected[i].ID]; !ok {
+			report.Missing = append(report.Missing, stocktakePiece(&expected[i], nil))
+		}
+	}
+
+	for i := range found {
+		artefact := &found[i]
+		location := scopeLocations[foundAt[artefact.ID]]
+		recorded := artefact.PhysicalLocationID
+		piece := stocktakePiece(artefact, location)
+
+		switch {
+		case !artefact.Available:
+			piece.Reason = dtos.UnexpectedOnLoan
+			report.Unexpected = append(report.Unexpected, piece)
+		case recorded != nil && *recorded == foundAt[artefact.ID]:
+			report.Matched++
+		case recorded == nil:
+			piece.Reason = dtos.UnexpectedNoLocation
+			report.Unexpected = append(report.Unexpected, piece)
+		case scopeLocations[*recorded] != nil:
+			report.Misplaced = append(report.Misplaced, piece)
+		default:
+			piece.Reason = dtos.UnexpectedOutOfScope
+			report.Unexpected = append(report.Unexpected, piece)
+		}
+	}
+
+	return report, nil
+}
+
+// stocktakePiece describes an artefact of the report, found at the given location (nil when missing)
+func stocktakePiece(artefact *models.ArtefactModel, foundLocation *models.PhysicalLocationModel) dtos.StocktakePieceDTO {
+	piece := dtos.StocktakePieceDTO{
+		ArtefactId:         artefact.ID,
+		Name:               artefact.Name,
+		InventoryCode:      inventoryCode(artefact),
+		Available:          artefact.Available,
+		RecordedLocationId: artefact.PhysicalLocationID,
+		RecordedLocation:   artefact.PhysicalLocation,
+		FoundLocation:      foundLocation,
+	}
+	if foundLocation != nil {
+		piece.FoundLocationId = &foundLocation.ID
+	}
+	return piece
+}
+
+// ApplyStocktakeCorrections moves the misplaced and unexpected pieces (except those on loan) to the location
+// where they were found, as a movement group linked to the session. Missing pieces are left for review.
+func (s *StocktakeService) ApplyStocktakeCorrections(id int, dto *dtos.ApplyStocktakeCorrectionsDTO, userId *int) (*models.MovementGroupModel, error) {
+	var group *models.MovementGroupModel
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// La sesión queda bloqueada: un cierre o una segunda corrección esperan a que esta termine
+		// y después ya no ven estas piezas como mal ubicadas
+		session, err := lockOpenStocktake(tx, id)
+		if err != nil {
+			return err
+		}
+
+		report, err := stocktakeReport(tx, session)
+		if err != nil {
+			return err
+		}
+
+		correctable := make(map[int]dtos.StocktakePieceDTO)
+		for _, piece := range append(report.Misplaced, report.Unexpected...) {
+			if piece.Reason != dtos.UnexpectedOnLoan && piece.FoundLocationId != nil {
+				correctable[piece.ArtefactId] = piece
+			}
+		}
+
+		artefactIds := dto.ArtefactIds
+		if len(artefactIds) == 0 {
+			for artefactId := range correctable {
+				artefactIds = append(artefactIds, artefactId)
+			}
+			sort.Ints(artefactIds)
+		}
+		if len(artefactIds) == 0 {
+			return ErrNoStocktakeCorrections
+		}
+
+		reason := dto.Reason
+		if reason == nil {
+			reason = stringPtr(fmt.Sprintf("Corrección del recuento de inventario %d", id))
+		}
+
+		now := time.Now()
+		movements := make([]*models.InternalMovementModel, 0, len(artefactIds))
+		for _, artefactId := range artefactIds {
+			piece, ok := correctable[artefactId]
+			if !ok {
+				return fmt.Errorf("pieza %d: %w", artefactId, ErrNotStocktakeCorrection)
+			}
+			movements = append(movements, &models.InternalMovementModel{
+				MovementDate:         now,
+				MovementTime:         now,
+				ArtefactId:           artefactId,
+				ToPhysicalLocationId: piece.FoundLocationId,
+				Reason:               reason,
+			})
+		}
+
+		group = &models.MovementGroupModel{
+			Reason:             reason,
+			CreatedById:        userId,
+			StocktakeSessionId: &session.Id,
+		}
+		return applyMovementGroup(tx, group, movements)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.movementService.GetMovementGroupByID(group.Id)
+}
+
+// CloseStocktake finishes a session; its scans can't change afterwards
+func (s *StocktakeService) CloseStocktake(id int, userId *int) (*models.StocktakeSessionModel, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		session, err := lockOpenStocktake(tx, id)
+		if err != nil {
+			return err
+		}
+		return tx.Model(session).Updates(map[string]interface{}{
+			"status":       models.StocktakeStatusClosed,
+			"closed_at":    time.Now(),
+			"closed_by_id": userId,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetStocktakeByID(id)
+}
@@ -39,6 +39,9 @@ func (s *UserService) CreateUser(user *models.UserModel) (*models.UserModel, err
 	}
 	user.Password = string(hashedPassword)
 
+	// El rol nunca se toma del registro: los usuarios nuevos son personal y un admin los promueve
+	user.Role = models.RoleStaff
+
 	result := s.db.Create(user)
 	if result.Error != nil {
 		return nil, result.Error
@@ -52,6 +55,19 @@ func (s *UserService) DeleteUser(id int) error {
 	return result.Error
 }
 
+// UpdateUserRole changes the role of a user
+func (s *UserService) UpdateUserRole(id int, role string) (*models.UserModel, error) {
+	var user models.UserModel
+	if err := s.db.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&user).Update("role", role).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 // AuthenticateUser checks user credentials and returns a JWT token if valid
 func (s *UserService) AuthenticateUser(username, password string) (string, error) {
 	var user models.UserModel
@@ -69,8 +85,9 @@ func (s *UserService) AuthenticateUser(username, password string) (string, error
 	}
 
 	claims := jwt.MapClaims{
-		"id":  user.Id,
-		"exp": time.Now().Add(time.Hour * 12).Unix(), // Token expires in 12 hours
+		"id":   user.Id,
+		"role": user.Role,
+		"exp":  time.Now().Add(time.Hour * 12).Unix(), // Token expires in 12 hours
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage keeps files on the local filesystem, under root
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage creates a local-disk storage. Relative keys are resolved against root,
+// absolute keys (e.g. an absolute INPL_UPLOAD_ROOT) are used as they are.
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{root: root}
+}
+
+func (l *LocalStorage) Name() string {
+	return BackendLocal
+}
+
+// path returns the filesystem path of a key
+func (l *LocalStorage) path(key string) string {
+	if filepath.IsAbs(key) {
+		return key
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key))
+}
+
+func (l *LocalStorage) Put(key string, r io.Reader, size int64, contentType string) error {
+	dest := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+
+	// Escribir a un temporal y renombrar para no dejar archivos a medio escribir
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".upload_*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (l *LocalStorage) Open(key string) (io.ReadCloser, error) {
+	f, err := os.Open(l.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *LocalStorage) Stat(key string) (FileInfo, error) {
+	info, err := os.Stat(l.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return FileInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if info.IsDir() {
+		return FileInfo{}, ErrNotFound
+	}
+	return FileInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (l *LocalStorage) Delete(key string) error {
+	err := os.Remove(l.path(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List walks the directory containing prefix. Keys keep the same form as prefix (relative or absolute).
+func (l *LocalStorage) List(prefix string) ([]FileInfo, error) {
+	base := l.path(prefix)
+	walkRoot := base
+	if info, err := os.Stat(base); err != nil || !info.IsDir() {
+		walkRoot = filepath.Dir(base)
+	}
+
+	var files []FileInfo
+	err := filepath.WalkDir(walkRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasPrefix(p, base) || strings.HasPrefix(d.Name(), ".upload_") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		key := p
+		if !filepath.IsAbs(prefix) {
+			if key, err = filepath.Rel(l.root, p); err != nil {
+				return err
+			}
+		}
+		files = append(files, FileInfo{Key: filepath.ToSlash(key), Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
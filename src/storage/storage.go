@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when the requested key doesn't exist in the backend
+var ErrNotFound = errors.New("archivo no encontrado en el almacenamiento")
+
+// Backends supported by STORAGE_BACKEND
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// FileInfo describes a stored object
+type FileInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage is where uploaded files (pictures, historical records, INPL fichas and their derivatives) live.
+// Keys are the FilePath values stored in the database, e.g. "uploads/pictures/artefact_1_...jpg".
+type Storage interface {
+	// Name identifies the backend (local, s3)
+	Name() string
+	// Put stores the content under key, replacing any previous object. size may be -1 if unknown.
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Open returns the content of key; the caller must close it
+	Open(key string) (io.ReadCloser, error)
+	// Stat returns size and modification time of key
+	Stat(key string) (FileInfo, error)
+	// Delete removes key; deleting a missing key is not an error
+	Delete(key string) error
+	// List returns every object whose key starts with prefix
+	List(prefix string) ([]FileInfo, error)
+}
+
+// Exists reports whether a non-empty key is present in the storage
+func Exists(s Storage, key string) bool {
+	if key == "" {
+		return false
+	}
+	_, err := s.Stat(key)
+	return err == nil
+}
+
+// NewFromEnv creates the backend selected by STORAGE_BACKEND (local by default)
+func NewFromEnv() (Storage, error) {
+	return New(os.Getenv("STORAGE_BACKEND"))
+}
+
+// New creates the given backend configured from environment variables:
+//   - local: LOCAL_STORAGE_ROOT (default: working directory, where uploads/ lives)
+//   - s3: S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY
+func New(backend string) (Storage, error) {
+	switch strings.ToLower(strings.TrimSpace(backend)) {
+	case "", BackendLocal:
+		root := os.Getenv("LOCAL_STORAGE_ROOT")
+		if root == "" {
+			root = "."
+		}
+		return NewLocalStorage(root), nil
+	case BackendS3:
+		return NewS3Storage(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	}
+	return nil, fmt.Errorf("backend de almacenamiento desconocido: %s (usar local o s3)", backend)
+}
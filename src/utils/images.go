@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"path/filepath"
+	"strings"
+
+	// Decoders registered for image.Decode
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Image sizes accepted by the serve endpoints (?size=...)
+const (
+	ImageSizeThumb    = "thumb"
+	ImageSizeMedium   = "medium"
+	ImageSizeOriginal = "original"
+)
+
+// Longest side (in pixels) of each derivative
+const (
+	thumbMaxDimension  = 320
+	mediumMaxDimension = 1280
+	derivativeQuality  = 82
+)
+
+// ParseImageSize validates the ?size= query value, defaulting to the original file
+func ParseImageSize(size string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(size)) {
+	case "", ImageSizeOriginal:
+		return ImageSizeOriginal, nil
+	case ImageSizeThumb:
+		return ImageSizeThumb, nil
+	case ImageSizeMedium:
+		return ImageSizeMedium, nil
+	}
+	return "", fmt.Errorf("tamaño de imagen inválido: %s (usar thumb, medium u original)", size)
+}
+
+// DerivativePath returns where the derivative of the given size is stored, next to the original
+func DerivativePath(originalPath, size string) string {
+	dir := filepath.Dir(originalPath)
+	base := strings.TrimSuffix(filepath.Base(originalPath), filepath.Ext(originalPath))
+	return filepath.Join(dir, fmt.Sprintf("%s_%s.jpg", base, size))
+}
+
+// EncodeImageDerivatives decodes an image and returns its thumbnail and medium JPEG versions.
+// Callers store them under DerivativePath of the original.
+func EncodeImageDerivatives(r io.Reader) ([]byte, []byte, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("no se pudo decodificar la imagen: %w", err)
+	}
+
+	thumb, err := encodeResizedJPEG(img, thumbMaxDimension)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	medium, err := encodeResizedJPEG(img, mediumMaxDimension)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return thumb, medium, nil
+}
+
+// encodeResizedJPEG scales the image so its longest side is at most maxDimension and encodes it as JPEG
+func encodeResizedJPEG(img image.Image, maxDimension int) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("imagen vacía")
+	}
+
+	// Never upscale: small images keep their size
+	targetWidth, targetHeight := width, height
+	if width > maxDimension || height > maxDimension {
+		if width >= height {
+			targetWidth = maxDimension
+			targetHeight = max(1, height*maxDimension/width)
+		} else {
+			targetHeight = maxDimension
+			targetWidth = max(1, width*maxDimension/height)
+		}
+	}
+
+	// White background so transparent PNG/WebP/GIF don't turn black in JPEG
+	dst := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: derivativeQuality}); err != nil {
+		return nil, fmt.Errorf("no se pudo codificar la imagen derivada: %w", err)
+	}
+	return buf.Bytes(), nil
+}
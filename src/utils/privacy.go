@@ -0,0 +1,18 @@
+package utils
+
+import "strings"
+
+// MaskTail replaces every character but the last visible ones with '*' (e.g. DNI 30123456 -> *****456).
+// Nil and empty values are returned as they are.
+func MaskTail(value *string, visible int) *string {
+	if value == nil || *value == "" {
+		return value
+	}
+	runes := []rune(*value)
+	if len(runes) <= visible {
+		masked := strings.Repeat("*", len(runes))
+		return &masked
+	}
+	masked := strings.Repeat("*", len(runes)-visible) + string(runes[len(runes)-visible:])
+	return &masked
+}
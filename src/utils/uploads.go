@@ -0,0 +1,318 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Kinds of uploaded files, each with its own allowed types and size cap
+const (
+	UploadKindPicture  = "picture"
+	UploadKindDocument = "document"
+	UploadKindFicha    = "ficha"
+)
+
+var (
+	ErrUploadEmpty          = errors.New("el archivo está vacío")
+	ErrUploadTooLarge       = errors.New("el archivo supera el tamaño máximo permitido")
+	ErrUploadTypeNotAllowed = errors.New("tipo de archivo no permitido")
+	ErrUploadInvalid        = errors.New("el archivo no es válido")
+	ErrUploadPolyglot       = errors.New("el archivo contiene contenido ajeno a su formato")
+)
+
+// Default size caps in MB, overridable with UPLOAD_MAX_PICTURE_MB, UPLOAD_MAX_DOCUMENT_MB and UPLOAD_MAX_FICHA_MB
+var defaultUploadMaxMB = map[string]int64{
+	UploadKindPicture:  20,
+	UploadKindDocument: 30,
+	UploadKindFicha:    20,
+}
+
+// Content types accepted for each kind (always detected from the bytes, never from the client)
+var allowedUploadTypes = map[string][]string{
+	UploadKindPicture:  {"image/jpeg", "image/png", "image/webp", "image/gif"},
+	UploadKindDocument: {"image/jpeg", "image/png", "image/webp", "image/gif", "application/pdf"},
+	UploadKindFicha:    {"image/jpeg", "image/png", "image/webp"},
+}
+
+var uploadExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"image/gif":       ".gif",
+	"application/pdf": ".pdf",
+}
+
+// maxImagePixels rejects decompression bombs before derivatives are generated
+const maxImagePixels = 150_000_000
+
+// Markup or scripts hidden inside an image/PDF (HTML/SVG/PHP polyglots)
+var activeContentPattern = regexp.MustCompile(`(?i)<\s*(script|html|svg|iframe|object|embed)[\s>/]|<\?php|<!doctype\s+html`)
+
+// PDF features that execute code or carry other files
+var pdfActiveContentPattern = regexp.MustCompile(`/(JavaScript|JS|Launch|EmbeddedFiles?|RichMedia)[^A-Za-z]`)
+
+// ValidatedUpload is an uploaded file that passed validation, ready to be stored
+type ValidatedUpload struct {
+	Data        []byte
+	ContentType string // detected from the content
+	Filename    string // sanitized, with the extension of ContentType
+}
+
+// MaxUploadSize returns the size cap in bytes for the kind of upload
+func MaxUploadSize(kind string) int64 {
+	maxMB := defaultUploadMaxMB[kind]
+	if v := os.Getenv("UPLOAD_MAX_" + strings.ToUpper(kind) + "_MB"); v != "" {
+		if parsed, err := strconv.ParseInt(v, 10, 64); err == nil && parsed > 0 {
+			maxMB = parsed
+		}
+	}
+	return maxMB << 20
+}
+
+// StripMetadataEnabled reports whether UPLOAD_STRIP_METADATA asks to remove EXIF/XMP/text metadata from images
+func StripMetadataEnabled() bool {
+	v, _ := strconv.ParseBool(os.Getenv("UPLOAD_STRIP_METADATA"))
+	return v
+}
+
+// ValidateUpload reads an upload (up to the size cap of its kind), detects its real type from the
+// magic bytes, rejects disallowed types, malformed images and polyglot files, and optionally strips
+// image metadata. The returned filename is sanitized and its extension matches the detected type.
+func ValidateUpload(kind string, r io.Reader, originalName string) (*ValidatedUpload, error) {
+	maxSize := MaxUploadSize(kind)
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, ErrUploadEmpty
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w (%d MB)", ErrUploadTooLarge, maxSize>>20)
+	}
+
+	contentType := http.DetectContentType(data)
+	if !isAllowedUploadType(kind, contentType) {
+		return nil, fmt.Errorf("%w: %s", ErrUploadTypeNotAllowed, contentType)
+	}
+
+	if err := checkUploadStructure(contentType, data); err != nil {
+		return nil, err
+	}
+
+	if StripMetadataEnabled() {
+		if data, err = StripImageMetadata(contentType, data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUploadInvalid, err)
+		}
+	}
+
+	return &ValidatedUpload{
+		Data:        data,
+		ContentType: contentType,
+		Filename:    safeUploadFilename(originalName, contentType),
+	}, nil
+}
+
+// SanitizeFilename removes potentially dangerous characters from filenames
+func SanitizeFilename(name string) string {
+	name = strings.TrimSpace(filepath.Base(filepath.ToSlash(name)))
+	name = strings.ReplaceAll(name, "..", "")
+	name = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') ||
+			(r >= 'A' && r <= 'Z') ||
+			(r >= '0' && r <= '9') ||
+			strings.ContainsRune("._- ", r) {
+			return r
+		}
+		return '_'
+	}, name)
+	return strings.Trim(name, ". ")
+}
+
+// safeUploadFilename sanitizes the client filename and forces the extension of the detected type
+func safeUploadFilename(originalName, contentType string) string {
+	base := SanitizeFilename(originalName)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	if base == "" {
+		base = "file"
+	}
+	return base + uploadExtensions[contentType]
+}
+
+func isAllowedUploadType(kind, contentType string) bool {
+	for _, allowed := range allowedUploadTypes[kind] {
+		if contentType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUploadStructure verifies the file is well-formed for its type and has nothing appended or embedded
+func checkUploadStructure(contentType string, data []byte) error {
+	if contentType == "application/pdf" {
+		return checkPDF(data)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUploadInvalid, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return fmt.Errorf("%w: dimensiones de imagen no soportadas (%dx%d)", ErrUploadInvalid, cfg.Width, cfg.Height)
+	}
+
+	if activeContentPattern.Match(data) {
+		return ErrUploadPolyglot
+	}
+
+	// Datos después del final de la imagen: típico de polyglots (ZIP/JAR/HTML anexados)
+	end := imageEnd(contentType, data)
+	if end < 0 {
+		return fmt.Errorf("%w: imagen truncada", ErrUploadInvalid)
+	}
+	if len(bytes.Trim(data[end:], "\x00\r\n\t ")) > 0 {
+		return ErrUploadPolyglot
+	}
+	return nil
+}
+
+// imageEnd returns the offset right after the end marker of the image, or -1 if there is none
+func imageEnd(contentType string, data []byte) int {
+	switch contentType {
+	case "image/jpeg":
+		if i := bytes.LastIndex(data, []byte{0xFF, 0xD9}); i >= 0 {
+			return i + 2
+		}
+	case "image/png":
+		if i := bytes.LastIndex(data, []byte("IEND")); i >= 0 && i+8 <= len(data) {
+			return i + 8 // type + CRC
+		}
+	case "image/gif":
+		if i := bytes.LastIndexByte(data, 0x3B); i >= 0 {
+			return i + 1
+		}
+	case "image/webp":
+		if len(data) >= 12 {
+			size := int(binary.LittleEndian.Uint32(data[4:8])) + 8
+			if size <= len(data) {
+				return size
+			}
+		}
+	}
+	return -1
+}
+
+// checkPDF requires a PDF header at offset 0, no data after the last %%EOF and no active content
+func checkPDF(data []byte) error {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return fmt.Errorf("%w: encabezado PDF inválido", ErrUploadInvalid)
+	}
+	eof := bytes.LastIndex(data, []byte("%%EOF"))
+	if eof < 0 {
+		return fmt.Errorf("%w: PDF truncado", ErrUploadInvalid)
+	}
+	if len(bytes.TrimSpace(data[eof+5:])) > 0 {
+		return ErrUploadPolyglot
+	}
+	if pdfActiveContentPattern.Match(data) {
+		return fmt.Errorf("%w: el PDF contiene scripts o archivos embebidos", ErrUploadPolyglot)
+	}
+	return nil
+}
+
+// StripImageMetadata removes EXIF/XMP/IPTC and comments from JPEG, and text/EXIF chunks from PNG,
+// without re-encoding the image. Other types are returned unchanged.
+// Note the EXIF orientation tag goes away too.
+func StripImageMetadata(contentType string, data []byte) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEGMetadata(data)
+	case "image/png":
+		return stripPNGMetadata(data)
+	}
+	return data, nil
+}
+
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errors.New("JPEG inválido")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, errors.New("marcador JPEG inválido")
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // relleno
+			i++
+			continue
+		}
+		// Marcadores sin longitud
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write(data[i : i+2])
+			i += 2
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if length < 2 || i+2+length > len(data) {
+			return nil, errors.New("segmento JPEG truncado")
+		}
+		segment := data[i : i+2+length]
+
+		// Desde SOS en adelante son los datos comprimidos: se copian tal cual
+		if marker == 0xDA {
+			out.Write(data[i:])
+			return out.Bytes(), nil
+		}
+
+		// APP1 (EXIF/XMP), APP13 (IPTC) y comentarios
+		if marker != 0xE1 && marker != 0xED && marker != 0xFE {
+			out.Write(segment)
+		}
+		i += 2 + length
+	}
+	return nil, errors.New("JPEG sin datos de imagen")
+}
+
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 8 {
+		return nil, errors.New("PNG inválido")
+	}
+
+	metadataChunks := map[string]bool{"tEXt": true, "zTXt": true, "iTXt": true, "eXIf": true, "tIME": true}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:8])
+	i := 8
+	for i+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errors.New("chunk PNG truncado")
+		}
+		chunkType := string(data[i+4 : i+8])
+		if !metadataChunks[chunkType] {
+			out.Write(data[i:end])
+		}
+		i = end
+		if chunkType == "IEND" {
+			return out.Bytes(), nil
+		}
+	}
+	return nil, errors.New("PNG sin IEND")
+}
@@ -0,0 +1,130 @@
+package main
+
+// Copies every stored file referenced by the database (pictures, historical records,
+// INPL fichas and their derivatives, generated loan documents) from one storage backend to another.
+//
+//	go run ./utils/storage_migrate -from local -to s3 [-dry-run] [-delete-source]
+//
+// Both backends are configured from the same environment variables the server uses
+// (LOCAL_STORAGE_ROOT, S3_ENDPOINT, S3_BUCKET, ...). Keys stay the same, so once the
+// copy finishes it's enough to switch STORAGE_BACKEND and restart the server.
+
+import (
+	"flag"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+
+	dbpkg "github.com/ARQAP/ARQAP-Backend/src/db"
+	"github.com/ARQAP/ARQAP-Backend/src/storage"
+	"gorm.io/gorm"
+)
+
+// fileColumns lists, per table, the columns holding storage keys
+var fileColumns = map[string][]string{
+	"picture_models":           {"file_path", "thumbnail_path", "medium_path"},
+	"historical_record_models": {"file_path"},
+	"inpl_fichas":              {"file_path", "thumbnail_path", "medium_path"},
+	"loan_document_models":     {"file_path"},
+}
+
+func main() {
+	from := flag.String("from", storage.BackendLocal, "source backend (local, s3)")
+	to := flag.String("to", storage.BackendS3, "destination backend (local, s3)")
+	dryRun := flag.Bool("dry-run", false, "only report what would be copied")
+	deleteSource := flag.Bool("delete-source", false, "delete each file from the source once it is in the destination")
+	flag.Parse()
+
+	if *from == *to {
+		log.Fatalf("source and destination backends must differ")
+	}
+
+	db, err := dbpkg.Connect()
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v\n", err)
+	}
+
+	src, err := storage.New(*from)
+	if err != nil {
+		log.Fatalf("Error configuring source storage: %v\n", err)
+	}
+	dst, err := storage.New(*to)
+	if err != nil {
+		log.Fatalf("Error configuring destination storage: %v\n", err)
+	}
+
+	keys, err := collectKeys(db)
+	if err != nil {
+		log.Fatalf("Error reading stored files: %v\n", err)
+	}
+	log.Printf("%d files referenced by the database\n", len(keys))
+
+	var copied, skipped, missing, failed int
+	for _, key := range keys {
+		info, err := src.Stat(key)
+		if err != nil {
+			log.Printf("MISSING %s: %v\n", key, err)
+			missing++
+			continue
+		}
+
+		if existing, err := dst.Stat(key); err == nil && existing.Size == info.Size {
+			skipped++
+		} else if *dryRun {
+			log.Printf("WOULD COPY %s (%d bytes)\n", key, info.Size)
+			copied++
+			continue
+		} else if err := copyFile(src, dst, key, info.Size); err != nil {
+			log.Printf("FAILED %s: %v\n", key, err)
+			failed++
+			continue
+		} else {
+			copied++
+		}
+
+		if *deleteSource && !*dryRun {
+			if err := src.Delete(key); err != nil {
+				log.Printf("Could not delete %s from source: %v\n", key, err)
+			}
+		}
+	}
+
+	log.Printf("Copied: %d, already present: %d, missing in source: %d, failed: %d\n", copied, skipped, missing, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// collectKeys returns every distinct non-empty storage key referenced by the database
+func collectKeys(db *gorm.DB) ([]string, error) {
+	seen := map[string]bool{}
+	var keys []string
+
+	for table, columns := range fileColumns {
+		for _, column := range columns {
+			var values []string
+			if err := db.Table(table).Where(column+" <> ''").Distinct().Pluck(column, &values).Error; err != nil {
+				return nil, err
+			}
+			for _, v := range values {
+				if !seen[v] {
+					seen[v] = true
+					keys = append(keys, v)
+				}
+			}
+		}
+	}
+	return keys, nil
+}
+
+// copyFile streams a single file from src to dst
+func copyFile(src, dst storage.Storage, key string, size int64) error {
+	r, err := src.Open(key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return dst.Put(key, r, size, mime.TypeByExtension(filepath.Ext(key)))
+}